@@ -31,6 +31,52 @@ func (ar *AccountResolver) SlackUserFromGithubUsername(githubUserName string) (s
 	return ar.SlackUserFromEmail(user.Slack.Email, false)
 }
 
+// GithubUsernameFromSlackUser returns github user name of registered slack user.
+// user is looked up by slack id recorded by reconciliation, then by email of slack profile.
+func (ar *AccountResolver) GithubUsernameFromSlackUser(slackUserID string) (string, error) {
+	find := func(filter *User) (string, error) {
+		users, err := ar.UserStore.FindUsers(context.Background(), &FindUsersInput{Limit: 1, Filter: filter})
+		if err != nil {
+			return "", err
+		}
+		if users[0].Github.UserName == "" {
+			return "", ErrUserNotFound
+		}
+		return users[0].Github.UserName, nil
+	}
+
+	name, err := find(&User{Slack: SlackProfile{ID: slackUserID}})
+	if err == nil || !IsUserNotFound(err) {
+		return name, errors.Annotatef(err, "slack user id=%s", slackUserID)
+	}
+
+	user, err := ar.SlackUserFromID(slackUserID)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	name, err = find(&User{Slack: SlackProfile{Email: user.Profile.Email}})
+	return name, errors.Annotatef(err, "slack user id=%s", slackUserID)
+}
+
+// SlackUserFromID -
+func (ar *AccountResolver) SlackUserFromID(slackUserID string) (slack.User, error) {
+	ar.Mu.Lock()
+	defer ar.Mu.Unlock()
+	for _, updateCache := range []bool{false, true} {
+		if ar.slackUsers == nil || updateCache {
+			if err := ar.updateSlackUsersCache(); err != nil {
+				return slack.User{}, errors.Trace(err)
+			}
+		}
+		for i := range ar.slackUsers {
+			if ar.slackUsers[i].ID == slackUserID {
+				return ar.slackUsers[i], nil
+			}
+		}
+	}
+	return slack.User{}, ErrUserNotFound
+}
+
 // SlackUserFromEmail -
 func (ar *AccountResolver) SlackUserFromEmail(email string, updateCache bool) (slack.User, error) {
 	ar.Mu.Lock()
@@ -59,6 +105,20 @@ func (ar *AccountResolver) slackUserFromEmail(email string, updateCache bool) (s
 	return slack.User{}, ErrUserNotFound
 }
 
+// SlackUsers returns slack workspace members. cache is refreshed when updateCache is true.
+func (ar *AccountResolver) SlackUsers(updateCache bool) ([]slack.User, error) {
+	ar.Mu.Lock()
+	defer ar.Mu.Unlock()
+	if ar.slackUsers == nil || updateCache {
+		if err := ar.updateSlackUsersCache(); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	users := make([]slack.User, len(ar.slackUsers))
+	copy(users, ar.slackUsers)
+	return users, nil
+}
+
 func (ar *AccountResolver) fetchSlackUsers() ([]slack.User, error) {
 	return ar.SlackClient.GetUsers()
 }
@@ -16,7 +16,9 @@ func NewAddCommand(b *CommandBuilder) *cli.Command {
 		LongDesc:  "@gobot add <OPTIONS> <RESOURCE>",
 	}
 
-	return cmd.AddCommand(NewAddUserCommand(b.UserStore))
+	return cmd.
+		AddCommand(NewAddUserCommand(b.UserStore)).
+		AddCommand(NewAddTeamCommand(b.Teams))
 }
 
 func NewAddUserCommand(users UserStore) *cli.Command {
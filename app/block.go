@@ -0,0 +1,272 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/nlopes/slack"
+)
+
+// SlackRenderer decides how messages are rendered.
+type SlackRenderer string
+
+const (
+	// RenderBlocks renders messages with Block Kit.
+	RenderBlocks SlackRenderer = "blocks"
+	// RenderAttachments renders messages with legacy attachments for workspaces which prefer them.
+	RenderAttachments SlackRenderer = "attachments"
+)
+
+// ParseSlackRenderer -
+func ParseSlackRenderer(s string) (SlackRenderer, error) {
+	switch r := SlackRenderer(strings.ToLower(s)); r {
+	case RenderBlocks, RenderAttachments:
+		return r, nil
+	default:
+		return "", errors.Errorf("unknown slack renderer %q. renderer must be blocks or attachments", s)
+	}
+}
+
+// nlopes/slack v0.5.0 does not support Block Kit, so minimum block types are defined here.
+// see https://api.slack.com/reference/messaging/blocks
+
+// Block -
+type Block struct {
+	Type      string        `json:"type"`
+	Text      *TextObject   `json:"text,omitempty"`
+	Fields    []*TextObject `json:"fields,omitempty"`
+	Accessory *BlockElement `json:"accessory,omitempty"`
+	// Elements are TextObject or BlockElement.
+	Elements []interface{} `json:"elements,omitempty"`
+}
+
+// TextObject -
+type TextObject struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// BlockElement is image or button element.
+type BlockElement struct {
+	Type     string      `json:"type"`
+	Text     *TextObject `json:"text,omitempty"`
+	URL      string      `json:"url,omitempty"`
+	ImageURL string      `json:"image_url,omitempty"`
+	AltText  string      `json:"alt_text,omitempty"`
+}
+
+const (
+	slackAPIURL = "https://slack.com/api/"
+
+	// limits are counted in characters.
+	maxSectionTextLength  = 3000
+	maxSectionFieldLength = 2000
+	maxSectionFields      = 10
+)
+
+func mrkdwn(text string, max int) *TextObject {
+	if runes := []rune(text); len(runes) > max {
+		text = string(runes[:max-3]) + "..."
+	}
+	return &TextObject{Type: "mrkdwn", Text: text}
+}
+
+// AttachmentBlocks renders attachment as blocks. the attachment is still the single source of message contents.
+// title, author and field titles are plain text in attachments, so they are escaped to be embedded in mrkdwn.
+// pretext, text and field values are mrkdwn already and their producers escape user contents.
+func AttachmentBlocks(a slack.Attachment) []*Block {
+	var blocks []*Block
+	if a.Pretext != "" {
+		blocks = append(blocks, &Block{Type: "section", Text: mrkdwn(a.Pretext, maxSectionTextLength)})
+	}
+
+	var body []string
+	switch {
+	case a.Title != "" && a.TitleLink != "":
+		body = append(body, "*<"+a.TitleLink+"|"+slackTextEscaper.Replace(a.Title)+">*")
+	case a.Title != "":
+		body = append(body, "*"+slackTextEscaper.Replace(a.Title)+"*")
+	}
+	if a.Text != "" {
+		body = append(body, a.Text)
+	}
+	if len(body) > 0 {
+		section := &Block{Type: "section", Text: mrkdwn(strings.Join(body, "\n"), maxSectionTextLength)}
+		if a.AuthorIcon != "" {
+			section.Accessory = &BlockElement{Type: "image", ImageURL: a.AuthorIcon, AltText: a.AuthorName}
+		}
+		blocks = append(blocks, section)
+	}
+
+	for i := 0; i < len(a.Fields); i += maxSectionFields {
+		section := &Block{Type: "section"}
+		for _, f := range a.Fields[i:minInt(i+maxSectionFields, len(a.Fields))] {
+			section.Fields = append(section.Fields, mrkdwn("*"+slackTextEscaper.Replace(f.Title)+"*\n"+f.Value, maxSectionFieldLength))
+		}
+		blocks = append(blocks, section)
+	}
+
+	var context []interface{}
+	if a.AuthorName != "" {
+		context = append(context, mrkdwn(slackTextEscaper.Replace(a.AuthorName), maxSectionFieldLength))
+	}
+	if a.Footer != "" {
+		context = append(context, mrkdwn(a.Footer, maxSectionFieldLength))
+	}
+	if len(context) > 0 {
+		blocks = append(blocks, &Block{Type: "context", Elements: context})
+	}
+
+	if a.TitleLink != "" {
+		blocks = append(blocks, &Block{Type: "actions", Elements: []interface{}{
+			&BlockElement{Type: "button", Text: &TextObject{Type: "plain_text", Text: "Open"}, URL: a.TitleLink},
+		}})
+	}
+	return blocks
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// BlockPoster posts Block Kit messages by calling chat.postMessage directly.
+type BlockPoster struct {
+	Token      string
+	HTTPClient *http.Client
+	// APIURL is for testing. default is slackAPIURL.
+	APIURL string
+}
+
+// BlockMessage is posted by BlockPoster. blocks are wrapped in an attachment to keep color bar of the attachment.
+type BlockMessage struct {
+	// Text is message text such as mentions. it is displayed above the attachment.
+	Text     string
+	Color    string
+	Fallback string
+	Blocks   json.RawMessage
+}
+
+// NewBlockMessage renders attachment as blocks.
+func NewBlockMessage(text string, attachment slack.Attachment) (*BlockMessage, error) {
+	blocks, err := marshalBlocks(AttachmentBlocks(attachment))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &BlockMessage{Text: text, Color: attachment.Color, Fallback: attachment.Fallback, Blocks: json.RawMessage(blocks)}, nil
+}
+
+type blockAttachment struct {
+	Color    string          `json:"color,omitempty"`
+	Fallback string          `json:"fallback,omitempty"`
+	Blocks   json.RawMessage `json:"blocks"`
+}
+
+func (m *BlockMessage) params(channel string) map[string]interface{} {
+	return map[string]interface{}{
+		"channel":     channel,
+		"text":        m.Text,
+		"attachments": []blockAttachment{{Color: m.Color, Fallback: m.Fallback, Blocks: m.Blocks}},
+	}
+}
+
+// BlockError is returned when slack rejects blocks. callers fall back to legacy attachments.
+type BlockError struct {
+	Method string
+	Code   string
+}
+
+func (e *BlockError) Error() string {
+	return e.Method + " failed: " + e.Code
+}
+
+// IsBlockError reports whether err is caused by invalid blocks.
+func IsBlockError(err error) bool {
+	_, ok := errors.Cause(err).(*BlockError)
+	return ok
+}
+
+type postMessageResponse struct {
+	OK      bool   `json:"ok"`
+	Error   string `json:"error"`
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+}
+
+// PostMessage posts msg to channel.
+// on rate limit, it returns *slack.RateLimitedError like nlopes/slack.
+func (p *BlockPoster) PostMessage(ctx context.Context, channel string, msg *BlockMessage) (string, string, error) {
+	return p.call(ctx, "chat.postMessage", msg.params(channel))
+}
+
+// PostReply posts msg to thread of threadTS.
+func (p *BlockPoster) PostReply(ctx context.Context, channel, threadTS string, msg *BlockMessage) (string, string, error) {
+	params := msg.params(channel)
+	params["thread_ts"] = threadTS
+	return p.call(ctx, "chat.postMessage", params)
+}
+
+// UpdateMessage replaces message of ts. channel must be channel id.
+func (p *BlockPoster) UpdateMessage(ctx context.Context, channel, ts string, msg *BlockMessage) (string, string, error) {
+	params := msg.params(channel)
+	params["ts"] = ts
+	return p.call(ctx, "chat.update", params)
+}
+
+func (p *BlockPoster) call(ctx context.Context, method string, params map[string]interface{}) (string, string, error) {
+	channel := params["channel"]
+	body, err := json.Marshal(params)
+	if err != nil {
+		return "", "", errors.Trace(err)
+	}
+
+	apiURL := p.APIURL
+	if apiURL == "" {
+		apiURL = slackAPIURL
+	}
+	req, err := http.NewRequest(http.MethodPost, apiURL+method, bytes.NewReader(body))
+	if err != nil {
+		return "", "", errors.Trace(err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+p.Token)
+
+	client := p.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", "", errors.Annotatef(err, "channel=%v", channel)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusTooManyRequests {
+		retryAfter, _ := strconv.ParseInt(res.Header.Get("Retry-After"), 10, 64)
+		return "", "", &slack.RateLimitedError{RetryAfter: time.Duration(retryAfter) * time.Second}
+	}
+	var resp postMessageResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return "", "", errors.Annotatef(err, "status=%d", res.StatusCode)
+	}
+	if !resp.OK {
+		if strings.HasPrefix(resp.Error, "invalid_blocks") || resp.Error == "invalid_attachments" {
+			return "", "", &BlockError{Method: method, Code: resp.Error}
+		}
+		return "", "", errors.Errorf("%s failed: %s", method, resp.Error)
+	}
+	return resp.Channel, resp.TS, nil
+}
+
+func marshalBlocks(blocks []*Block) (string, error) {
+	b, err := json.Marshal(blocks)
+	return string(b), errors.Trace(err)
+}
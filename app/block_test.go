@@ -0,0 +1,140 @@
+package app_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nlopes/slack"
+
+	"github.com/ymgyt/gobot/app"
+)
+
+func TestAttachmentBlocks(t *testing.T) {
+	blocks := app.AttachmentBlocks(slack.Attachment{
+		Pretext:    ":ok_hand: <@U1> your PR *approved*",
+		AuthorName: "ymgyt",
+		AuthorIcon: "https://example.com/ymgyt.png",
+		Title:      "PR (fix) review",
+		TitleLink:  "https://github.com/ymgyt/gobot/pull/1",
+		Text:       "LGTM",
+		Footer:     "Github webhook (v0.1.0)",
+		Fields:     []slack.AttachmentField{{Title: "Repository", Value: "gobot", Short: true}},
+	})
+
+	encoded, err := json.Marshal(blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[` +
+		`{"type":"section","text":{"type":"mrkdwn","text":":ok_hand: <@U1> your PR *approved*"}},` +
+		`{"type":"section","text":{"type":"mrkdwn","text":"*<https://github.com/ymgyt/gobot/pull/1|PR (fix) review>*\nLGTM"},` +
+		`"accessory":{"type":"image","image_url":"https://example.com/ymgyt.png","alt_text":"ymgyt"}},` +
+		`{"type":"section","fields":[{"type":"mrkdwn","text":"*Repository*\ngobot"}]},` +
+		`{"type":"context","elements":[{"type":"mrkdwn","text":"ymgyt"},{"type":"mrkdwn","text":"Github webhook (v0.1.0)"}]},` +
+		`{"type":"actions","elements":[{"type":"button","text":{"type":"plain_text","text":"Open"},"url":"https://github.com/ymgyt/gobot/pull/1"}]}` +
+		`]`
+
+	var wantBlocks, gotBlocks interface{}
+	if err := json.Unmarshal([]byte(want), &wantBlocks); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(encoded, &gotBlocks); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(wantBlocks, gotBlocks); diff != "" {
+		t.Errorf("(-want +got)\n%s", diff)
+	}
+}
+
+func TestAttachmentBlocks_Escape(t *testing.T) {
+	blocks := app.AttachmentBlocks(slack.Attachment{
+		AuthorName: "<!here>",
+		Title:      "<!channel> fix & <https://example.com|click>",
+		TitleLink:  "https://github.com/ymgyt/gobot/pull/1",
+		Fields:     []slack.AttachmentField{{Title: "<!everyone>", Value: "gobot"}},
+	})
+
+	encoded, err := json.Marshal(blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []struct {
+		Text     *app.TextObject
+		Fields   []*app.TextObject
+		Elements []map[string]interface{}
+	}
+	if err := json.Unmarshal(encoded, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := "*<https://github.com/ymgyt/gobot/pull/1|&lt;!channel&gt; fix &amp; &lt;https://example.com|click&gt;>*"
+	if got[0].Text.Text != want {
+		t.Errorf("title want %s, got %s", want, got[0].Text.Text)
+	}
+	if got[1].Fields[0].Text != "*&lt;!everyone&gt;*\ngobot" {
+		t.Errorf("field is not escaped: %s", got[1].Fields[0].Text)
+	}
+	if got[2].Elements[0]["text"] != "&lt;!here&gt;" {
+		t.Errorf("author is not escaped: %v", got[2].Elements[0]["text"])
+	}
+}
+
+func TestBlockPoster_PostMessage(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("Authorization") != "Bearer xoxb-test" {
+			t.Errorf("unexpected authorization header %s", r.Header.Get("Authorization"))
+		}
+		switch requests {
+		case 1:
+			w.Header().Set("Retry-After", "3")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			var params struct {
+				Text        string `json:"text"`
+				Attachments []struct {
+					Color    string          `json:"color"`
+					Fallback string          `json:"fallback"`
+					Blocks   json.RawMessage `json:"blocks"`
+				} `json:"attachments"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+				t.Fatal(err)
+			}
+			// blocks are wrapped in an attachment to keep its color.
+			if params.Text != "<@U1>" || len(params.Attachments) != 1 || params.Attachments[0].Color != "#cb2431" ||
+				params.Attachments[0].Fallback != "push" || string(params.Attachments[0].Blocks) != "[]" {
+				t.Errorf("unexpected params %+v", params)
+			}
+			_, _ = w.Write([]byte(`{"ok":true,"channel":"C1","ts":"1556000000.000100"}`))
+		default:
+			_, _ = w.Write([]byte(`{"ok":false,"error":"invalid_blocks"}`))
+		}
+	}))
+	defer srv.Close()
+
+	poster := &app.BlockPoster{Token: "xoxb-test", APIURL: srv.URL + "/"}
+	msg := &app.BlockMessage{Text: "<@U1>", Color: "#cb2431", Fallback: "push", Blocks: json.RawMessage(`[]`)}
+	_, _, err := poster.PostMessage(context.Background(), "C1", msg)
+	rateLimitedErr, ok := err.(*slack.RateLimitedError)
+	if !ok || rateLimitedErr.RetryAfter != 3*time.Second {
+		t.Fatalf("want rate limited error, got %v", err)
+	}
+
+	channel, ts, err := poster.PostMessage(context.Background(), "C1", msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if channel != "C1" || ts != "1556000000.000100" {
+		t.Errorf("unexpected channel=%s ts=%s", channel, ts)
+	}
+
+	if _, _, err := poster.PostMessage(context.Background(), "C1", msg); !app.IsBlockError(err) {
+		t.Errorf("want block error, got %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package app
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	// bodies of pull requests and reviews longer than this are truncated with read more link.
+	maxBodyLength = 1000
+)
+
+// BodyFormatter converts github markdown body to slack mrkdwn.
+type BodyFormatter struct {
+	// Mention returns slack mention of github login. ok is false when login can not be resolved.
+	Mention   func(login string) (mention string, ok bool)
+	MaxLength int
+}
+
+var (
+	htmlCommentRegexp   = regexp.MustCompile(`(?s)<!--.*?-->`)
+	githubMentionRegexp = regexp.MustCompile(`(^|[^A-Za-z0-9_.\-/@])@([A-Za-z0-9](?:[A-Za-z0-9-]{0,38}))\b(/[A-Za-z0-9_.-]+)?`)
+	headingRegexp       = regexp.MustCompile(`^#{1,6}\s+(.+?)\s*#*$`)
+	listItemRegexp      = regexp.MustCompile(`^(\s*)[-*+]\s+`)
+	checkboxRegexp      = regexp.MustCompile(`^(\s*)• \[([ xX])\]\s+`)
+	imageRegexp         = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)\)`)
+	linkRegexp          = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	boldRegexp          = regexp.MustCompile(`(\*\*|__)(\S(?:.*?\S)?)(\*\*|__)`)
+	italicRegexp        = regexp.MustCompile(`(^|[^*\w])\*(\S(?:[^*]*?\S)?)\*`)
+	strikeRegexp        = regexp.MustCompile(`~~(\S(?:.*?\S)?)~~`)
+	inlineCodeRegexp    = regexp.MustCompile("`[^`]+`")
+
+	// slack requires these characters to be escaped. escaping also neutralizes <!channel> injection.
+	slackTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+)
+
+// special mentions which notify everyone in channel.
+var broadcastMentions = []string{"channel", "here", "everyone"}
+
+const boldPlaceholder = "\x00"
+
+// GithubMentions returns logins mentioned in body. mentions in code are ignored.
+func GithubMentions(body string) []string {
+	var logins []string
+	var inCodeBlock bool
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inCodeBlock = !inCodeBlock
+			continue
+		}
+		if inCodeBlock {
+			continue
+		}
+		line = inlineCodeRegexp.ReplaceAllString(line, "")
+		for _, m := range githubMentionRegexp.FindAllStringSubmatch(line, -1) {
+			login, team := m[2], m[3]
+			if team != "" || stringsContain(broadcastMentions, strings.ToLower(login)) || stringsContain(logins, login) {
+				continue
+			}
+			logins = append(logins, login)
+		}
+	}
+	return logins
+}
+
+// Format converts body. url is used for read more link of truncated body.
+func (f *BodyFormatter) Format(body, url string) string {
+	body = strings.Replace(body, "\r\n", "\n", -1)
+	body = htmlCommentRegexp.ReplaceAllString(body, "")
+	body = strings.TrimSpace(body)
+
+	var lines []string
+	var inCodeBlock bool
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inCodeBlock = !inCodeBlock
+			lines = append(lines, "```")
+			continue
+		}
+		if inCodeBlock {
+			lines = append(lines, slackTextEscaper.Replace(line))
+			continue
+		}
+		lines = append(lines, f.formatLine(line))
+	}
+	if inCodeBlock {
+		lines = append(lines, "```")
+	}
+	return f.truncate(strings.Join(lines, "\n"), url)
+}
+
+func (f *BodyFormatter) formatLine(line string) string {
+	// inline code is kept as is except escaping.
+	var codes []string
+	line = inlineCodeRegexp.ReplaceAllStringFunc(line, func(code string) string {
+		codes = append(codes, slackTextEscaper.Replace(code))
+		return "\x01"
+	})
+
+	line = slackTextEscaper.Replace(line)
+	line = f.mention(line)
+
+	if m := headingRegexp.FindStringSubmatch(line); m != nil {
+		line = boldPlaceholder + m[1] + boldPlaceholder
+	}
+	line = listItemRegexp.ReplaceAllString(line, "$1• ")
+	line = checkboxRegexp.ReplaceAllStringFunc(line, func(s string) string {
+		m := checkboxRegexp.FindStringSubmatch(s)
+		if m[2] == " " {
+			return m[1] + "☐ "
+		}
+		return m[1] + "☑ "
+	})
+	line = imageRegexp.ReplaceAllString(line, "<$2|$1>")
+	line = linkRegexp.ReplaceAllString(line, "<$2|$1>")
+	line = boldRegexp.ReplaceAllString(line, boldPlaceholder+"$2"+boldPlaceholder)
+	line = italicRegexp.ReplaceAllString(line, "${1}_${2}_")
+	line = strings.Replace(line, boldPlaceholder, "*", -1)
+	line = strikeRegexp.ReplaceAllString(line, "~$1~")
+
+	for _, code := range codes {
+		line = strings.Replace(line, "\x01", code, 1)
+	}
+	return line
+}
+
+// mention translates github @login to slack mention and neutralizes @channel, @here and @everyone.
+func (f *BodyFormatter) mention(line string) string {
+	return githubMentionRegexp.ReplaceAllStringFunc(line, func(s string) string {
+		m := githubMentionRegexp.FindStringSubmatch(s)
+		prefix, login, team := m[1], m[2], m[3]
+		if stringsContain(broadcastMentions, strings.ToLower(login)) {
+			// zero width space prevents slack from treating it as special mention.
+			return prefix + "@\u200b" + login + team
+		}
+		// team mention(@org/team) is not resolved.
+		if team != "" || f.Mention == nil {
+			return s
+		}
+		if mention, ok := f.Mention(login); ok {
+			return prefix + mention
+		}
+		return s
+	})
+}
+
+func (f *BodyFormatter) truncate(body, url string) string {
+	max := f.MaxLength
+	if max <= 0 {
+		max = maxBodyLength
+	}
+	runes := []rune(body)
+	if len(runes) <= max {
+		return body
+	}
+	truncated := string(runes[:max])
+	// do not cut links and mentions in the middle.
+	if start, end := strings.LastIndex(truncated, "<"), strings.LastIndex(truncated, ">"); start > end {
+		truncated = truncated[:start]
+	}
+	if strings.Count(truncated, "```")%2 == 1 {
+		truncated += "\n```"
+	}
+	truncated = strings.TrimRight(truncated, " \n") + "…"
+	if url != "" {
+		truncated += " <" + url + "|read more>"
+	}
+	return truncated
+}
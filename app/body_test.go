@@ -0,0 +1,78 @@
+package app_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/ymgyt/gobot/app"
+)
+
+func TestBodyFormatter_Format(t *testing.T) {
+	mention := func(login string) (string, bool) {
+		if login == "alice" {
+			return "<@U123>", true
+		}
+		return "", false
+	}
+
+	tests := map[string]struct {
+		body string
+		want string
+	}{
+		"mention":         {body: "@alice please check", want: "<@U123> please check"},
+		"unresolvable":    {body: "cc @bob", want: "cc @bob"},
+		"email":           {body: "mail to alice@example.com", want: "mail to alice@example.com"},
+		"team":            {body: "@org/alice", want: "@org/alice"},
+		"broadcast":       {body: "@channel @Here", want: "@\u200bchannel @\u200bHere"},
+		"special mention": {body: "<!channel> <!subteam^S123>", want: "&lt;!channel&gt; &lt;!subteam^S123&gt;"},
+		"heading":         {body: "## Summary", want: "*Summary*"},
+		"emphasis":        {body: "**bold** *italic* ~~strike~~", want: "*bold* _italic_ ~strike~"},
+		"link":            {body: "see [docs](https://example.com/a?b=1&c=2)", want: "see <https://example.com/a?b=1&amp;c=2|docs>"},
+		"list":            {body: "- one\n  * two\n- [x] done\n- [ ] todo", want: "• one\n  • two\n☑ done\n☐ todo"},
+		"html comment":    {body: "<!-- template -->\nfix bug", want: "fix bug"},
+		"code":            {body: "run `@alice **x**`\n```\n@alice <b>\n```", want: "run `@alice **x**`\n```\n@alice &lt;b&gt;\n```"},
+		"crlf":            {body: "a\r\nb", want: "a\nb"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := &app.BodyFormatter{Mention: mention}
+			got := f.Format(tc.body, "https://github.com/ymgyt/gobot/pull/1")
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("(-want +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestBodyFormatter_Truncate(t *testing.T) {
+	f := &app.BodyFormatter{MaxLength: 10}
+
+	got := f.Format("0123456789abc", "https://github.com/ymgyt/gobot/pull/1")
+	want := "0123456789… <https://github.com/ymgyt/gobot/pull/1|read more>"
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want +got)\n%s", diff)
+	}
+
+	// link is not cut in the middle.
+	got = f.Format("see [d](https://example.com)", "")
+	if want := "see…"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+
+	// code block is closed.
+	got = f.Format("```\n0123456789\n```", "")
+	if !strings.HasSuffix(got, "\n```…") {
+		t.Errorf("code block is not closed: %q", got)
+	}
+}
+
+func TestGithubMentions(t *testing.T) {
+	body := "@alice @bob please check\ncc @alice @org/team @here mail@example.com\n`@carol`\n```\n@dave\n```"
+	want := []string{"alice", "bob"}
+	if diff := cmp.Diff(want, app.GithubMentions(body)); diff != "" {
+		t.Errorf("(-want +got)\n%s", diff)
+	}
+}
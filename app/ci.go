@@ -0,0 +1,230 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nlopes/slack"
+	"go.uber.org/zap"
+
+	"github.com/ymgyt/gobot/log"
+)
+
+const (
+	// CIStatusSuite is Suite of commit statuses. statuses of a commit are batched together.
+	CIStatusSuite = "status"
+
+	defaultCIBatchWindow = time.Minute
+)
+
+// CIFailure is a failed check run, check suite or commit status.
+type CIFailure struct {
+	// Repository is full name of repository.
+	Repository string
+	RepoName   string
+	SHA        string
+	// Suite groups failures notified together. check suite id or CIStatusSuite.
+	Suite      string
+	Name       string
+	Conclusion string
+	URL        string
+	// Summary is failure of whole check suite. it is not listed when failures of check runs are batched.
+	Summary bool
+	Event   *GithubEvent
+}
+
+func (f *CIFailure) batchKey() string {
+	return strings.ToLower(f.Repository) + "@" + f.SHA + ":" + f.Suite
+}
+
+// IsCIFailure returns whether conclusion of check or state of status is failure.
+func IsCIFailure(conclusion string) bool {
+	switch strings.ToLower(conclusion) {
+	case "failure", "timed_out", "error":
+		return true
+	default:
+		return false
+	}
+}
+
+// CIFailureBatcher batches failures of the same suite for Window, then flushes them at once.
+// check runs of a suite usually fail together, so they are notified as one message.
+type CIFailureBatcher struct {
+	Window time.Duration
+	Flush  func([]*CIFailure)
+
+	mu      sync.Mutex
+	batches map[string][]*CIFailure
+	timers  map[string]*time.Timer
+	closed  bool
+}
+
+// Add adds failure to batch. failure of the same name in the batch is ignored.
+// after Close, failure is flushed immediately.
+func (b *CIFailureBatcher) Add(f *CIFailure) {
+	key := f.batchKey()
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		b.Flush([]*CIFailure{f})
+		return
+	}
+	defer b.mu.Unlock()
+	if b.batches == nil {
+		b.batches = make(map[string][]*CIFailure)
+		b.timers = make(map[string]*time.Timer)
+	}
+	batch, found := b.batches[key]
+	for _, failure := range batch {
+		if failure.Name == f.Name {
+			return
+		}
+	}
+	b.batches[key] = append(batch, f)
+	if !found {
+		b.timers[key] = time.AfterFunc(b.Window, func() { b.flush(key) })
+	}
+}
+
+// Close flushes pending batches without waiting for Window. batches live in memory, so they are lost unless flushed on shutdown.
+func (b *CIFailureBatcher) Close() {
+	b.mu.Lock()
+	b.closed = true
+	keys := make([]string, 0, len(b.batches))
+	for key, timer := range b.timers {
+		timer.Stop()
+		keys = append(keys, key)
+	}
+	b.mu.Unlock()
+
+	for _, key := range keys {
+		b.flush(key)
+	}
+}
+
+func (b *CIFailureBatcher) flush(key string) {
+	b.mu.Lock()
+	batch := b.batches[key]
+	delete(b.batches, key)
+	delete(b.timers, key)
+	b.mu.Unlock()
+
+	failures := make([]*CIFailure, 0, len(batch))
+	for _, f := range batch {
+		if !f.Summary {
+			failures = append(failures, f)
+		}
+	}
+	if len(failures) == 0 {
+		failures = batch
+	}
+	if len(failures) > 0 {
+		b.Flush(failures)
+	}
+}
+
+// NotifyCIFailure notifies failure to author and requested reviewers of pull requests of the commit after batching.
+func (n *Notifier) NotifyCIFailure(f *CIFailure) error {
+	n.ciFailureBatcher().Add(f)
+	return nil
+}
+
+// Close flushes batched ci failures. it is called after webhook events are drained.
+func (n *Notifier) Close() {
+	n.ciFailureBatcher().Close()
+}
+
+func (n *Notifier) ciFailureBatcher() *CIFailureBatcher {
+	n.ciOnce.Do(func() {
+		window := n.CIBatchWindow
+		if window <= 0 {
+			window = defaultCIBatchWindow
+		}
+		n.ciBatcher = &CIFailureBatcher{Window: window, Flush: n.notifyCIFailures}
+	})
+	return n.ciBatcher
+}
+
+func (n *Notifier) notifyCIFailures(failures []*CIFailure) {
+	first := failures[0]
+	if n.PullRequests == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), subscriptionLookupTimeout)
+	defer cancel()
+	prs, err := n.PullRequests.FindPullRequestsByHeadSHA(ctx, first.Repository, first.SHA)
+	if err != nil {
+		log.Error("notifier/lookup pull requests", zap.String("repository", first.Repository), zap.String("sha", first.SHA), zap.Error(err))
+		return
+	}
+	if len(prs) == 0 {
+		log.Info("notifier/no pull request for commit", zap.String("repository", first.Repository), zap.String("sha", first.SHA))
+		return
+	}
+	for _, pr := range prs {
+		if err := n.notify(n.ciFailureNotification(pr, failures)); err != nil {
+			log.Error("notifier/ci failure", zap.String("pull_request", pr.ID), zap.Error(err))
+		}
+	}
+}
+
+func (n *Notifier) ciFailureNotification(pr *PullRequest, failures []*CIFailure) *notification {
+	first := failures[0]
+	mentions := []string{}
+	for _, login := range append([]string{pr.Author}, pr.RequestedReviewers...) {
+		if mention, ok := n.slackMention(login); ok && !stringsContain(mentions, mention) {
+			mentions = append(mentions, mention)
+		}
+	}
+	lines := make([]string, len(failures))
+	for i, f := range failures {
+		name := f.Name
+		if f.URL != "" {
+			name = fmt.Sprintf("<%s|%s>", f.URL, f.Name)
+		}
+		lines[i] = fmt.Sprintf("• %s %s", name, f.Conclusion)
+	}
+
+	event := GithubEvent{}
+	if first.Event != nil {
+		event = *first.Event
+	}
+	event.Author = pr.Author
+	event.Base = pr.BaseRef
+
+	return &notification{
+		Kind:  TemplateCIFailure,
+		Event: &event,
+		Data: map[string]interface{}{
+			"Mentions":   strings.Join(mentions, " "),
+			"Author":     pr.Author,
+			"Number":     pr.Number,
+			"Title":      pr.Title,
+			"URL":        pr.URL,
+			"SHA":        shortSHA(first.SHA),
+			"Failures":   strings.Join(lines, "\n"),
+			"Count":      len(failures),
+			"RepoName":   first.RepoName,
+			"Repository": event.Repository,
+		},
+		Attachment: slack.Attachment{
+			Fallback:  "ci failed",
+			TitleLink: pr.URL,
+			Footer:    "Github webhook " + footerSuffix(),
+			Ts:        slackTimestamp(),
+			Fields:    repositoryFields(first.RepoName),
+		},
+		ThreadKey: pr.URL,
+		Reply:     true,
+	}
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
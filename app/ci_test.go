@@ -0,0 +1,82 @@
+package app_test
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/ymgyt/gobot/app"
+)
+
+func TestCIFailureBatcher(t *testing.T) {
+	flushed := make(chan []*app.CIFailure, 2)
+	b := &app.CIFailureBatcher{
+		Window: 10 * time.Millisecond,
+		Flush:  func(fs []*app.CIFailure) { flushed <- fs },
+	}
+
+	b.Add(&app.CIFailure{Repository: "ymgyt/gobot", SHA: "abc", Suite: "1", Name: "GitHub Actions", Summary: true})
+	b.Add(&app.CIFailure{Repository: "ymgyt/gobot", SHA: "abc", Suite: "1", Name: "test"})
+	b.Add(&app.CIFailure{Repository: "ymgyt/gobot", SHA: "abc", Suite: "1", Name: "test"})
+	b.Add(&app.CIFailure{Repository: "ymgyt/gobot", SHA: "abc", Suite: "1", Name: "lint"})
+	b.Add(&app.CIFailure{Repository: "ymgyt/gobot", SHA: "abc", Suite: app.CIStatusSuite, Name: "ci/circleci"})
+
+	var got [][]string
+	for i := 0; i < 2; i++ {
+		select {
+		case fs := <-flushed:
+			names := make([]string, len(fs))
+			for i := range fs {
+				names[i] = fs[i].Name
+			}
+			got = append(got, names)
+		case <-time.After(time.Second):
+			t.Fatal("batch is not flushed")
+		}
+	}
+	sort.Slice(got, func(i, j int) bool { return len(got[i]) > len(got[j]) })
+
+	want := [][]string{{"test", "lint"}, {"ci/circleci"}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want +got)\n%s", diff)
+	}
+}
+
+func TestCIFailureBatcher_Close(t *testing.T) {
+	var flushed [][]*app.CIFailure
+	b := &app.CIFailureBatcher{
+		Window: time.Hour,
+		Flush:  func(fs []*app.CIFailure) { flushed = append(flushed, fs) },
+	}
+
+	b.Add(&app.CIFailure{Repository: "ymgyt/gobot", SHA: "abc", Suite: "1", Name: "test"})
+	b.Add(&app.CIFailure{Repository: "ymgyt/gobot", SHA: "abc", Suite: "1", Name: "lint"})
+	b.Close()
+	if len(flushed) != 1 || len(flushed[0]) != 2 {
+		t.Fatalf("pending batch is not flushed on close: %v", flushed)
+	}
+
+	// failures after close are not batched because nothing flushes them.
+	b.Add(&app.CIFailure{Repository: "ymgyt/gobot", SHA: "def", Suite: "1", Name: "test"})
+	if len(flushed) != 2 || flushed[1][0].SHA != "def" {
+		t.Errorf("failure after close is not flushed: %v", flushed)
+	}
+}
+
+func TestIsCIFailure(t *testing.T) {
+	tests := map[string]bool{
+		"failure":   true,
+		"TIMED_OUT": true,
+		"error":     true,
+		"success":   false,
+		"cancelled": false,
+		"pending":   false,
+	}
+	for conclusion, want := range tests {
+		if got := app.IsCIFailure(conclusion); got != want {
+			t.Errorf("%s: want %v, got %v", conclusion, want, got)
+		}
+	}
+}
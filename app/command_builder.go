@@ -7,6 +7,7 @@ import (
 	"text/template"
 
 	"github.com/davecgh/go-spew/spew"
+	"github.com/juju/errors"
 	"github.com/ymgyt/cli"
 )
 
@@ -15,10 +16,26 @@ const (
 )
 
 type CommandBuilder struct {
-	UserStore UserStore
-
-	once     sync.Once
-	commands chan *cli.Command
+	UserStore     UserStore
+	Reconciler    *Reconciler
+	Outbox        OutboxStore
+	Subscriptions SubscriptionStore
+	Rules         RuleStore
+	Templates     TemplateStore
+
+	RepositorySettings RepositorySettingStore
+	Teams              TeamStore
+	PullRequests       PullRequestStore
+	AccountResolver    *AccountResolver
+
+	WebhookDeliveries WebhookDeliveryStore
+	WebhookReplayer   WebhookReplayer
+	// Admins are slack user ids allowed to run admin commands such as webhook replay.
+	Admins []string
+
+	once        sync.Once
+	commands    chan *cli.Command
+	importPlans userImportPlans
 }
 
 func (b *CommandBuilder) Build(sm *SlackMessage) *cli.Command {
@@ -57,7 +74,18 @@ func (b *CommandBuilder) build() *cli.Command {
 		AddCommand(NewAddCommand(b)).
 		AddCommand(NewLsCommand(b)).
 		AddCommand(NewUpdateCommand(b)).
-		AddCommand(NewDeleteCommand(b))
+		AddCommand(NewDeleteCommand(b)).
+		AddCommand(NewExportCommand(b)).
+		AddCommand(NewImportCommand(b)).
+		AddCommand(NewReconcileCommand(b)).
+		AddCommand(NewOutboxCommand(b)).
+		AddCommand(NewWebhookCommand(b)).
+		AddCommand(NewSubscribeCommand(b)).
+		AddCommand(NewUnsubscribeCommand(b)).
+		AddCommand(NewRulesCommand(b)).
+		AddCommand(NewTemplateCommand(b)).
+		AddCommand(NewRepoCommand(b)).
+		AddCommand(NewReviewsCommand(b))
 }
 
 type rootCmd struct {
@@ -84,6 +112,15 @@ func (b *CommandBuilder) setup(cmd *cli.Command, sm *SlackMessage) {
 
 type commandFunc func(context.Context, *cli.Command, []string)
 
+// requireAdmin reports whether sender of sm is admin. otherwise it replies why the command is rejected.
+func requireAdmin(admins []string, sm *SlackMessage) bool {
+	if stringsContain(admins, sm.user.ID) {
+		return true
+	}
+	sm.Fail(errors.Unauthorizedf("this command is only for admins(GOBOT_SLACK_ADMINS)"))
+	return false
+}
+
 type baseCommand struct {
 	printHelp bool
 }
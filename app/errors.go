@@ -4,6 +4,8 @@ import "github.com/juju/errors"
 
 var (
 	ErrUserNotFound = errors.New("user not found")
+	// ErrStalePullRequest is returned when saved pull request is newer than the event.
+	ErrStalePullRequest = errors.New("pull request is older than saved one")
 )
 
 func IsUserNotFound(err error) bool {
@@ -0,0 +1,81 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/juju/errors"
+	"github.com/ymgyt/cli"
+)
+
+func NewExportCommand(b *CommandBuilder) *cli.Command {
+	cmd := &cli.Command{
+		Name:      "export",
+		ShortDesc: "export resources",
+		LongDesc:  "@gobot export <OPTIONS> <RESOURCE>",
+	}
+	return cmd.AddCommand(NewExportUsersCommand(b.UserStore))
+}
+
+func NewExportUsersCommand(users UserStore) *cli.Command {
+	exportUsersCmd := &exportUsersCommand{}
+	cmd := &cli.Command{
+		Name:      "user",
+		Aliases:   []string{"users"},
+		ShortDesc: "export users",
+		LongDesc: "export users\n" +
+			"Usage: @gobot export users <OPTIONS>\n\n" +
+			"# userをcsvでexport. import usersでそのまま取り込める\n" +
+			"@gobot export users --output=csv",
+		Run: exportUsersCmd.runFunc(users),
+	}
+	if err := cmd.Options().
+		Add(&cli.BoolOpt{Var: &exportUsersCmd.printHelp, Long: "help", Description: "print help"}).
+		Add(&cli.StringOpt{Var: &exportUsersCmd.Output, Long: "output", Short: "o", Description: "file format(csv,json). default csv"}).
+		Add(&cli.BoolOpt{Var: &exportUsersCmd.All, Long: "all", Description: "include soft deleted users."}).
+		Err; err != nil {
+		panic(err)
+	}
+	return cmd
+}
+
+type exportUsersCommand struct {
+	baseCommand
+	Output string
+	All    bool
+}
+
+func (c *exportUsersCommand) runFunc(users UserStore) commandFunc {
+	return func(ctx context.Context, cmd *cli.Command, args []string) {
+		if c.printHelp {
+			cli.HelpFunc(cmd.Stdout, cmd)
+			return
+		}
+		sm := getSlackMessage(ctx)
+
+		format := c.Output
+		if format == "" {
+			format = OutputFormatCSV
+		}
+		if format != OutputFormatCSV && format != OutputFormatJSON {
+			sm.Fail(errors.Errorf("unsupported format %s. csv or json", format))
+			return
+		}
+
+		all, err := ListAllUsers(ctx, users, c.All)
+		if err != nil {
+			sm.Fail(err)
+			return
+		}
+		output, err := RenderOutput(format, all)
+		if err != nil {
+			sm.Fail(err)
+			return
+		}
+		if err := sm.Upload(output.Filename("users"), output.Filetype(), output.Content); err != nil {
+			sm.Fail(err)
+			return
+		}
+		_, _ = sm.WriteString(fmt.Sprintf("%d user(s) exported", len(all)))
+	}
+}
@@ -0,0 +1,18 @@
+package app
+
+import "context"
+
+// SendDue exposes sendDue to tests.
+func (s *OutboxSender) SendDue(ctx context.Context) {
+	s.sendDue(ctx)
+}
+
+// NotifyDirect exposes direct message path of notify to tests.
+func (n *Notifier) NotifyDirect(event *GithubEvent, channel string) error {
+	return n.notify(&notification{Kind: TemplateReviewReminder, Event: event, Channel: channel, Direct: true})
+}
+
+// RunOnce exposes a tick of scheduled reconciliation to tests.
+func (r *Reconciler) RunOnce(ctx context.Context) {
+	r.runOnce(ctx)
+}
@@ -0,0 +1,49 @@
+package app
+
+// GithubEvent is normalized fields of github webhook event. notifications are routed by it.
+type GithubEvent struct {
+	// Name is X-GitHub-Event. ex. pull_request
+	Name     string        `json:"name"`
+	Category EventCategory `json:"category,omitempty"`
+	Action   string        `json:"action,omitempty"`
+	// Repository is full name of repository(owner/repo).
+	Repository string `json:"repository"`
+	// Author is login of pull request or issue author.
+	Author string   `json:"author,omitempty"`
+	Labels []string `json:"labels,omitempty"`
+	// Base is base branch of pull request.
+	Base string `json:"base,omitempty"`
+	// Environment is environment of deployment.
+	Environment string `json:"environment,omitempty"`
+}
+
+var eventCategories = map[string]EventCategory{
+	"pull_request":                   EventCategoryPulls,
+	"pull_request_review":            EventCategoryReviews,
+	"issues":                         EventCategoryIssues,
+	"issue_comment":                  EventCategoryComments,
+	"pull_request_review_comment":    EventCategoryComments,
+	"check_run":                      EventCategoryCI,
+	"check_suite":                    EventCategoryCI,
+	"status":                         EventCategoryCI,
+	"release":                        EventCategoryReleases,
+	"create":                         EventCategoryReleases,
+	"deployment":                     EventCategoryDeployments,
+	"deployment_status":              EventCategoryDeployments,
+	"push":                           EventCategoryPushes,
+	"repository_vulnerability_alert": EventCategorySecurity,
+	"dependabot_alert":               EventCategorySecurity,
+	"security_advisory":              EventCategorySecurity,
+}
+
+// CategoryOf returns category of X-GitHub-Event. it returns empty category for unknown event.
+func CategoryOf(name string) EventCategory {
+	return eventCategories[name]
+}
+
+func (e *GithubEvent) repository() string {
+	if e == nil {
+		return ""
+	}
+	return e.Repository
+}
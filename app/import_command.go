@@ -0,0 +1,122 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/juju/errors"
+	"github.com/nlopes/slack"
+	"github.com/ymgyt/cli"
+)
+
+func NewImportCommand(b *CommandBuilder) *cli.Command {
+	cmd := &cli.Command{
+		Name:      "import",
+		ShortDesc: "import resources",
+		LongDesc:  "@gobot import <OPTIONS> <RESOURCE>",
+	}
+	return cmd.AddCommand(NewImportUsersCommand(b.UserStore, &b.importPlans))
+}
+
+func NewImportUsersCommand(users UserStore, plans *userImportPlans) *cli.Command {
+	importUsersCmd := &importUsersCommand{}
+	cmd := &cli.Command{
+		Name:      "user",
+		Aliases:   []string{"users"},
+		ShortDesc: "import users",
+		LongDesc: "import users\n" +
+			"Usage: @gobot import users (with csv or json file)\n\n" +
+			"# fileを添付してmentionするとcreate/update/skipのplanが表示される\n" +
+			"@gobot import users\n\n" +
+			"# planを確認したら適用する\n" +
+			"@gobot import users --confirm <plan_id>",
+		Run: importUsersCmd.runFunc(users, plans),
+	}
+	if err := cmd.Options().
+		Add(&cli.BoolOpt{Var: &importUsersCmd.printHelp, Long: "help", Description: "print help"}).
+		Add(&cli.StringOpt{Var: &importUsersCmd.Confirm, Long: "confirm", Description: "apply import plan"}).
+		Err; err != nil {
+		panic(err)
+	}
+	return cmd
+}
+
+type importUsersCommand struct {
+	baseCommand
+	Confirm string
+}
+
+func (c *importUsersCommand) runFunc(users UserStore, plans *userImportPlans) commandFunc {
+	return func(ctx context.Context, cmd *cli.Command, args []string) {
+		if c.printHelp {
+			cli.HelpFunc(cmd.Stdout, cmd)
+			return
+		}
+		sm := getSlackMessage(ctx)
+
+		if c.Confirm != "" {
+			c.apply(ctx, sm, users, plans)
+			return
+		}
+
+		files := sm.Files()
+		if len(files) == 0 {
+			cli.HelpFunc(cmd.Stdout, cmd)
+			return
+		}
+		content, err := sm.DownloadFile(ctx, &files[0])
+		if err != nil {
+			sm.Fail(err)
+			return
+		}
+		imported, err := ReadUsersFromFile(files[0].Name, content)
+		if err != nil {
+			sm.Fail(err)
+			return
+		}
+
+		plan, err := PlanUserImport(ctx, users, imported)
+		if err != nil {
+			sm.Fail(err)
+			return
+		}
+		plan.RequestedBy = sm.user.ID
+
+		text := fmt.Sprintf("import plan: %d create, %d update, %d skip",
+			plan.Count(UserImportCreate), plan.Count(UserImportUpdate), plan.Count(UserImportSkip))
+		attachment := slack.Attachment{
+			Fallback: text,
+			Color:    slackColorYellow,
+			Pretext:  text,
+			Text:     Literalize(plan.Summary()),
+		}
+		if plan.Count(UserImportCreate)+plan.Count(UserImportUpdate) > 0 {
+			plans.put(plan)
+			attachment.Fields = []slack.AttachmentField{{
+				Title: "to apply",
+				Value: LiteralizeLine("@gobot import users --confirm " + plan.ID),
+			}}
+		}
+		sm.PostAttachment(attachment)
+	}
+}
+
+func (c *importUsersCommand) apply(ctx context.Context, sm *SlackMessage, users UserStore, plans *userImportPlans) {
+	plan, err := plans.take(c.Confirm, sm.user.ID)
+	if err != nil {
+		sm.Fail(err)
+		return
+	}
+	if err := plan.Apply(ctx, users); err != nil {
+		sm.Fail(errors.Annotatef(err, "import plan %s partially applied", plan.ID))
+		return
+	}
+
+	text := fmt.Sprintf("users successfully imported. %d created, %d updated",
+		plan.Count(UserImportCreate), plan.Count(UserImportUpdate))
+	sm.PostAttachment(slack.Attachment{
+		Fallback: text,
+		Color:    slackColorGreen,
+		Pretext:  slackEmojiOKHand + " " + text,
+	})
+}
@@ -2,6 +2,8 @@ package app
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"text/template"
 
 	"github.com/juju/errors"
@@ -16,7 +18,33 @@ func NewLsCommand(b *CommandBuilder) *cli.Command {
 		ShortDesc: "ls resources",
 		LongDesc:  "ls resources",
 	}
-	return cmd.AddCommand(NewLsUsersCommand(b.UserStore))
+	return cmd.
+		AddCommand(NewLsUsersCommand(b.UserStore)).
+		AddCommand(NewLsSubscriptionsCommand(b.Subscriptions)).
+		AddCommand(NewLsTeamsCommand(b.Teams))
+}
+
+// lsOutput is embedded in ls sub commands to support --output.
+type lsOutput struct {
+	Output string
+}
+
+func (o *lsOutput) option() *cli.StringOpt {
+	return &cli.StringOpt{Var: &o.Output, Long: "output", Short: "o", Description: "output format(json,yaml,csv,table)."}
+}
+
+func (o *lsOutput) enabled() bool {
+	return o.Output != ""
+}
+
+// post renders v in requested format. v should implement Tabular for csv and table.
+func (o *lsOutput) post(sm *SlackMessage, resource string, v interface{}) {
+	output, err := RenderOutput(o.Output, v)
+	if err != nil {
+		sm.Fail(err)
+		return
+	}
+	sm.PostOutput(resource, output)
 }
 
 func NewLsUsersCommand(users UserStore) *cli.Command {
@@ -25,17 +53,30 @@ func NewLsUsersCommand(users UserStore) *cli.Command {
 		Name:      "user",
 		Aliases:   []string{"users"},
 		ShortDesc: "ls users",
-		LongDesc: "ls users\n\n" +
+		LongDesc: "ls users\n" +
+			"Usage: @gobot ls users <QUERY>... <OPTIONS>\n\n" +
 			"# 表示するuserの情報を指定. formatにはapp.Userがinjectされる.\n" +
-			"@gobot ls users --format=email={{.Slack.Email}}/Deleted={{.DeletedAt}}",
+			"@gobot ls users --format=email={{.Slack.Email}}/Deleted={{.DeletedAt}}\n\n" +
+			"# queryで絞り込む. name,emailは =(完全一致) ^(前方一致) ~(正規表現)\n" +
+			"@gobot ls users name^ymg email~@example\\.com$\n" +
+			"@gobot ls users created>=2019-01-01 updated-since=72h\n" +
+			"@gobot ls users deleted\n\n" +
+			"# sortとpagination. sortはname,email,created,updated. -で降順\n" +
+			"@gobot ls users --sort=-created --limit=10\n" +
+			"@gobot ls users --sort=-created --limit=10 --page <cursor>\n\n" +
+			"# json,yaml,csv,tableで出力. 大きい場合はfileとしてuploadされる\n" +
+			"@gobot ls users --output=csv",
 		Run: lsUsersCmd.runFunc(users),
 	}
 	if err := cmd.Options().
 		Add(&cli.BoolOpt{Var: &lsUsersCmd.printHelp, Long: "help", Description: "print help"}).
-		Add(&cli.IntOpt{Var: &lsUsersCmd.Limit, Long: "limit", Description: "user limit"}).
+		Add(&cli.IntOpt{Var: &lsUsersCmd.Limit, Long: "limit", Description: "users per page"}).
 		Add(&cli.BoolOpt{Var: &lsUsersCmd.All, Long: "all", Description: "include soft delted users."}).
 		Add(&cli.StringOpt{Var: &lsUsersCmd.Format, Long: "format", Description: "go template format."}).
+		Add(&cli.StringOpt{Var: &lsUsersCmd.Sort, Long: "sort", Description: "sort field(name,email,created,updated). prefix - for descending order."}).
+		Add(&cli.StringOpt{Var: &lsUsersCmd.Page, Long: "page", Description: "page cursor"}).
 		Add(&cli.BoolOpt{Var: &lsUsersCmd.Verbose, Long: "verbose", Short: "v", Description: "show full user information"}).
+		Add(lsUsersCmd.option()).
 		Err; err != nil {
 		panic(err)
 	}
@@ -45,13 +86,17 @@ func NewLsUsersCommand(users UserStore) *cli.Command {
 // nolint:maligned
 type lsUsersCommand struct {
 	baseCommand
+	lsOutput
 	Limit   int
 	Format  string
+	Sort    string
+	Page    string
 	All     bool
 	Verbose bool
 }
 
 // @gobot ls users --format email={{.Slack.Email}}/D={{.DeletedAt}}
+// @gobot ls users name^ymg created>2019-01-01 --sort=-created --page <cursor>
 func (c *lsUsersCommand) runFunc(users UserStore) commandFunc {
 	return func(ctx context.Context, cmd *cli.Command, args []string) {
 		if c.printHelp {
@@ -65,41 +110,77 @@ func (c *lsUsersCommand) runFunc(users UserStore) commandFunc {
 			return
 		}
 
-		var filter *User
-		if len(args) > 0 {
-			filter, err = ReadUserFromArgs(args)
-			if err != nil {
-				sm.Fail(err)
-				return
-			}
-		}
-
-		users, err := users.FindUsers(ctx, &FindUsersInput{
+		input := &ListUsersInput{
 			Limit:          int64(c.Limit),
-			Filter:         filter,
+			Sort:           c.Sort,
+			Cursor:         c.Page,
 			IncludeDeleted: c.All,
-		})
+		}
+		// 後方互換のためjsonのfilterも受け付ける
+		if len(args) > 0 && strings.HasPrefix(args[0], "{") {
+			input.Filter, err = ReadUserFromArgs(args)
+		} else {
+			input.Query, err = ParseUserQuery(args, Now())
+		}
 		if err != nil {
 			sm.Fail(err)
 			return
 		}
 
-		text := "user found"
-		if len(users) == 0 {
+		output, err := users.ListUsers(ctx, input)
+		if err != nil {
+			sm.Fail(err)
+			return
+		}
+
+		if c.enabled() {
+			if output.Users == nil {
+				output.Users = Users{}
+			}
+			c.post(sm, "users", output.Users)
+			if output.NextCursor != "" {
+				_, _ = sm.WriteString("next page: " + LiteralizeLine(c.nextPageCommand(args, output.NextCursor)))
+			}
+			return
+		}
+
+		text := fmt.Sprintf("%d user(s) found", len(output.Users))
+		if len(output.Users) == 0 {
 			text = "user not found"
 		}
 
-		fields, err := users.SlackAttachmentFields(tmpl)
+		fields, err := output.Users.SlackAttachmentFields(tmpl)
 		if err != nil {
 			sm.Fail(err)
 			return
 		}
-		sm.PostAttachment(slack.Attachment{
+		attachment := slack.Attachment{
 			Fallback: text,
 			Color:    slackColorGreen,
 			Fields:   fields,
-		})
+		}
+		if output.NextCursor != "" {
+			attachment.Text = "next page: " + LiteralizeLine(c.nextPageCommand(args, output.NextCursor))
+		}
+		sm.PostAttachment(attachment)
+	}
+}
+
+func (c *lsUsersCommand) nextPageCommand(args []string, cursor string) string {
+	cmd := append([]string{"@gobot ls users"}, args...)
+	if c.Sort != "" {
+		cmd = append(cmd, "--sort="+c.Sort)
+	}
+	if c.Limit > 0 {
+		cmd = append(cmd, fmt.Sprintf("--limit=%d", c.Limit))
+	}
+	if c.All {
+		cmd = append(cmd, "--all")
+	}
+	if c.enabled() {
+		cmd = append(cmd, "--output="+c.Output)
 	}
+	return strings.Join(append(cmd, "--page", cursor), " ")
 }
 
 func (c *lsUsersCommand) template() (*template.Template, error) {
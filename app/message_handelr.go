@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"context"
 	"io"
+	"io/ioutil"
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/juju/errors"
 	"github.com/nlopes/slack"
@@ -24,8 +27,12 @@ func (h *MessageHandler) Handle(sm *SlackMessage) {
 	h.CommandBuilder.Build(sm).ExecuteWithArgs(ctx, h.readArgs(sm))
 }
 
+// slack escapes these characters in message text.
+// see https://api.slack.com/docs/message-formatting#how_to_escape_characters
+var slackTextUnescaper = strings.NewReplacer("&lt;", "<", "&gt;", ">", "&amp;", "&")
+
 func (h *MessageHandler) readArgs(sm *SlackMessage) []string {
-	args := strings.Fields(sm.event.Msg.Text)
+	args := strings.Fields(slackTextUnescaper.Replace(sm.event.Msg.Text))
 	normalized := make([]string, 0, len(args))
 	for _, arg := range args {
 		if arg == "" {
@@ -40,6 +47,15 @@ func (h *MessageHandler) readArgs(sm *SlackMessage) []string {
 	return normalized
 }
 
+const (
+	maxDownloadFileSize = 1 << 20
+	downloadFileTimeout = 30 * time.Second
+	slackPostTimeout    = 10 * time.Second
+)
+
+// downloadClient gives up stalled file downloads instead of blocking the handler.
+var downloadClient = &http.Client{Timeout: downloadFileTimeout}
+
 type slackMessageContextKeyType string
 
 var slackMessageContextKey slackMessageContextKeyType = "slackMessage"
@@ -56,7 +72,10 @@ type SlackMessage struct {
 	event    *slack.MessageEvent
 	user     *slack.User
 	client   *slack.Client
+	token    string
 	isDirect bool
+	renderer SlackRenderer
+	poster   *BlockPoster
 }
 
 func (sm *SlackMessage) Write(msg []byte) (int, error) {
@@ -73,9 +92,91 @@ func (sm *SlackMessage) PostAttachment(attachment slack.Attachment) {
 		attachment.Ts = slackTimestamp()
 	}
 	attachment.Footer += footerSuffix()
+	if sm.renderer == RenderBlocks {
+		sm.postBlocks(attachment)
+		return
+	}
+	sm.post(sm.event.Channel, slack.MsgOptionAttachments(attachment))
+}
+
+// postBlocks posts attachment as blocks. attachment is posted as is when blocks are rejected, so the reply is not dropped.
+// other errors are not retried because slack may have accepted the message or asks to wait.
+func (sm *SlackMessage) postBlocks(attachment slack.Attachment) {
+	msg, err := NewBlockMessage("", attachment)
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), slackPostTimeout)
+		defer cancel()
+		_, _, err = sm.poster.PostMessage(ctx, sm.event.Channel, msg)
+		if err == nil {
+			return
+		}
+		if !IsBlockError(err) {
+			log.Error("post slack blocks", zap.String("channel", sm.event.Channel), zap.Error(err))
+			return
+		}
+	}
+	log.Warn("post slack blocks. fall back to attachments", zap.Error(err))
 	sm.post(sm.event.Channel, slack.MsgOptionAttachments(attachment))
 }
 
+// PostOutput posts rendered ls result. large output is uploaded as a file snippet.
+func (sm *SlackMessage) PostOutput(resource string, output *Output) {
+	if !output.shouldUpload() {
+		sm.post(sm.event.Channel, slack.MsgOptionText(Literalize(output.Content), false))
+		return
+	}
+	if err := sm.Upload(output.Filename(resource), output.Filetype(), output.Content); err != nil {
+		log.Warn("upload slack file", zap.Error(err))
+	}
+}
+
+// Upload uploads content as a file to the channel where message was posted.
+func (sm *SlackMessage) Upload(filename, filetype, content string) error {
+	_, err := sm.client.UploadFile(slack.FileUploadParameters{
+		Content:  content,
+		Filetype: filetype,
+		Filename: filename,
+		Title:    filename,
+		Channels: []string{sm.event.Channel},
+	})
+	return errors.Trace(err)
+}
+
+// Files returns files attached to the message.
+func (sm *SlackMessage) Files() []slack.File {
+	return sm.event.Msg.Files
+}
+
+// DownloadFile downloads a file attached to the message. bot token is required to access private url.
+func (sm *SlackMessage) DownloadFile(ctx context.Context, file *slack.File) ([]byte, error) {
+	if file.Size > maxDownloadFileSize {
+		return nil, errors.Errorf("file %s is too large. max %d bytes", file.Name, maxDownloadFileSize)
+	}
+	req, err := http.NewRequest(http.MethodGet, file.URLPrivateDownload, nil)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+sm.token)
+
+	res, err := downloadClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Annotatef(err, "file=%s", file.Name)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("failed to download file %s. status=%d", file.Name, res.StatusCode)
+	}
+	// reported size can not be trusted. read one more byte to detect larger body.
+	b, err := ioutil.ReadAll(io.LimitReader(res.Body, maxDownloadFileSize+1))
+	if err != nil {
+		return nil, errors.Annotatef(err, "file=%s", file.Name)
+	}
+	if len(b) > maxDownloadFileSize {
+		return nil, errors.Errorf("file %s is too large. max %d bytes", file.Name, maxDownloadFileSize)
+	}
+	return b, nil
+}
+
 func (sm *SlackMessage) Fail(err error) {
 	msg := errors.ErrorStack(err)
 	sm.post(sm.event.Channel, slack.MsgOptionText(msg, false))
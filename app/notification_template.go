@@ -0,0 +1,558 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+// TemplateKind identifies notification which templates are applied to.
+type TemplateKind string
+
+const (
+	TemplateReviewRequested TemplateKind = "review_requested"
+	TemplateReviewSubmitted TemplateKind = "review_submitted"
+	TemplateIssue           TemplateKind = "issue"
+	TemplateComment         TemplateKind = "comment"
+	TemplateCIFailure       TemplateKind = "ci_failure"
+	TemplateRelease         TemplateKind = "release"
+	TemplateTag             TemplateKind = "tag"
+	TemplateDeployment      TemplateKind = "deployment"
+	TemplatePush            TemplateKind = "push"
+	TemplateSecurityAlert   TemplateKind = "security_alert"
+
+	TemplateReviewDismissed TemplateKind = "review_dismissed"
+	// TemplateReviewRequestRemoved is notified when review request is withdrawn.
+	TemplateReviewRequestRemoved TemplateKind = "review_request_removed"
+	// TemplateReviewReminder is notified when review request is left pending.
+	TemplateReviewReminder TemplateKind = "review_reminder"
+)
+
+// TemplateKinds -
+var TemplateKinds = []TemplateKind{
+	TemplateReviewRequested,
+	TemplateReviewSubmitted,
+	TemplateReviewDismissed,
+	TemplateReviewRequestRemoved,
+	TemplateReviewReminder,
+	TemplateIssue,
+	TemplateComment,
+	TemplateCIFailure,
+	TemplateRelease,
+	TemplateTag,
+	TemplateDeployment,
+	TemplatePush,
+	TemplateSecurityAlert,
+}
+
+// TemplateFields are customizable fields of notification.
+// emoji and color are rendered first, so that other fields can refer them as .Emoji and .Color.
+var TemplateFields = []string{"emoji", "color", "pretext", "title", "text"}
+
+// ParseTemplateKind -
+func ParseTemplateKind(s string) (TemplateKind, error) {
+	for _, kind := range TemplateKinds {
+		if TemplateKind(s) == kind {
+			return kind, nil
+		}
+	}
+	kinds := make([]string, len(TemplateKinds))
+	for i := range TemplateKinds {
+		kinds[i] = string(TemplateKinds[i])
+	}
+	return "", errors.Errorf("unknown template %q. available templates are %s", s, strings.Join(kinds, ","))
+}
+
+// NotificationTemplate holds go templates of notification fields.
+// empty Repository or Channel means the template applies to any repository or channel.
+// empty field falls back to less specific template.
+type NotificationTemplate struct {
+	Kind       TemplateKind      `json:"kind" bson:"kind"`
+	Repository string            `json:"repository,omitempty" bson:"repository"`
+	Channel    string            `json:"channel,omitempty" bson:"channel"`
+	Fields     map[string]string `json:"fields" bson:"fields"`
+	UpdatedBy  string            `json:"updated_by,omitempty" bson:"updated_by,omitempty"`
+	UpdatedAt  time.Time         `json:"updated_at" bson:"updated_at"`
+}
+
+// DefaultNotificationTemplates reproduce built-in notifications.
+var DefaultNotificationTemplates = map[TemplateKind]*NotificationTemplate{
+	TemplateReviewRequested: {
+		Kind: TemplateReviewRequested,
+		Fields: map[string]string{
+			"emoji":   ":point_right:",
+			"color":   slackColorGreen,
+			"pretext": "{{.Emoji}} {{.ReviewerMentions}} {{if .ReRequested}}ready for another look{{else}}your review is requested{{end}}",
+			"title":   "{{.Title}}",
+			"text":    "{{if .ReRequested}}{{.PreviousReviews}}{{else}}{{.Body}}{{end}}",
+		},
+	},
+	TemplateReviewSubmitted: {
+		Kind: TemplateReviewSubmitted,
+		Fields: map[string]string{
+			"emoji": `{{$s := lower .ReviewState}}` +
+				`{{if eq $s "commented"}}` + slackEmojiWritingHand +
+				`{{else if eq $s "changes_requested"}}` + slackEmojiPointUp +
+				`{{else if eq $s "approved"}}` + slackEmojiOKHand +
+				`{{else}}` + slackEmojiMiddleFinger + `{{end}}`,
+			"color": `{{$s := lower .ReviewState}}` +
+				`{{if eq $s "commented"}}` + slackColorGray +
+				`{{else if eq $s "changes_requested"}}` + slackColorYellow +
+				`{{else if eq $s "approved"}}` + slackColorGreen +
+				`{{else}}` + slackColorRed + `{{end}}`,
+			"pretext": "{{.Emoji}} {{.OwnerMention}} your PR *{{.ReviewState}}*",
+			"title":   "PR ({{.Title}}) review",
+			"text":    "{{.ReviewBody}}",
+		},
+	},
+	TemplateReviewDismissed: {
+		Kind: TemplateReviewDismissed,
+		Fields: map[string]string{
+			"emoji":   ":no_entry_sign:",
+			"color":   slackColorGray,
+			"pretext": "{{.Emoji}} {{.ReviewerMention}} your review was dismissed by {{.DismissedBy}}",
+			"title":   "#{{.Number}} {{.Title}}",
+			"text":    "",
+		},
+	},
+	TemplateReviewRequestRemoved: {
+		Kind: TemplateReviewRequestRemoved,
+		Fields: map[string]string{
+			"emoji":   ":wave:",
+			"color":   slackColorGray,
+			"pretext": "{{.Emoji}} {{.ReviewerMention}} review request was removed by {{.RemovedBy}}. you can stop reviewing",
+			"title":   "#{{.Number}} {{.Title}}",
+			"text":    "",
+		},
+	},
+	TemplateReviewReminder: {
+		Kind: TemplateReviewReminder,
+		Fields: map[string]string{
+			"emoji": `{{if .Escalated}}:rotating_light:{{else}}:alarm_clock:{{end}}`,
+			"color": `{{if .Escalated}}` + slackColorRed + `{{else}}` + slackColorYellow + `{{end}}`,
+			"pretext": `{{.Emoji}} {{if .Escalated}}review of #{{.Number}} by {{.ReviewerMention}} has been pending for {{.Hours}} working hours {{.AuthorMention}}` +
+				`{{else}}{{.ReviewerMention}} your review has been requested for {{.Hours}} working hours{{end}}`,
+			"title": "#{{.Number}} {{.Title}}",
+			"text":  "",
+		},
+	},
+	TemplateIssue: {
+		Kind: TemplateIssue,
+		Fields: map[string]string{
+			"emoji":   ":memo:",
+			"color":   `{{if eq .Action "closed"}}` + slackColorGray + `{{else}}` + slackColorGreen + `{{end}}`,
+			"pretext": "{{.Emoji}} issue *{{.Action}}*",
+			"title":   "#{{.Number}} {{.Title}}",
+			"text":    "{{.Body}}",
+		},
+	},
+	TemplateComment: {
+		Kind: TemplateComment,
+		Fields: map[string]string{
+			"emoji":   ":speech_balloon:",
+			"color":   slackColorGray,
+			"pretext": "{{.Emoji}} {{.Mentions}} *{{.Commenter}}* commented on #{{.Number}}",
+			"title":   "#{{.Number}} {{.Title}}",
+			"text":    "{{.Body}}",
+		},
+	},
+	TemplateCIFailure: {
+		Kind: TemplateCIFailure,
+		Fields: map[string]string{
+			"emoji":   ":rotating_light:",
+			"color":   slackColorRed,
+			"pretext": "{{.Emoji}} {{.Mentions}} CI failed on #{{.Number}} ({{.SHA}})",
+			"title":   "#{{.Number}} {{.Title}}",
+			"text":    "{{.Failures}}",
+		},
+	},
+	TemplateRelease: {
+		Kind: TemplateRelease,
+		Fields: map[string]string{
+			"emoji":   ":rocket:",
+			"color":   slackColorGreen,
+			"pretext": "{{.Emoji}} *{{.RepoName}}* {{.TagName}} released{{if .Prerelease}} (pre-release){{end}}",
+			"title":   "{{if .Name}}{{.Name}}{{else}}{{.TagName}}{{end}}",
+			"text":    "{{.Body}}",
+		},
+	},
+	TemplateTag: {
+		Kind: TemplateTag,
+		Fields: map[string]string{
+			"emoji":   ":label:",
+			"color":   slackColorGray,
+			"pretext": "{{.Emoji}} tag *{{.Tag}}* created in *{{.RepoName}}*",
+			"title":   "{{.Tag}}",
+			"text":    "",
+		},
+	},
+	TemplateDeployment: {
+		Kind: TemplateDeployment,
+		Fields: map[string]string{
+			"emoji": `{{if eq .State "success"}}:white_check_mark:` +
+				`{{else if or (eq .State "failure") (eq .State "error")}}:x:` +
+				`{{else if eq .State "inactive"}}:zzz:` +
+				`{{else}}:hourglass_flowing_sand:{{end}}`,
+			"color": `{{if eq .State "success"}}` + slackColorGreen +
+				`{{else if or (eq .State "failure") (eq .State "error")}}` + slackColorRed +
+				`{{else if eq .State "inactive"}}` + slackColorGray +
+				`{{else}}` + slackColorYellow + `{{end}}`,
+			"pretext": "{{.Emoji}} deploy *{{.Ref}}* ({{.SHA}}) to *{{.Environment}}* {{.State}}",
+			"title":   "{{.RepoName}} {{.Environment}}",
+			"text":    "{{.Description}}",
+		},
+	},
+	TemplatePush: {
+		Kind: TemplatePush,
+		Fields: map[string]string{
+			"emoji": `{{if .Alert}}:rotating_light:{{else}}:arrow_up:{{end}}`,
+			"color": `{{if .Alert}}` + slackColorRed + `{{else}}` + slackColorGreen + `{{end}}`,
+			"pretext": `{{.Emoji}} {{if .Deleted}}*{{.Branch}}* was deleted by {{.Pusher}}` +
+				`{{else if .Forced}}*force-pushed* to *{{.Branch}}* by {{.Pusher}}` +
+				`{{else}}{{.Pusher}} pushed {{.Count}} commit(s) to *{{.Branch}}*{{end}}`,
+			"title": "{{.RepoName}}:{{.Branch}}",
+			"text":  "{{.Commits}}",
+		},
+	},
+	TemplateSecurityAlert: {
+		Kind: TemplateSecurityAlert,
+		Fields: map[string]string{
+			"emoji": `{{if eq .State "open"}}:rotating_light:{{else}}:white_check_mark:{{end}}`,
+			"color": `{{if ne .State "open"}}` + slackColorGray +
+				`{{else if eq .Severity "critical"}}` + slackColorMaroon +
+				`{{else if eq .Severity "high"}}` + slackColorRed +
+				`{{else if or (eq .Severity "moderate") (eq .Severity "medium")}}` + slackColorOrange +
+				`{{else}}` + slackColorYellow + `{{end}}`,
+			"pretext": `{{.Emoji}} {{if eq .State "open"}}{{if .Mentions}}{{.Mentions}} {{end}}*{{.Severity}}* vulnerability in *{{.Package}}*` +
+				`{{else}}vulnerability in *{{.Package}}* {{.State}}{{if .DismissedBy}} by {{.DismissedBy}}{{end}}{{end}}`,
+			"title": "{{if .Identifier}}{{.Identifier}} {{end}}{{.Summary}}",
+			"text": "{{if .VulnerableRange}}affected: {{.VulnerableRange}}\n{{end}}" +
+				"{{if .PatchedVersion}}patched: {{.PatchedVersion}}\n{{end}}" +
+				"{{if .Manifest}}manifest: {{.Manifest}}\n{{end}}" +
+				"{{if .DismissReason}}reason: {{.DismissReason}}{{end}}",
+		},
+	},
+}
+
+var notificationTemplateFuncs = template.FuncMap{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"join":  strings.Join,
+}
+
+// ValidateTemplate parses field template and renders it with sample data.
+func ValidateTemplate(kind TemplateKind, field, text string) error {
+	if !stringsContain(TemplateFields, field) {
+		return errors.Errorf("unknown field %q. available fields are %s", field, strings.Join(TemplateFields, ","))
+	}
+	data := SampleTemplateData(kind)
+	data["Emoji"], data["Color"] = ":ok_hand:", slackColorGreen
+	_, err := renderTemplate(field, text, data)
+	return errors.Trace(err)
+}
+
+func renderTemplate(name, text string, data map[string]interface{}) (string, error) {
+	tmpl, err := template.New(name).Funcs(notificationTemplateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", errors.Annotatef(err, "parse %s template", name)
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", errors.Annotatef(err, "execute %s template", name)
+	}
+	return b.String(), nil
+}
+
+// NotificationTemplates -
+type NotificationTemplates []*NotificationTemplate
+
+// Header implements Tabular.
+func (ts NotificationTemplates) Header() []string {
+	return []string{"kind", "repository", "channel", "field", "template"}
+}
+
+// Rows implements Tabular.
+func (ts NotificationTemplates) Rows() [][]string {
+	var rows [][]string
+	for _, t := range ts {
+		for _, field := range TemplateFields {
+			if text, ok := t.Fields[field]; ok {
+				rows = append(rows, []string{string(t.Kind), t.Repository, t.Channel, field, text})
+			}
+		}
+	}
+	return rows
+}
+
+// Resolve merges templates applicable to repository and channel onto default template.
+// more specific template wins. repository and channel > channel > repository > default.
+// channel is channel id. templates and notifier resolve channels with ResolveSlackChannelID.
+func (ts NotificationTemplates) Resolve(kind TemplateKind, repository, channel string) map[string]string {
+	fields := make(map[string]string)
+	for field, text := range DefaultNotificationTemplates[kind].Fields {
+		fields[field] = text
+	}
+	score := func(t *NotificationTemplate) int {
+		s := 0
+		if t.Repository != "" {
+			s++
+		}
+		if t.Channel != "" {
+			s += 2
+		}
+		return s
+	}
+	for level := 0; level <= 3; level++ {
+		for _, t := range ts {
+			if t.Kind != kind || score(t) != level {
+				continue
+			}
+			if t.Repository != "" && !strings.EqualFold(t.Repository, repository) {
+				continue
+			}
+			if t.Channel != "" && t.Channel != channel {
+				continue
+			}
+			for field, text := range t.Fields {
+				if text != "" {
+					fields[field] = text
+				}
+			}
+		}
+	}
+	return fields
+}
+
+// RenderedTemplate is notification fields rendered from templates.
+type RenderedTemplate struct {
+	Emoji   string
+	Color   string
+	Pretext string
+	Title   string
+	Text    string
+}
+
+// RenderTemplate renders fields with data. field which fails to render falls back to default template.
+func RenderTemplate(kind TemplateKind, fields map[string]string, data map[string]interface{}) (*RenderedTemplate, error) {
+	var errs []string
+	render := func(field string) string {
+		out, err := renderTemplate(field, fields[field], data)
+		if err == nil {
+			return out
+		}
+		errs = append(errs, err.Error())
+		out, _ = renderTemplate(field, DefaultNotificationTemplates[kind].Fields[field], data)
+		return out
+	}
+
+	r := &RenderedTemplate{}
+	r.Emoji = render("emoji")
+	data["Emoji"] = r.Emoji
+	r.Color = strings.TrimSpace(render("color"))
+	data["Color"] = r.Color
+	r.Pretext = render("pretext")
+	r.Title = render("title")
+	r.Text = render("text")
+
+	if len(errs) > 0 {
+		return r, errors.New(strings.Join(errs, ", "))
+	}
+	return r, nil
+}
+
+// SampleTemplateData returns data to preview templates.
+func SampleTemplateData(kind TemplateKind) map[string]interface{} {
+	switch kind {
+	case TemplateReviewRequested:
+		return map[string]interface{}{
+			"Owner":              "octocat",
+			"OwnerMention":       "<@octocat>",
+			"URL":                "https://github.com/ymgyt/gobot/pull/1",
+			"Title":              "Add notification templates",
+			"Body":               "this PR adds notification templates.",
+			"RepoName":           "gobot",
+			"Repository":         "ymgyt/gobot",
+			"RequestedReviewers": []string{"ymgyt"},
+			"RequestedTeams":     []string{"backend"},
+			"ReviewerMentions":   "<@ymgyt> <!subteam^S0123>",
+			"ReRequested":        false,
+			"PreviousReviews":    "",
+		}
+	case TemplateReviewSubmitted:
+		return map[string]interface{}{
+			"Owner":        "octocat",
+			"OwnerMention": "<@octocat>",
+			"Title":        "Add notification templates",
+			"RepoName":     "gobot",
+			"Repository":   "ymgyt/gobot",
+			"Reviewer":     "ymgyt",
+			"ReviewBody":   "LGTM",
+			"ReviewState":  "approved",
+			"ReviewURL":    "https://github.com/ymgyt/gobot/pull/1#pullrequestreview-1",
+		}
+	case TemplateReviewDismissed:
+		return map[string]interface{}{
+			"Reviewer":        "ymgyt",
+			"ReviewerMention": "<@ymgyt>",
+			"DismissedBy":     "octocat",
+			"Number":          int64(1),
+			"Title":           "Add notification templates",
+			"URL":             "https://github.com/ymgyt/gobot/pull/1",
+			"ReviewURL":       "https://github.com/ymgyt/gobot/pull/1#pullrequestreview-1",
+			"ReviewState":     "changes_requested",
+			"RepoName":        "gobot",
+			"Repository":      "ymgyt/gobot",
+		}
+	case TemplateReviewRequestRemoved:
+		return map[string]interface{}{
+			"Reviewer":        "ymgyt",
+			"Team":            "",
+			"ReviewerMention": "<@ymgyt>",
+			"RemovedBy":       "octocat",
+			"Number":          int64(1),
+			"Title":           "Add notification templates",
+			"URL":             "https://github.com/ymgyt/gobot/pull/1",
+			"RepoName":        "gobot",
+			"Repository":      "ymgyt/gobot",
+		}
+	case TemplateReviewReminder:
+		return map[string]interface{}{
+			"Reviewer":        "ymgyt",
+			"ReviewerMention": "<@ymgyt>",
+			"Author":          "octocat",
+			"AuthorMention":   "<@octocat>",
+			"Hours":           8,
+			"Escalated":       false,
+			"Number":          int64(1),
+			"Title":           "Add notification templates",
+			"URL":             "https://github.com/ymgyt/gobot/pull/1",
+			"Repository":      "ymgyt/gobot",
+		}
+	case TemplateIssue:
+		return map[string]interface{}{
+			"Action":     "opened",
+			"Author":     "octocat",
+			"Number":     int64(1),
+			"Title":      "Notification is too noisy",
+			"Body":       "please make it quiet.",
+			"URL":        "https://github.com/ymgyt/gobot/issues/1",
+			"RepoName":   "gobot",
+			"Repository": "ymgyt/gobot",
+		}
+	case TemplateComment:
+		return map[string]interface{}{
+			"Commenter":     "ymgyt",
+			"Author":        "octocat",
+			"AuthorMention": "<@octocat>",
+			"Mentions":      "<@octocat>",
+			"Number":        int64(1),
+			"Title":         "Add notification templates",
+			"Body":          "nit: typo",
+			"URL":           "https://github.com/ymgyt/gobot/pull/1#discussion_r1",
+			"PullRequest":   true,
+			"RepoName":      "gobot",
+			"Repository":    "ymgyt/gobot",
+		}
+	case TemplateCIFailure:
+		return map[string]interface{}{
+			"Mentions":   "<@octocat> <@ymgyt>",
+			"Author":     "octocat",
+			"Number":     int64(1),
+			"Title":      "Add notification templates",
+			"URL":        "https://github.com/ymgyt/gobot/pull/1",
+			"SHA":        "6d456b6",
+			"Failures":   "• <https://github.com/ymgyt/gobot/runs/1|test> failure",
+			"Count":      1,
+			"RepoName":   "gobot",
+			"Repository": "ymgyt/gobot",
+		}
+	case TemplateRelease:
+		return map[string]interface{}{
+			"Author":     "ymgyt",
+			"TagName":    "v1.0.0",
+			"Name":       "v1.0.0",
+			"Body":       "*Features*\n• notification templates",
+			"URL":        "https://github.com/ymgyt/gobot/releases/tag/v1.0.0",
+			"Prerelease": false,
+			"RepoName":   "gobot",
+			"Repository": "ymgyt/gobot",
+		}
+	case TemplateTag:
+		return map[string]interface{}{
+			"Sender":     "ymgyt",
+			"Tag":        "v1.0.0",
+			"URL":        "https://github.com/ymgyt/gobot/tree/v1.0.0",
+			"RepoName":   "gobot",
+			"Repository": "ymgyt/gobot",
+		}
+	case TemplateDeployment:
+		return map[string]interface{}{
+			"ID":             int64(1),
+			"Environment":    "production",
+			"Ref":            "master",
+			"SHA":            "6d456b6",
+			"State":          "success",
+			"Description":    "deployed by ci",
+			"Creator":        "ymgyt",
+			"URL":            "https://github.com/ymgyt/gobot/actions/runs/1",
+			"EnvironmentURL": "https://gobot.example.com",
+			"RepoName":       "gobot",
+			"Repository":     "ymgyt/gobot",
+		}
+	case TemplatePush:
+		return map[string]interface{}{
+			"Branch":     "master",
+			"Pusher":     "ymgyt",
+			"Forced":     false,
+			"Deleted":    false,
+			"Alert":      false,
+			"Count":      1,
+			"Commits":    "• <https://github.com/ymgyt/gobot/commit/6d456b6|6d456b6> Add push alerts - ymgyt",
+			"CompareURL": "https://github.com/ymgyt/gobot/compare/6d456b6...e8f0a14",
+			"RepoName":   "gobot",
+			"Repository": "ymgyt/gobot",
+		}
+	case TemplateSecurityAlert:
+		return map[string]interface{}{
+			"Mentions":        "<@ymgyt>",
+			"Action":          "created",
+			"State":           "open",
+			"Severity":        "high",
+			"Ecosystem":       "go",
+			"Package":         "golang.org/x/net",
+			"VulnerableRange": "< 0.7.0",
+			"PatchedVersion":  "0.7.0",
+			"Identifier":      "GHSA-vvpx-j8f3-3w6h",
+			"Summary":         "Uncontrolled Resource Consumption",
+			"Manifest":        "go.mod",
+			"URL":             "https://github.com/ymgyt/gobot/security/dependabot/1",
+			"DismissedBy":     "",
+			"DismissReason":   "",
+			"RepoName":        "gobot",
+			"Repository":      "ymgyt/gobot",
+		}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// TemplateStore persists notification templates.
+type TemplateStore interface {
+	// SetTemplate sets field template of kind, repository and channel.
+	SetTemplate(ctx context.Context, t *NotificationTemplate, field, text string) error
+	ListTemplates(ctx context.Context, kind TemplateKind) (NotificationTemplates, error)
+	DeleteTemplate(ctx context.Context, kind TemplateKind, repository, channel string) error
+}
+
+func (t *NotificationTemplate) String() string {
+	scope := "default"
+	if t.Repository != "" || t.Channel != "" {
+		scope = fmt.Sprintf("repository=%s channel=%s", t.Repository, t.Channel)
+	}
+	return string(t.Kind) + " " + scope
+}
@@ -0,0 +1,132 @@
+package app_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/ymgyt/gobot/app"
+)
+
+func TestRenderTemplate_Default(t *testing.T) {
+	tests := map[string]struct {
+		kind app.TemplateKind
+		data map[string]interface{}
+		want *app.RenderedTemplate
+	}{
+		"review approved": {
+			kind: app.TemplateReviewSubmitted,
+			data: map[string]interface{}{"OwnerMention": "<@U1>", "Title": "fix", "ReviewBody": "LGTM", "ReviewState": "approved"},
+			want: &app.RenderedTemplate{Emoji: ":ok_hand:", Color: "#2cbe4e", Pretext: ":ok_hand: <@U1> your PR *approved*", Title: "PR (fix) review", Text: "LGTM"},
+		},
+		"review changes requested": {
+			kind: app.TemplateReviewSubmitted,
+			data: map[string]interface{}{"OwnerMention": "<@U1>", "Title": "fix", "ReviewBody": "", "ReviewState": "CHANGES_REQUESTED"},
+			want: &app.RenderedTemplate{Emoji: ":point_up:", Color: "#dbab09", Pretext: ":point_up: <@U1> your PR *CHANGES_REQUESTED*", Title: "PR (fix) review"},
+		},
+		"issue closed": {
+			kind: app.TemplateIssue,
+			data: map[string]interface{}{"Action": "closed", "Number": int64(3), "Title": "bug", "Body": "detail"},
+			want: &app.RenderedTemplate{Emoji: ":memo:", Color: "#586069", Pretext: ":memo: issue *closed*", Title: "#3 bug", Text: "detail"},
+		},
+		"comment": {
+			kind: app.TemplateComment,
+			data: map[string]interface{}{"Mentions": "<@U1>", "Commenter": "ymgyt", "Number": int64(3), "Title": "fix", "Body": "nit"},
+			want: &app.RenderedTemplate{Emoji: ":speech_balloon:", Color: "#586069", Pretext: ":speech_balloon: <@U1> *ymgyt* commented on #3", Title: "#3 fix", Text: "nit"},
+		},
+		"deployment failure": {
+			kind: app.TemplateDeployment,
+			data: map[string]interface{}{"State": "failure", "Ref": "master", "SHA": "6d456b6", "Environment": "production", "RepoName": "gobot", "Description": "timeout"},
+			want: &app.RenderedTemplate{Emoji: ":x:", Color: "#cb2431", Pretext: ":x: deploy *master* (6d456b6) to *production* failure", Title: "gobot production", Text: "timeout"},
+		},
+		"force push": {
+			kind: app.TemplatePush,
+			data: map[string]interface{}{"Alert": true, "Forced": true, "Deleted": false, "Branch": "master", "Pusher": "ymgyt", "RepoName": "gobot", "Commits": ""},
+			want: &app.RenderedTemplate{Emoji: ":rotating_light:", Color: "#cb2431", Pretext: ":rotating_light: *force-pushed* to *master* by ymgyt", Title: "gobot:master"},
+		},
+		"deployment in progress": {
+			kind: app.TemplateDeployment,
+			data: map[string]interface{}{"State": "in_progress", "Ref": "v1.0.0", "SHA": "6d456b6", "Environment": "staging", "RepoName": "gobot", "Description": ""},
+			want: &app.RenderedTemplate{Emoji: ":hourglass_flowing_sand:", Color: "#dbab09", Pretext: ":hourglass_flowing_sand: deploy *v1.0.0* (6d456b6) to *staging* in_progress", Title: "gobot staging"},
+		},
+		"review re-requested": {
+			kind: app.TemplateReviewRequested,
+			data: map[string]interface{}{"ReviewerMentions": "<@U1>", "Title": "fix", "Body": "body", "ReRequested": true, "PreviousReviews": "• <https://github.com/ymgyt/gobot/pull/1#pullrequestreview-1|previous review> by ymgyt"},
+			want: &app.RenderedTemplate{Emoji: ":point_right:", Color: "#2cbe4e", Pretext: ":point_right: <@U1> ready for another look", Title: "fix", Text: "• <https://github.com/ymgyt/gobot/pull/1#pullrequestreview-1|previous review> by ymgyt"},
+		},
+		"review dismissed": {
+			kind: app.TemplateReviewDismissed,
+			data: map[string]interface{}{"ReviewerMention": "<@U1>", "DismissedBy": "octocat", "Number": int64(1), "Title": "fix"},
+			want: &app.RenderedTemplate{Emoji: ":no_entry_sign:", Color: "#586069", Pretext: ":no_entry_sign: <@U1> your review was dismissed by octocat", Title: "#1 fix"},
+		},
+		"review escalated": {
+			kind: app.TemplateReviewReminder,
+			data: map[string]interface{}{"Escalated": true, "ReviewerMention": "<@U1>", "AuthorMention": "<@U2>", "Hours": 16, "Number": int64(1), "Title": "fix"},
+			want: &app.RenderedTemplate{Emoji: ":rotating_light:", Color: "#cb2431", Pretext: ":rotating_light: review of #1 by <@U1> has been pending for 16 working hours <@U2>", Title: "#1 fix"},
+		},
+		"critical security alert": {
+			kind: app.TemplateSecurityAlert,
+			data: securityAlertData(map[string]interface{}{"Severity": "critical", "Mentions": "<@U1>"}),
+			want: &app.RenderedTemplate{Emoji: ":rotating_light:", Color: "#86181d", Pretext: ":rotating_light: <@U1> *critical* vulnerability in *lodash*", Title: "GHSA-1 Prototype Pollution", Text: "affected: < 4.17.12\npatched: 4.17.12\n"},
+		},
+		"moderate security alert": {
+			kind: app.TemplateSecurityAlert,
+			data: securityAlertData(map[string]interface{}{"Severity": "moderate"}),
+			want: &app.RenderedTemplate{Emoji: ":rotating_light:", Color: "#e36209", Pretext: ":rotating_light: *moderate* vulnerability in *lodash*", Title: "GHSA-1 Prototype Pollution", Text: "affected: < 4.17.12\npatched: 4.17.12\n"},
+		},
+		"dismissed security alert": {
+			kind: app.TemplateSecurityAlert,
+			data: securityAlertData(map[string]interface{}{"Severity": "high", "State": "dismissed", "DismissedBy": "ymgyt", "DismissReason": "tolerable_risk"}),
+			want: &app.RenderedTemplate{Emoji: ":white_check_mark:", Color: "#586069", Pretext: ":white_check_mark: vulnerability in *lodash* dismissed by ymgyt", Title: "GHSA-1 Prototype Pollution", Text: "affected: < 4.17.12\npatched: 4.17.12\nreason: tolerable_risk"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := app.RenderTemplate(tc.kind, app.NotificationTemplates{}.Resolve(tc.kind, "", ""), tc.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("(-want +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func securityAlertData(override map[string]interface{}) map[string]interface{} {
+	data := map[string]interface{}{
+		"Mentions": "", "State": "open", "Package": "lodash", "Identifier": "GHSA-1", "Summary": "Prototype Pollution",
+		"VulnerableRange": "< 4.17.12", "PatchedVersion": "4.17.12", "Manifest": "", "DismissedBy": "", "DismissReason": "",
+	}
+	for k, v := range override {
+		data[k] = v
+	}
+	return data
+}
+
+func TestNotificationTemplates_Resolve(t *testing.T) {
+	kind := app.TemplateReviewRequested
+	ts := app.NotificationTemplates{
+		{Kind: kind, Channel: "C1", Fields: map[string]string{"emoji": "channel", "title": "channel"}},
+		{Kind: kind, Repository: "ymgyt/gobot", Channel: "C1", Fields: map[string]string{"emoji": "repo and channel"}},
+		{Kind: kind, Repository: "ymgyt/gobot", Fields: map[string]string{"emoji": "repo", "title": "repo", "text": "repo"}},
+		{Kind: app.TemplateIssue, Fields: map[string]string{"emoji": "issue"}},
+	}
+
+	got := ts.Resolve(kind, "YMGYT/gobot", "C1")
+	want := map[string]string{
+		"emoji":   "repo and channel",
+		"color":   app.DefaultNotificationTemplates[kind].Fields["color"],
+		"pretext": app.DefaultNotificationTemplates[kind].Fields["pretext"],
+		"title":   "channel",
+		"text":    "repo",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want +got)\n%s", diff)
+	}
+
+	if got := ts.Resolve(kind, "ymgyt/other", "C2"); got["emoji"] != ":point_right:" {
+		t.Errorf("default emoji should be used, got %s", got["emoji"])
+	}
+}
@@ -0,0 +1,521 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/nlopes/slack"
+	"go.uber.org/zap"
+
+	"github.com/ymgyt/gobot/log"
+)
+
+// Notifier notifies github events to slack.
+// messages are written to outbox and delivered by OutboxSender, so they survive slack outage and restart.
+type Notifier struct {
+	Outbox             OutboxStore
+	AccountResolver    *AccountResolver
+	DuplicationChecker Deduplicator
+	Subscriptions      SubscriptionStore
+	Rules              RuleStore
+	Templates          TemplateStore
+	Renderer           SlackRenderer
+	PullRequests       PullRequestStore
+	RepositorySettings RepositorySettingStore
+	Teams              TeamStore
+	// Channel is id of default github notification channel. subscriptions are stored by channel id too.
+	Channel string
+	// SecurityChannel is id of default channel of security alerts. empty means Channel.
+	SecurityChannel string
+	// CIBatchWindow is duration to batch failures of the same check suite.
+	CIBatchWindow time.Duration
+	Now           func() time.Time
+
+	ciOnce    sync.Once
+	ciBatcher *CIFailureBatcher
+}
+
+const (
+	subscriptionLookupTimeout = 5 * time.Second
+)
+
+// notification is a github event notification before templates are rendered.
+type notification struct {
+	Kind  TemplateKind
+	Event *GithubEvent
+	// Data is passed to templates.
+	Data map[string]interface{}
+	// Attachment has fields which are not customizable by templates.
+	Attachment slack.Attachment
+	// ThreadKey is url of pull request or issue. Reply is posted to its thread.
+	ThreadKey string
+	Reply     bool
+	// UpdateKey identifies message updated in place. UpdateVersion orders updates.
+	UpdateKey     string
+	UpdateVersion int64
+	// Channel overrides default channel.
+	Channel string
+	// Direct posts to Channel only. subscribed channels are not notified. used for direct messages.
+	Direct bool
+}
+
+// notify posts notification to default channel and channels subscribing the event.
+// rules can route to other channels, suppress notification and add mentions.
+func (n *Notifier) notify(nt *notification) error {
+	event := nt.Event
+	if event == nil {
+		event = &GithubEvent{}
+	}
+	result := n.evaluateRules(event)
+	var channels []string
+	switch {
+	case result.Suppressed:
+		log.Info("notifier/suppressed by rule", zap.String("repository", event.Repository), zap.String("event", event.Name))
+	case nt.Direct:
+		channels = []string{nt.Channel}
+	default:
+		channels = n.channels(event, nt.Channel)
+	}
+	channels = uniqueChannels(append(channels, result.Channels...)...)
+
+	mentions := make([]string, len(result.Mentions))
+	for i, m := range result.Mentions {
+		mentions[i] = n.mention(m)
+	}
+	text := strings.Join(mentions, " ")
+
+	templates := n.templates(nt.Kind)
+	var err error
+	for _, channel := range channels {
+		attachment := n.render(nt, templates.Resolve(nt.Kind, event.Repository, channel))
+		if postErr := n.post(nt, channel, text, attachment); postErr != nil {
+			log.Error("notifier/post", zap.String("channel", channel), zap.Error(postErr))
+			err = postErr
+		}
+	}
+	return err
+}
+
+func (n *Notifier) templates(kind TemplateKind) NotificationTemplates {
+	if n.Templates == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), subscriptionLookupTimeout)
+	defer cancel()
+	templates, err := n.Templates.ListTemplates(ctx, kind)
+	// default templates are used.
+	if err != nil {
+		log.Error("notifier/list templates", zap.String("kind", string(kind)), zap.Error(err))
+		return nil
+	}
+	return templates
+}
+
+func (n *Notifier) render(nt *notification, fields map[string]string) slack.Attachment {
+	data := make(map[string]interface{}, len(nt.Data)+2)
+	for k, v := range nt.Data {
+		data[k] = v
+	}
+	rendered, err := RenderTemplate(nt.Kind, fields, data)
+	if err != nil {
+		log.Warn("notifier/render template", zap.String("kind", string(nt.Kind)), zap.Error(err))
+	}
+	attachment := nt.Attachment
+	attachment.Color = rendered.Color
+	attachment.Pretext = rendered.Pretext
+	attachment.Title = rendered.Title
+	attachment.Text = rendered.Text
+	return attachment
+}
+
+func (n *Notifier) evaluateRules(event *GithubEvent) *RuleResult {
+	if n.Rules == nil {
+		return &RuleResult{}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), subscriptionLookupTimeout)
+	defer cancel()
+	rules, err := n.Rules.ListRules(ctx)
+	// rules are not applied rather than dropping notification.
+	if err != nil {
+		log.Error("notifier/list rules", zap.Error(err))
+		return &RuleResult{}
+	}
+	return rules.Evaluate(event)
+}
+
+// mention accepts slack mention as is and resolves github login.
+func (n *Notifier) mention(m string) string {
+	if strings.HasPrefix(m, "<") {
+		return m
+	}
+	return n.MentionByGithubUsername(strings.TrimPrefix(m, "@"))
+}
+
+// channels returns default channel and subscribed channels. defaultChannel overrides Channel.
+func (n *Notifier) channels(event *GithubEvent, defaultChannel string) []string {
+	var subscribed []string
+	if n.Subscriptions != nil && event.Repository != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), subscriptionLookupTimeout)
+		defer cancel()
+		var err error
+		subscribed, err = n.Subscriptions.SubscribedChannels(ctx, strings.ToLower(event.Repository), event.Category, event.Environment)
+		// default channel is notified even if subscriptions are not available.
+		if err != nil {
+			log.Error("notifier/lookup subscriptions", zap.String("repository", event.Repository), zap.Error(err))
+		}
+	}
+	if defaultChannel == "" {
+		defaultChannel = n.Channel
+	}
+	return uniqueChannels(append([]string{defaultChannel}, subscribed...)...)
+}
+
+func (n *Notifier) post(nt *notification, channel, text string, attachment slack.Attachment) error {
+	now := n.Now()
+	msg := &OutboxMessage{
+		ID:            NewOutboxID(),
+		Channel:       channel,
+		Text:          text,
+		Attachments:   []slack.Attachment{attachment},
+		ThreadKey:     nt.ThreadKey,
+		Reply:         nt.Reply,
+		UpdateKey:     nt.UpdateKey,
+		UpdateVersion: nt.UpdateVersion,
+		Status:        OutboxPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if n.Renderer == RenderBlocks {
+		if err := msg.setBlocks(attachment); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if err := n.Outbox.Enqueue(context.Background(), msg); err != nil {
+		return errors.Annotatef(err, "channel=%s", channel)
+	}
+	log.Debug("notifier/enqueue outbox", zap.String("id", msg.ID), zap.String("channel", channel))
+	return nil
+}
+
+// PRReviewRequestedMsg githubのPullRequestでReviewerを指定した際にslackに通知するための情報.
+type PRReviewRequestedMsg struct {
+	Owner              string // prを作成したuser name(login)
+	OwnerAvatarURL     string
+	URL                string   // prへのlink
+	Title              string   // prのtitle
+	Body               string   // prのcomment
+	RepoName           string   // prが紐づくrepositoryの名前
+	RequestedReviewers []string // reviewerとして指定されたuser name(login)
+	RequestedTeams     []string // reviewerとして指定されたteamのslug
+	// PreviousReviews are changes_requested reviews of re-requested reviewers.
+	PreviousReviews []*PullRequestReview
+	Event           *GithubEvent
+}
+
+// ReRequested reports whether review is requested again after changes were requested.
+func (m *PRReviewRequestedMsg) ReRequested() bool {
+	return len(m.PreviousReviews) > 0
+}
+
+func (m *PRReviewRequestedMsg) previousReviewLines() string {
+	lines := make([]string, len(m.PreviousReviews))
+	for i, r := range m.PreviousReviews {
+		lines[i] = fmt.Sprintf("• <%s|previous review> by %s", r.URL, r.Reviewer)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (m *PRReviewRequestedMsg) notification(n *Notifier) *notification {
+	mentions := make([]string, 0, len(m.RequestedReviewers)+len(m.RequestedTeams))
+	for _, reviewer := range m.RequestedReviewers {
+		mentions = append(mentions, n.MentionByGithubUsername(reviewer))
+	}
+	for _, team := range m.RequestedTeams {
+		mentions = append(mentions, n.teamMention(team))
+	}
+	return &notification{
+		Kind:  TemplateReviewRequested,
+		Event: m.Event,
+		Data: map[string]interface{}{
+			"Owner":              m.Owner,
+			"OwnerMention":       n.MentionByGithubUsername(m.Owner),
+			"URL":                m.URL,
+			"Title":              m.Title,
+			"Body":               n.formatBody(m.Body, m.URL),
+			"RepoName":           m.RepoName,
+			"Repository":         m.Event.repository(),
+			"RequestedReviewers": m.RequestedReviewers,
+			"RequestedTeams":     m.RequestedTeams,
+			"ReviewerMentions":   strings.Join(mentions, " "),
+			"ReRequested":        m.ReRequested(),
+			"PreviousReviews":    m.previousReviewLines(),
+		},
+		Attachment: slack.Attachment{
+			Fallback:   "pull request review requested message",
+			AuthorName: m.Owner,
+			AuthorIcon: m.OwnerAvatarURL,
+			TitleLink:  m.URL,
+			Footer:     "Github webhook " + footerSuffix(),
+			Ts:         slackTimestamp(),
+			Fields:     repositoryFields(m.RepoName),
+		},
+		ThreadKey: m.URL,
+	}
+}
+
+// NotifyPRReviewRequested -
+func (n *Notifier) NotifyPRReviewRequested(msg *PRReviewRequestedMsg) error {
+	// https://github.com/ymgyt/gobot/issues/7
+	// when multiple reviewer are requested, multiple event emitted.
+	// re-request is notified to each reviewer.
+	key := msg.URL
+	if msg.ReRequested() {
+		key += ":" + strings.Join(msg.RequestedReviewers, ",")
+	}
+	var err error
+	if ok := n.DuplicationChecker.CheckDuplicateNotification(key, (4 * time.Second)); ok {
+		err = n.notify(msg.notification(n))
+	}
+	return err
+}
+
+// PRReviewSubmittedMsg -
+type PRReviewSubmittedMsg struct {
+	Owner             string
+	Title             string
+	URL               string
+	RepoName          string
+	Reviewer          string
+	ReviewerAvatarURL string
+	ReviewBody        string
+	ReviewState       string
+	ReviewURL         string
+	Event             *GithubEvent
+}
+
+func (m *PRReviewSubmittedMsg) notification(n *Notifier) *notification {
+	return &notification{
+		Kind:  TemplateReviewSubmitted,
+		Event: m.Event,
+		Data: map[string]interface{}{
+			"Owner":        m.Owner,
+			"OwnerMention": n.MentionByGithubUsername(m.Owner),
+			"Title":        m.Title,
+			"RepoName":     m.RepoName,
+			"Repository":   m.Event.repository(),
+			"Reviewer":     m.Reviewer,
+			"ReviewBody":   n.formatBody(m.ReviewBody, m.ReviewURL),
+			"ReviewState":  m.ReviewState,
+			"ReviewURL":    m.ReviewURL,
+		},
+		Attachment: slack.Attachment{
+			Fallback:   "pull request review submitted",
+			AuthorName: m.Reviewer,
+			AuthorIcon: m.ReviewerAvatarURL,
+			TitleLink:  m.ReviewURL,
+			Footer:     "Github webhook " + footerSuffix(),
+			Ts:         slackTimestamp(),
+			Fields:     repositoryFields(m.RepoName),
+		},
+		ThreadKey: m.URL,
+	}
+}
+
+// NotifyPRReviewSubmitted -
+func (n *Notifier) NotifyPRReviewSubmitted(msg *PRReviewSubmittedMsg) error {
+	// https://github.com/ymgyt/gobot/issues/6
+	// ignore self comment
+	if msg.Owner == msg.Reviewer {
+		log.Info("notify_prreview_submitted", zap.String("msg", "ignore event for self comment"), zap.String("pr_owner", msg.Owner), zap.String("reviewer", msg.Reviewer))
+		return nil
+	}
+	return n.notify(msg.notification(n))
+}
+
+// IssueMsg -
+type IssueMsg struct {
+	Action          string
+	Author          string
+	AuthorAvatarURL string
+	Number          int64
+	Title           string
+	Body            string
+	URL             string
+	RepoName        string
+	Event           *GithubEvent
+}
+
+func (m *IssueMsg) notification(n *Notifier) *notification {
+	return &notification{
+		Kind:  TemplateIssue,
+		Event: m.Event,
+		Data: map[string]interface{}{
+			"Action":     m.Action,
+			"Author":     m.Author,
+			"Number":     m.Number,
+			"Title":      m.Title,
+			"Body":       n.formatBody(m.Body, m.URL),
+			"URL":        m.URL,
+			"RepoName":   m.RepoName,
+			"Repository": m.Event.repository(),
+		},
+		Attachment: slack.Attachment{
+			Fallback:   "issue " + m.Action,
+			AuthorName: m.Author,
+			AuthorIcon: m.AuthorAvatarURL,
+			TitleLink:  m.URL,
+			Footer:     "Github webhook " + footerSuffix(),
+			Ts:         slackTimestamp(),
+			Fields:     repositoryFields(m.RepoName),
+		},
+		ThreadKey: m.URL,
+	}
+}
+
+// NotifyIssue -
+func (n *Notifier) NotifyIssue(msg *IssueMsg) error {
+	return n.notify(msg.notification(n))
+}
+
+// CommentMsg is a comment on pull request or issue. it is posted to the thread of pull request or issue.
+type CommentMsg struct {
+	Commenter          string
+	CommenterAvatarURL string
+	// Author is login of pull request or issue author.
+	Author      string
+	Number      int64
+	Title       string
+	Body        string
+	URL         string // commentへのlink
+	ThreadURL   string // pr or issueへのlink
+	PullRequest bool
+	RepoName    string
+	Event       *GithubEvent
+}
+
+// recipients returns author and users mentioned in comment except for commenter.
+func (m *CommentMsg) recipients() []string {
+	var logins []string
+	for _, login := range append([]string{m.Author}, GithubMentions(m.Body)...) {
+		if login == "" || strings.EqualFold(login, m.Commenter) {
+			continue
+		}
+		logins = append(logins, login)
+	}
+	return logins
+}
+
+func (m *CommentMsg) notification(n *Notifier) *notification {
+	// author and users mentioned in comment are notified.
+	mentions := []string{}
+	for _, login := range m.recipients() {
+		if mention, ok := n.slackMention(login); ok && !stringsContain(mentions, mention) {
+			mentions = append(mentions, mention)
+		}
+	}
+	return &notification{
+		Kind:  TemplateComment,
+		Event: m.Event,
+		Data: map[string]interface{}{
+			"Commenter":     m.Commenter,
+			"Author":        m.Author,
+			"AuthorMention": n.MentionByGithubUsername(m.Author),
+			"Mentions":      strings.Join(mentions, " "),
+			"Number":        m.Number,
+			"Title":         m.Title,
+			"Body":          n.formatBody(m.Body, m.URL),
+			"URL":           m.URL,
+			"PullRequest":   m.PullRequest,
+			"RepoName":      m.RepoName,
+			"Repository":    m.Event.repository(),
+		},
+		Attachment: slack.Attachment{
+			Fallback:   "comment created",
+			AuthorName: m.Commenter,
+			AuthorIcon: m.CommenterAvatarURL,
+			TitleLink:  m.URL,
+			Footer:     "Github webhook " + footerSuffix(),
+			Ts:         slackTimestamp(),
+			Fields:     repositoryFields(m.RepoName),
+		},
+		ThreadKey: m.ThreadURL,
+		Reply:     true,
+	}
+}
+
+// NotifyComment -
+func (n *Notifier) NotifyComment(msg *CommentMsg) error {
+	// self comment without mentions reaches nobody.
+	if len(msg.recipients()) == 0 {
+		log.Info("notify_comment", zap.String("msg", "ignore event for self comment"), zap.String("author", msg.Author))
+		return nil
+	}
+	return n.notify(msg.notification(n))
+}
+
+func repositoryFields(repoName string) []slack.AttachmentField {
+	return []slack.AttachmentField{
+		{
+			Title: "Repository",
+			Value: slackTextEscaper.Replace(repoName),
+			Short: true,
+		},
+	}
+}
+
+// teamMention returns mention of team mapped by TeamStore. unmapped team is not mentioned.
+func (n *Notifier) teamMention(slug string) string {
+	if n.Teams == nil {
+		return "@" + slug
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), subscriptionLookupTimeout)
+	defer cancel()
+	team, err := n.Teams.GetTeam(ctx, strings.ToLower(slug))
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			log.Error("notifier/get team", zap.String("team", slug), zap.Error(err))
+		}
+		return "@" + slug
+	}
+	return team.Mention()
+}
+
+// formatBody converts github markdown body to slack mrkdwn with mentions translated.
+func (n *Notifier) formatBody(body, url string) string {
+	f := &BodyFormatter{Mention: n.slackMention, MaxLength: maxBodyLength}
+	return f.Format(body, url)
+}
+
+// slackMention returns slack mention of github user. unlike MentionByGithubUsername, unresolvable user is reported by ok.
+func (n *Notifier) slackMention(githubUserName string) (string, bool) {
+	if n.AccountResolver == nil {
+		return "", false
+	}
+	user, err := n.AccountResolver.SlackUserFromGithubUsername(githubUserName)
+	if err != nil {
+		if !IsUserNotFound(err) {
+			log.Warn("notifier/resolve github user", zap.String("github_user", githubUserName), zap.Error(err))
+		}
+		return "", false
+	}
+	return Mentiorize(user.ID), true
+}
+
+// MentionByGithubUsername githubのusernameをslackでmentionできるようにする.
+func (n *Notifier) MentionByGithubUsername(name string) string {
+	user, err := n.AccountResolver.SlackUserFromGithubUsername(name)
+	// 見つからなければそれがわかるように元の名前で返す
+	if IsUserNotFound(err) {
+		return fmt.Sprintf("<@%s> (could not resolve slack user by github user name)", name)
+	}
+	if err != nil {
+		return fmt.Sprintf("<@%s> (%s)", name, err)
+	}
+
+	return Mentiorize(user.ID)
+}
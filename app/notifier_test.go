@@ -0,0 +1,229 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nlopes/slack"
+
+	"github.com/ymgyt/gobot/app"
+)
+
+type fakeTemplateStore app.NotificationTemplates
+
+func (s fakeTemplateStore) SetTemplate(ctx context.Context, t *app.NotificationTemplate, field, text string) error {
+	return nil
+}
+
+func (s fakeTemplateStore) ListTemplates(ctx context.Context, kind app.TemplateKind) (app.NotificationTemplates, error) {
+	return app.NotificationTemplates(s), nil
+}
+
+func (s fakeTemplateStore) DeleteTemplate(ctx context.Context, kind app.TemplateKind, repository, channel string) error {
+	return nil
+}
+
+// fakeSubscriptions returns the same channels for any event.
+type fakeSubscriptions []string
+
+func (s fakeSubscriptions) Subscribe(ctx context.Context, sub *app.Subscription) error { return nil }
+
+func (s fakeSubscriptions) Unsubscribe(ctx context.Context, channel, repository string, categories []app.EventCategory) error {
+	return nil
+}
+
+func (s fakeSubscriptions) ListSubscriptions(ctx context.Context, input *app.ListSubscriptionsInput) (app.Subscriptions, error) {
+	return nil, nil
+}
+
+func (s fakeSubscriptions) SubscribedChannels(ctx context.Context, repository string, category app.EventCategory, environment string) ([]string, error) {
+	return s, nil
+}
+
+func TestNotifier_TemplateOnDefaultChannel(t *testing.T) {
+	now := time.Date(2019, 4, 1, 10, 0, 0, 0, time.UTC)
+	outbox := &fakeOutboxStore{now: func() time.Time { return now }}
+	n := &app.Notifier{
+		Outbox: outbox,
+		// template set by "template set --here" in the default channel is keyed by channel id.
+		Templates: fakeTemplateStore{
+			{Kind: app.TemplateIssue, Channel: "C1", Fields: map[string]string{"pretext": "default channel"}},
+		},
+		// default channel also subscribes the repository.
+		Subscriptions: fakeSubscriptions{"C1", "C2"},
+		Renderer:      app.RenderAttachments,
+		Channel:       "C1",
+		Now:           outbox.now,
+	}
+
+	err := n.NotifyIssue(&app.IssueMsg{
+		Action:   "opened",
+		Author:   "octocat",
+		Number:   1,
+		Title:    "Found a bug",
+		URL:      "https://github.com/ymgyt/gobot/issues/1",
+		RepoName: "gobot",
+		Event:    &app.GithubEvent{Name: "issues", Repository: "ymgyt/gobot", Category: app.EventCategoryIssues},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]string)
+	for _, m := range outbox.messages {
+		if _, ok := got[m.Channel]; ok {
+			t.Errorf("duplicated message to %s", m.Channel)
+		}
+		got[m.Channel] = m.Attachments[0].Pretext
+	}
+	if len(got) != 2 {
+		t.Fatalf("want messages to C1 and C2, got %v", got)
+	}
+	if got["C1"] != "default channel" {
+		t.Errorf("template of default channel is not applied: %q", got["C1"])
+	}
+	if got["C2"] == "default channel" {
+		t.Error("template of default channel is applied to other channel")
+	}
+}
+
+type fakeRuleStore app.Rules
+
+func (s fakeRuleStore) AddRule(ctx context.Context, r *app.Rule) error { return nil }
+
+func (s fakeRuleStore) ListRules(ctx context.Context) (app.Rules, error) { return app.Rules(s), nil }
+
+func (s fakeRuleStore) DeleteRule(ctx context.Context, id string) error { return nil }
+
+func TestNotifier_DirectRules(t *testing.T) {
+	suppress := mustRule(t, 0, app.RuleSuppress, "author=dependabot*")
+	mention := mustRule(t, 0, app.RuleMention, "repo=ymgyt/*")
+	mention.Mentions = []string{"<@U123>"}
+
+	tests := map[string]struct {
+		rules app.Rules
+		want  map[string]string
+	}{
+		"no rule":  {want: map[string]string{"U1": ""}},
+		"suppress": {rules: app.Rules{suppress}, want: map[string]string{}},
+		"mention":  {rules: app.Rules{mention}, want: map[string]string{"U1": "<@U123>"}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			now := time.Date(2019, 4, 1, 10, 0, 0, 0, time.UTC)
+			outbox := &fakeOutboxStore{now: func() time.Time { return now }}
+			n := &app.Notifier{
+				Outbox:        outbox,
+				Rules:         fakeRuleStore(tc.rules),
+				Subscriptions: fakeSubscriptions{"C2"},
+				Renderer:      app.RenderAttachments,
+				Channel:       "C1",
+				Now:           outbox.now,
+			}
+			event := &app.GithubEvent{Name: "review_reminder", Category: app.EventCategoryReviews, Repository: "ymgyt/gobot", Author: "dependabot[bot]"}
+			if err := n.NotifyDirect(event, "U1"); err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string]string)
+			for _, m := range outbox.messages {
+				got[m.Channel] = m.Text
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("(-want +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+// fakeSlackAPI answers users.list with users.
+type fakeSlackAPI []slack.User
+
+func (f fakeSlackAPI) Do(r *http.Request) (*http.Response, error) {
+	body, err := json.Marshal(map[string]interface{}{"ok": true, "members": []slack.User(f)})
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: ioutil.NopCloser(bytes.NewReader(body))}, nil
+}
+
+func TestNotifier_NotifyComment(t *testing.T) {
+	users := &fakeUserStore{users: app.Users{
+		linkedUser("octocat", "octocat@example.com", "U1"),
+		linkedUser("alice", "alice@example.com", "U2"),
+	}}
+	client := slack.New("token", slack.OptionHTTPClient(fakeSlackAPI{
+		slackUser("U1", "octocat@example.com", false),
+		slackUser("U2", "alice@example.com", false),
+	}))
+
+	tests := map[string]struct {
+		commenter string
+		body      string
+		// want is mentions of posted message. nil means no message.
+		want []string
+	}{
+		"author is notified":                {commenter: "alice", body: "LGTM", want: []string{"<@U1>"}},
+		"self comment":                      {commenter: "octocat", body: "fixed"},
+		"self comment mentions":             {commenter: "octocat", body: "@alice please check", want: []string{"<@U2>"}},
+		"commenter mentions self":           {commenter: "alice", body: "@alice @octocat done", want: []string{"<@U1>"}},
+		"self comment mentions author only": {commenter: "octocat", body: "note to @octocat"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			now := time.Date(2019, 4, 1, 10, 0, 0, 0, time.UTC)
+			outbox := &fakeOutboxStore{now: func() time.Time { return now }}
+			n := &app.Notifier{
+				Outbox:          outbox,
+				AccountResolver: &app.AccountResolver{SlackClient: client, UserStore: users, Mu: &sync.Mutex{}},
+				Renderer:        app.RenderAttachments,
+				Channel:         "C1",
+				Now:             outbox.now,
+			}
+			err := n.NotifyComment(&app.CommentMsg{
+				Commenter: tc.commenter,
+				Author:    "octocat",
+				Number:    1,
+				Body:      tc.body,
+				URL:       "https://github.com/ymgyt/gobot/pull/1#issuecomment-1",
+				ThreadURL: "https://github.com/ymgyt/gobot/pull/1",
+				RepoName:  "gobot",
+				Event:     &app.GithubEvent{Name: "issue_comment", Repository: "ymgyt/gobot", Category: app.EventCategoryComments},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.want == nil {
+				if len(outbox.messages) != 0 {
+					t.Errorf("want no message, got %d", len(outbox.messages))
+				}
+				return
+			}
+			if len(outbox.messages) != 1 {
+				t.Fatalf("want 1 message, got %d", len(outbox.messages))
+			}
+			pretext := outbox.messages[0].Attachments[0].Pretext
+			for _, m := range []string{"<@U1>", "<@U2>"} {
+				if got, want := strings.Contains(pretext, m), stringsContain(tc.want, m); got != want {
+					t.Errorf("mention %s: want %v, got pretext %q", m, want, pretext)
+				}
+			}
+		})
+	}
+}
+
+func stringsContain(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,357 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/nlopes/slack"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+
+	"github.com/ymgyt/gobot/log"
+)
+
+// OutboxStatus -
+type OutboxStatus string
+
+const (
+	OutboxPending OutboxStatus = "pending"
+	OutboxSent    OutboxStatus = "sent"
+	// OutboxDead means delivery was given up after max attempts.
+	OutboxDead OutboxStatus = "dead"
+)
+
+// OutboxMessage is an outgoing slack message persisted before delivery.
+type OutboxMessage struct {
+	ID            string             `json:"id" bson:"_id"`
+	Channel       string             `json:"channel" bson:"channel"`
+	Text          string             `json:"text,omitempty" bson:"text,omitempty"`
+	Attachments   []slack.Attachment `json:"attachments,omitempty" bson:"attachments,omitempty"`
+	Blocks        string             `json:"blocks,omitempty" bson:"blocks,omitempty"` // json encoded. bson can not restore blocks as is.
+	Status        OutboxStatus       `json:"status" bson:"status"`
+	Attempts      int                `json:"attempts" bson:"attempts"`
+	NextAttemptAt time.Time          `json:"next_attempt_at" bson:"next_attempt_at"`
+	LastError     string             `json:"last_error,omitempty" bson:"last_error,omitempty"`
+	// ThreadKey groups messages of the same conversation. ex. pull request url.
+	ThreadKey string `json:"thread_key,omitempty" bson:"thread_key,omitempty"`
+	// Reply is posted to the thread of the first message which has the same ThreadKey in the channel.
+	Reply bool `json:"reply,omitempty" bson:"reply,omitempty"`
+	// UpdateKey identifies a message updated in place. message is posted first, then sent messages replace it.
+	UpdateKey string `json:"update_key,omitempty" bson:"update_key,omitempty"`
+	// UpdateVersion orders messages of UpdateKey. message older than the posted one is not sent. ex. deployment status id.
+	UpdateVersion int64 `json:"update_version,omitempty" bson:"update_version,omitempty"`
+	// PostedChannel and PostedTS identify the message posted to slack.
+	PostedChannel string    `json:"posted_channel,omitempty" bson:"posted_channel,omitempty"`
+	PostedTS      string    `json:"posted_ts,omitempty" bson:"posted_ts,omitempty"`
+	CreatedAt     time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// setBlocks renders attachment as blocks. Attachments are kept to give color to blocks and to be posted when slack rejects blocks.
+func (m *OutboxMessage) setBlocks(attachment slack.Attachment) error {
+	encoded, err := marshalBlocks(AttachmentBlocks(attachment))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	m.Blocks = encoded
+	return nil
+}
+
+// blockMessage returns blocks wrapped in an attachment which has color of the attachment.
+func (m *OutboxMessage) blockMessage() *BlockMessage {
+	msg := &BlockMessage{Text: m.Text, Blocks: json.RawMessage(m.Blocks)}
+	if len(m.Attachments) > 0 {
+		msg.Color = m.Attachments[0].Color
+		msg.Fallback = m.Attachments[0].Fallback
+	}
+	return msg
+}
+
+// NewOutboxID -
+func NewOutboxID() string {
+	return primitive.NewObjectID().Hex()
+}
+
+// OutboxMessages -
+type OutboxMessages []*OutboxMessage
+
+// Header implements Tabular.
+func (ms OutboxMessages) Header() []string {
+	return []string{"id", "channel", "status", "attempts", "next_attempt_at", "last_error", "created_at"}
+}
+
+// Rows implements Tabular.
+func (ms OutboxMessages) Rows() [][]string {
+	rows := make([][]string, 0, len(ms))
+	for _, m := range ms {
+		rows = append(rows, []string{
+			m.ID,
+			m.Channel,
+			string(m.Status),
+			fmt.Sprintf("%d", m.Attempts),
+			m.NextAttemptAt.In(TimeZone).Format(time.RFC3339),
+			m.LastError,
+			m.CreatedAt.In(TimeZone).Format(time.RFC3339),
+		})
+	}
+	return rows
+}
+
+// ListOutboxInput -
+type ListOutboxInput struct {
+	Status OutboxStatus
+	Limit  int64
+}
+
+// MarkOutboxFailedInput -
+type MarkOutboxFailedInput struct {
+	ID            string
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	Dead          bool
+}
+
+// OutboxStore persists outgoing messages.
+type OutboxStore interface {
+	Enqueue(context.Context, *OutboxMessage) error
+	// Claim returns a due pending message and hides it from other senders until lease expires.
+	// it returns nil when no message is due.
+	Claim(ctx context.Context, lease time.Duration) (*OutboxMessage, error)
+	MarkSent(ctx context.Context, id, channel, ts string) error
+	MarkFailed(context.Context, *MarkOutboxFailedInput) error
+	ListOutbox(context.Context, *ListOutboxInput) (OutboxMessages, error)
+	// Retry moves a dead message back to pending.
+	Retry(ctx context.Context, id string) error
+	// ThreadTS returns ts of the first sent message of threadKey in channel. it returns empty string when not found.
+	ThreadTS(ctx context.Context, channel, threadKey string) (string, error)
+	// PostedMessage returns the sent message of updateKey in channel which has the latest UpdateVersion. it returns nil when not found.
+	PostedMessage(ctx context.Context, channel, updateKey string) (*OutboxMessage, error)
+	// LockUpdateKey serializes sending messages of updateKey in channel across senders.
+	// it returns false when another sender holds the lock. lock expires after lease.
+	LockUpdateKey(ctx context.Context, channel, updateKey string, lease time.Duration) (bool, error)
+	UnlockUpdateKey(ctx context.Context, channel, updateKey string) error
+}
+
+// SlackMessagePoster posts messages with nlopes/slack options. *slack.Client implements it.
+type SlackMessagePoster interface {
+	PostMessage(channel string, options ...slack.MsgOption) (string, string, error)
+	UpdateMessage(channel, timestamp string, options ...slack.MsgOption) (string, string, string, error)
+}
+
+// BlockMessagePoster posts Block Kit messages. *BlockPoster implements it.
+type BlockMessagePoster interface {
+	PostMessage(ctx context.Context, channel string, msg *BlockMessage) (string, string, error)
+	PostReply(ctx context.Context, channel, threadTS string, msg *BlockMessage) (string, string, error)
+	UpdateMessage(ctx context.Context, channel, ts string, msg *BlockMessage) (string, string, error)
+}
+
+// OutboxSender delivers outbox messages to slack with exponential backoff.
+type OutboxSender struct {
+	Store        OutboxStore
+	Client       SlackMessagePoster
+	Poster       BlockMessagePoster
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	PollInterval time.Duration
+	Now          func() time.Time
+
+	pausedUntil time.Time
+}
+
+const (
+	outboxLease = time.Minute
+	// message of locked update key is retried after this delay.
+	updateKeyLockedDelay = time.Second
+)
+
+// errUpdateKeyLocked means another sender is sending message of the same update key.
+var errUpdateKeyLocked = errors.New("update key is locked by another sender")
+
+// Run polls outbox and sends due messages until ctx is done.
+func (s *OutboxSender) Run(ctx context.Context) error {
+	tick := time.NewTicker(s.PollInterval)
+	defer tick.Stop()
+	for {
+		s.sendDue(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tick.C:
+		}
+	}
+}
+
+func (s *OutboxSender) sendDue(ctx context.Context) {
+	for {
+		// slack asked us to wait. respect it for every message.
+		if s.Now().Before(s.pausedUntil) {
+			return
+		}
+		msg, err := s.Store.Claim(ctx, outboxLease)
+		if err != nil {
+			log.Error("outbox/claim", zap.Error(err))
+			return
+		}
+		if msg == nil {
+			return
+		}
+		s.send(ctx, msg)
+
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+	}
+}
+
+func (s *OutboxSender) post(ctx context.Context, msg *OutboxMessage) (string, string, error) {
+	if msg.UpdateKey != "" {
+		return s.postOrUpdate(ctx, msg)
+	}
+	return s.postMessage(ctx, msg)
+}
+
+// postOrUpdate posts msg first time, then updates the posted message. the decision is serialized per update key,
+// otherwise messages enqueued before the first one is sent are posted separately.
+func (s *OutboxSender) postOrUpdate(ctx context.Context, msg *OutboxMessage) (string, string, error) {
+	locked, err := s.Store.LockUpdateKey(ctx, msg.Channel, msg.UpdateKey, outboxLease)
+	if err != nil {
+		return "", "", errors.Trace(err)
+	}
+	if !locked {
+		return "", "", errUpdateKeyLocked
+	}
+	defer func() {
+		if err := s.Store.UnlockUpdateKey(ctx, msg.Channel, msg.UpdateKey); err != nil {
+			log.Warn("outbox/unlock update key", zap.String("id", msg.ID), zap.String("update_key", msg.UpdateKey), zap.Error(err))
+		}
+	}()
+
+	posted, err := s.Store.PostedMessage(ctx, msg.Channel, msg.UpdateKey)
+	if err != nil {
+		return "", "", errors.Annotatef(err, "lookup posted message update_key=%s", msg.UpdateKey)
+	}
+	if posted == nil {
+		return s.postMessage(ctx, msg)
+	}
+	// retries and concurrent deliveries can reorder updates. older one must not overwrite final status.
+	if msg.UpdateVersion < posted.UpdateVersion {
+		log.Info("outbox/skip stale update", zap.String("id", msg.ID), zap.String("update_key", msg.UpdateKey),
+			zap.Int64("version", msg.UpdateVersion), zap.Int64("posted_version", posted.UpdateVersion))
+		return posted.PostedChannel, posted.PostedTS, nil
+	}
+	return s.update(ctx, msg, posted)
+}
+
+func (s *OutboxSender) postMessage(ctx context.Context, msg *OutboxMessage) (string, string, error) {
+	threadTS := s.threadTS(ctx, msg)
+	if msg.Blocks != "" {
+		var channel, ts string
+		var err error
+		if threadTS != "" {
+			channel, ts, err = s.Poster.PostReply(ctx, msg.Channel, threadTS, msg.blockMessage())
+		} else {
+			channel, ts, err = s.Poster.PostMessage(ctx, msg.Channel, msg.blockMessage())
+		}
+		if !s.fallback(msg, err) {
+			return channel, ts, err
+		}
+	}
+	opts := []slack.MsgOption{slack.MsgOptionAttachments(msg.Attachments...)}
+	if msg.Text != "" {
+		opts = append(opts, slack.MsgOptionText(msg.Text, false))
+	}
+	if threadTS != "" {
+		opts = append(opts, slack.MsgOptionTS(threadTS))
+	}
+	return s.Client.PostMessage(msg.Channel, opts...)
+}
+
+// update replaces posted message with msg.
+func (s *OutboxSender) update(ctx context.Context, msg, posted *OutboxMessage) (string, string, error) {
+	if msg.Blocks != "" {
+		channel, ts, err := s.Poster.UpdateMessage(ctx, posted.PostedChannel, posted.PostedTS, msg.blockMessage())
+		if !s.fallback(msg, err) {
+			return channel, ts, err
+		}
+	}
+	opts := []slack.MsgOption{slack.MsgOptionAttachments(msg.Attachments...)}
+	if msg.Text != "" {
+		opts = append(opts, slack.MsgOptionText(msg.Text, false))
+	}
+	channel, ts, _, err := s.Client.UpdateMessage(posted.PostedChannel, posted.PostedTS, opts...)
+	return channel, ts, err
+}
+
+// fallback reports whether msg should be posted as attachments because slack rejected its blocks.
+func (s *OutboxSender) fallback(msg *OutboxMessage, err error) bool {
+	if !IsBlockError(err) || len(msg.Attachments) == 0 {
+		return false
+	}
+	log.Warn("outbox/blocks rejected. fall back to attachments", zap.String("id", msg.ID), zap.Error(err))
+	return true
+}
+
+// threadTS returns thread which reply is posted to. reply is posted to channel when thread is not found.
+func (s *OutboxSender) threadTS(ctx context.Context, msg *OutboxMessage) string {
+	if !msg.Reply || msg.ThreadKey == "" {
+		return ""
+	}
+	ts, err := s.Store.ThreadTS(ctx, msg.Channel, msg.ThreadKey)
+	if err != nil {
+		log.Warn("outbox/lookup thread", zap.String("id", msg.ID), zap.String("thread_key", msg.ThreadKey), zap.Error(err))
+		return ""
+	}
+	return ts
+}
+
+func (s *OutboxSender) send(ctx context.Context, msg *OutboxMessage) {
+	channel, ts, err := s.post(ctx, msg)
+	if err == nil {
+		if err := s.Store.MarkSent(ctx, msg.ID, channel, ts); err != nil {
+			log.Error("outbox/mark sent", zap.String("id", msg.ID), zap.Error(err))
+		}
+		return
+	}
+
+	input := &MarkOutboxFailedInput{ID: msg.ID, Attempts: msg.Attempts, LastError: err.Error()}
+	if errors.Cause(err) == errUpdateKeyLocked {
+		// message is retried after another sender posts message of the same key.
+		input.NextAttemptAt = s.Now().Add(updateKeyLockedDelay)
+		log.Info("outbox/update key locked", zap.String("id", msg.ID), zap.String("update_key", msg.UpdateKey))
+	} else if rateLimited, ok := errors.Cause(err).(*slack.RateLimitedError); ok {
+		// rate limit is not a failure of the message, so it does not count as an attempt.
+		s.pausedUntil = s.Now().Add(rateLimited.RetryAfter)
+		input.NextAttemptAt = s.pausedUntil
+		log.Warn("outbox/rate limited", zap.String("id", msg.ID), zap.Duration("retry_after", rateLimited.RetryAfter))
+	} else {
+		input.Attempts++
+		input.NextAttemptAt = s.Now().Add(s.backoff(input.Attempts))
+		input.Dead = input.Attempts >= s.MaxAttempts
+		log.Warn("outbox/send failed", zap.String("id", msg.ID), zap.Int("attempts", input.Attempts), zap.Bool("dead", input.Dead), zap.Error(err))
+	}
+	if err := s.Store.MarkFailed(ctx, input); err != nil {
+		log.Error("outbox/mark failed", zap.String("id", msg.ID), zap.Error(err))
+	}
+}
+
+// backoff returns BaseBackoff * 2^(attempts-1) with jitter, capped by MaxBackoff.
+func (s *OutboxSender) backoff(attempts int) time.Duration {
+	d := s.BaseBackoff
+	for i := 1; i < attempts && d < s.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > s.MaxBackoff {
+		d = s.MaxBackoff
+	}
+	// nolint:gosec
+	jitter := time.Duration(rand.Int63n(int64(d)/5 + 1))
+	return d + jitter
+}
@@ -0,0 +1,135 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nlopes/slack"
+	"github.com/ymgyt/cli"
+)
+
+func NewOutboxCommand(b *CommandBuilder) *cli.Command {
+	cmd := &cli.Command{
+		Name:      "outbox",
+		ShortDesc: "operate outgoing notifications",
+		LongDesc:  "@gobot outbox <COMMAND> <OPTIONS> <ARGS>",
+	}
+	return cmd.
+		AddCommand(NewOutboxLsCommand(b.Outbox)).
+		AddCommand(NewOutboxRetryCommand(b.Outbox))
+}
+
+func NewOutboxLsCommand(outbox OutboxStore) *cli.Command {
+	outboxLsCmd := &outboxLsCommand{}
+	cmd := &cli.Command{
+		Name:      "ls",
+		Aliases:   []string{"list"},
+		ShortDesc: "ls outbox messages",
+		LongDesc: "ls outbox messages\n" +
+			"Usage: @gobot outbox ls <OPTIONS>\n\n" +
+			"# 配送をあきらめたmessageを表示\n" +
+			"@gobot outbox ls --status=dead",
+		Run: outboxLsCmd.runFunc(outbox),
+	}
+	if err := cmd.Options().
+		Add(&cli.BoolOpt{Var: &outboxLsCmd.printHelp, Long: "help", Description: "print help"}).
+		Add(&cli.StringOpt{Var: &outboxLsCmd.Status, Long: "status", Description: "filter by status(pending,sent,dead)"}).
+		Add(&cli.IntOpt{Var: &outboxLsCmd.Limit, Long: "limit", Description: "message limit. default 20"}).
+		Add(outboxLsCmd.option()).
+		Err; err != nil {
+		panic(err)
+	}
+	return cmd
+}
+
+type outboxLsCommand struct {
+	baseCommand
+	lsOutput
+	Status string
+	Limit  int
+}
+
+func (c *outboxLsCommand) runFunc(outbox OutboxStore) commandFunc {
+	return func(ctx context.Context, cmd *cli.Command, args []string) {
+		if c.printHelp {
+			cli.HelpFunc(cmd.Stdout, cmd)
+			return
+		}
+		sm := getSlackMessage(ctx)
+
+		limit := c.Limit
+		if limit <= 0 {
+			limit = 20
+		}
+		msgs, err := outbox.ListOutbox(ctx, &ListOutboxInput{Status: OutboxStatus(c.Status), Limit: int64(limit)})
+		if err != nil {
+			sm.Fail(err)
+			return
+		}
+		if c.enabled() {
+			c.post(sm, "outbox", msgs)
+			return
+		}
+
+		text := fmt.Sprintf("%d outbox message(s) found", len(msgs))
+		fields := make([]slack.AttachmentField, 0, len(msgs))
+		for _, m := range msgs {
+			value := fmt.Sprintf("status=%s attempts=%d channel=%s", m.Status, m.Attempts, m.Channel)
+			if m.LastError != "" {
+				value += "\nlast_error=" + m.LastError
+			}
+			fields = append(fields, slack.AttachmentField{Title: m.ID, Value: value})
+		}
+		sm.PostAttachment(slack.Attachment{
+			Fallback: text,
+			Color:    slackColorGreen,
+			Pretext:  text,
+			Fields:   fields,
+		})
+	}
+}
+
+func NewOutboxRetryCommand(outbox OutboxStore) *cli.Command {
+	outboxRetryCmd := &outboxRetryCommand{}
+	cmd := &cli.Command{
+		Name:      "retry",
+		ShortDesc: "retry dead outbox messages",
+		LongDesc: "retry dead outbox messages\n" +
+			"Usage: @gobot outbox retry <ID>...\n\n" +
+			"@gobot outbox retry 5cc2d5d4e1b3c2a1f0e9d8c7",
+		Run: outboxRetryCmd.runFunc(outbox),
+	}
+	if err := cmd.Options().
+		Add(&cli.BoolOpt{Var: &outboxRetryCmd.printHelp, Long: "help", Description: "print help"}).
+		Err; err != nil {
+		panic(err)
+	}
+	return cmd
+}
+
+type outboxRetryCommand struct {
+	baseCommand
+}
+
+func (c *outboxRetryCommand) runFunc(outbox OutboxStore) commandFunc {
+	return func(ctx context.Context, cmd *cli.Command, args []string) {
+		if c.printHelp || len(args) == 0 {
+			cli.HelpFunc(cmd.Stdout, cmd)
+			return
+		}
+		sm := getSlackMessage(ctx)
+
+		for _, id := range args {
+			if err := outbox.Retry(ctx, id); err != nil {
+				sm.Fail(err)
+				return
+			}
+		}
+		text := fmt.Sprintf("%d message(s) scheduled to retry", len(args))
+		sm.PostAttachment(slack.Attachment{
+			Fallback: text,
+			Color:    slackColorGreen,
+			Pretext:  slackEmojiOKHand + " " + text,
+		})
+	}
+}
@@ -0,0 +1,347 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/ymgyt/gobot/app"
+)
+
+// fakeOutboxStore keeps messages in memory in enqueued order.
+type fakeOutboxStore struct {
+	messages app.OutboxMessages
+	now      func() time.Time
+	claimed  map[string]bool
+	// locked update keys are held by another sender.
+	locked map[string]bool
+}
+
+func (s *fakeOutboxStore) Enqueue(ctx context.Context, msg *app.OutboxMessage) error {
+	msg.Status = app.OutboxPending
+	s.messages = append(s.messages, msg)
+	return nil
+}
+
+func (s *fakeOutboxStore) Claim(ctx context.Context, lease time.Duration) (*app.OutboxMessage, error) {
+	if s.claimed == nil {
+		s.claimed = make(map[string]bool)
+	}
+	for _, m := range s.messages {
+		if m.Status == app.OutboxPending && !s.claimed[m.ID] && !m.NextAttemptAt.After(s.now()) {
+			s.claimed[m.ID] = true
+			return m, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *fakeOutboxStore) MarkSent(ctx context.Context, id, channel, ts string) error {
+	m := s.get(id)
+	m.Status, m.PostedChannel, m.PostedTS = app.OutboxSent, channel, ts
+	return nil
+}
+
+func (s *fakeOutboxStore) MarkFailed(ctx context.Context, input *app.MarkOutboxFailedInput) error {
+	m := s.get(input.ID)
+	m.Attempts, m.NextAttemptAt, m.LastError = input.Attempts, input.NextAttemptAt, input.LastError
+	if input.Dead {
+		m.Status = app.OutboxDead
+	}
+	delete(s.claimed, input.ID)
+	return nil
+}
+
+func (s *fakeOutboxStore) ListOutbox(ctx context.Context, input *app.ListOutboxInput) (app.OutboxMessages, error) {
+	return s.messages, nil
+}
+
+func (s *fakeOutboxStore) Retry(ctx context.Context, id string) error {
+	s.get(id).Status = app.OutboxPending
+	return nil
+}
+
+func (s *fakeOutboxStore) ThreadTS(ctx context.Context, channel, threadKey string) (string, error) {
+	for _, m := range s.messages {
+		if m.Status == app.OutboxSent && m.Channel == channel && m.ThreadKey == threadKey {
+			return m.PostedTS, nil
+		}
+	}
+	return "", nil
+}
+
+func (s *fakeOutboxStore) PostedMessage(ctx context.Context, channel, updateKey string) (*app.OutboxMessage, error) {
+	var posted *app.OutboxMessage
+	for _, m := range s.messages {
+		if m.Status == app.OutboxSent && m.Channel == channel && m.UpdateKey == updateKey &&
+			(posted == nil || m.UpdateVersion > posted.UpdateVersion) {
+			posted = m
+		}
+	}
+	return posted, nil
+}
+
+func (s *fakeOutboxStore) LockUpdateKey(ctx context.Context, channel, updateKey string, lease time.Duration) (bool, error) {
+	if s.locked == nil {
+		s.locked = make(map[string]bool)
+	}
+	key := channel + "/" + updateKey
+	if s.locked[key] {
+		return false, nil
+	}
+	s.locked[key] = true
+	return true, nil
+}
+
+func (s *fakeOutboxStore) UnlockUpdateKey(ctx context.Context, channel, updateKey string) error {
+	delete(s.locked, channel+"/"+updateKey)
+	return nil
+}
+
+func (s *fakeOutboxStore) get(id string) *app.OutboxMessage {
+	for _, m := range s.messages {
+		if m.ID == id {
+			return m
+		}
+	}
+	return nil
+}
+
+// postCall records a request to slack.
+type postCall struct {
+	method   string
+	channel  string
+	ts       string
+	threadTS string
+	text     string
+	blocks   bool
+	color    string
+}
+
+// fakeSlackPoster implements both app.SlackMessagePoster and app.BlockMessagePoster.
+type fakeSlackPoster struct {
+	calls []postCall
+	// errs are returned in order before posts succeed.
+	errs []error
+}
+
+func (p *fakeSlackPoster) post(call postCall) (string, string, error) {
+	p.calls = append(p.calls, call)
+	if len(p.errs) > 0 {
+		err := p.errs[0]
+		p.errs = p.errs[1:]
+		return "", "", err
+	}
+	ts := call.ts
+	if ts == "" {
+		ts = fmt.Sprintf("1556000000.%06d", len(p.calls))
+	}
+	// slack returns channel id.
+	return "C" + call.channel, ts, nil
+}
+
+func (p *fakeSlackPoster) postOptions(method, channel, ts string, options []slack.MsgOption) (string, string, error) {
+	_, values, err := slack.UnsafeApplyMsgOptions("", channel, options...)
+	if err != nil {
+		return "", "", err
+	}
+	return p.post(postCall{method: method, channel: channel, ts: ts, threadTS: values.Get("thread_ts"), text: values.Get("text")})
+}
+
+func (p *fakeSlackPoster) PostMessage(channel string, options ...slack.MsgOption) (string, string, error) {
+	return p.postOptions("chat.postMessage", channel, "", options)
+}
+
+func (p *fakeSlackPoster) UpdateMessage(channel, timestamp string, options ...slack.MsgOption) (string, string, string, error) {
+	channel, ts, err := p.postOptions("chat.update", channel, timestamp, options)
+	return channel, ts, "", err
+}
+
+type fakeBlockPoster struct{ *fakeSlackPoster }
+
+func (p fakeBlockPoster) PostMessage(ctx context.Context, channel string, msg *app.BlockMessage) (string, string, error) {
+	return p.post(postCall{method: "chat.postMessage", channel: channel, text: msg.Text, blocks: true, color: msg.Color})
+}
+
+func (p fakeBlockPoster) PostReply(ctx context.Context, channel, threadTS string, msg *app.BlockMessage) (string, string, error) {
+	return p.post(postCall{method: "chat.postMessage", channel: channel, threadTS: threadTS, text: msg.Text, blocks: true, color: msg.Color})
+}
+
+func (p fakeBlockPoster) UpdateMessage(ctx context.Context, channel, ts string, msg *app.BlockMessage) (string, string, error) {
+	return p.post(postCall{method: "chat.update", channel: channel, ts: ts, text: msg.Text, blocks: true, color: msg.Color})
+}
+
+func newOutboxSender(messages ...*app.OutboxMessage) (*app.OutboxSender, *fakeOutboxStore, *fakeSlackPoster) {
+	now := time.Date(2019, 4, 1, 10, 0, 0, 0, time.UTC)
+	store := &fakeOutboxStore{now: func() time.Time { return now }}
+	for _, m := range messages {
+		_ = store.Enqueue(context.Background(), m)
+	}
+	poster := &fakeSlackPoster{}
+	return &app.OutboxSender{
+		Store:       store,
+		Client:      poster,
+		Poster:      fakeBlockPoster{poster},
+		MaxAttempts: 2,
+		BaseBackoff: time.Second,
+		MaxBackoff:  time.Minute,
+		Now:         store.now,
+	}, store, poster
+}
+
+func TestOutboxSender_SendDue(t *testing.T) {
+	sender, store, poster := newOutboxSender(
+		&app.OutboxMessage{ID: "1", Channel: "general", Text: "opened", ThreadKey: "pr/1", UpdateKey: "deploy/1"},
+		&app.OutboxMessage{ID: "2", Channel: "general", Text: "reviewed", Blocks: "[]", ThreadKey: "pr/1", Reply: true},
+		&app.OutboxMessage{ID: "3", Channel: "general", Text: "deployed", UpdateKey: "deploy/1"},
+		&app.OutboxMessage{ID: "4", Channel: "random", Text: "reviewed", ThreadKey: "pr/1", Reply: true},
+	)
+	sender.SendDue(context.Background())
+
+	for _, m := range store.messages {
+		if m.Status != app.OutboxSent {
+			t.Errorf("message %s status %s", m.ID, m.Status)
+		}
+	}
+	first := store.get("1")
+	if first.PostedChannel != "Cgeneral" || first.PostedTS == "" {
+		t.Fatalf("posted message of first is not recorded: %+v", first)
+	}
+	want := []postCall{
+		{method: "chat.postMessage", channel: "general", text: "opened"},
+		// reply is posted to the thread of the first message.
+		{method: "chat.postMessage", channel: "general", threadTS: first.PostedTS, text: "reviewed", blocks: true},
+		// message of the same update key replaces the posted message.
+		{method: "chat.update", channel: "Cgeneral", ts: first.PostedTS, text: "deployed"},
+		// thread is looked up per channel.
+		{method: "chat.postMessage", channel: "random", text: "reviewed"},
+	}
+	if len(poster.calls) != len(want) {
+		t.Fatalf("want %d calls, got %+v", len(want), poster.calls)
+	}
+	for i := range want {
+		if poster.calls[i] != want[i] {
+			t.Errorf("call %d: want %+v, got %+v", i, want[i], poster.calls[i])
+		}
+	}
+}
+
+func TestOutboxSender_SendDue_Failure(t *testing.T) {
+	sender, store, poster := newOutboxSender(&app.OutboxMessage{ID: "1", Channel: "general", Text: "opened"})
+	poster.errs = []error{errors.New("channel_not_found"), errors.New("channel_not_found")}
+
+	sender.SendDue(context.Background())
+	msg := store.get("1")
+	if msg.Status != app.OutboxPending || msg.Attempts != 1 || msg.LastError != "channel_not_found" {
+		t.Fatalf("first failure: %+v", msg)
+	}
+	if !msg.NextAttemptAt.After(sender.Now()) {
+		t.Errorf("next attempt %s is not delayed", msg.NextAttemptAt)
+	}
+
+	// message is not retried before next attempt.
+	sender.SendDue(context.Background())
+	if len(poster.calls) != 1 {
+		t.Fatalf("retried before backoff: %+v", poster.calls)
+	}
+
+	store.now = func() time.Time { return msg.NextAttemptAt }
+	sender.Now = store.now
+	sender.SendDue(context.Background())
+	if msg.Status != app.OutboxDead || msg.Attempts != 2 {
+		t.Errorf("want dead after max attempts, got %+v", msg)
+	}
+}
+
+func TestOutboxSender_SendDue_RateLimited(t *testing.T) {
+	sender, store, poster := newOutboxSender(
+		&app.OutboxMessage{ID: "1", Channel: "general", Text: "opened"},
+		&app.OutboxMessage{ID: "2", Channel: "general", Text: "closed"},
+	)
+	poster.errs = []error{&slack.RateLimitedError{RetryAfter: 30 * time.Second}}
+
+	sender.SendDue(context.Background())
+	msg := store.get("1")
+	if msg.Status != app.OutboxPending || msg.Attempts != 0 {
+		t.Errorf("rate limit counts as an attempt: %+v", msg)
+	}
+	if want := sender.Now().Add(30 * time.Second); !msg.NextAttemptAt.Equal(want) {
+		t.Errorf("next attempt want %s, got %s", want, msg.NextAttemptAt)
+	}
+	// sender pauses for every message.
+	if len(poster.calls) != 1 || store.get("2").Status != app.OutboxPending {
+		t.Errorf("sent while rate limited: %+v", poster.calls)
+	}
+}
+
+func TestOutboxSender_SendDue_BlocksRejected(t *testing.T) {
+	sender, store, poster := newOutboxSender(&app.OutboxMessage{
+		ID:          "1",
+		Channel:     "general",
+		Text:        "<@U1>",
+		Blocks:      "[]",
+		Attachments: []slack.Attachment{{Color: "#cb2431", Fallback: "push"}},
+	})
+	poster.errs = []error{&app.BlockError{Method: "chat.postMessage", Code: "invalid_blocks"}}
+
+	sender.SendDue(context.Background())
+	want := []postCall{
+		{method: "chat.postMessage", channel: "general", text: "<@U1>", blocks: true, color: "#cb2431"},
+		{method: "chat.postMessage", channel: "general", text: "<@U1>"},
+	}
+	if len(poster.calls) != len(want) {
+		t.Fatalf("want %d calls, got %+v", len(want), poster.calls)
+	}
+	for i := range want {
+		if poster.calls[i] != want[i] {
+			t.Errorf("call %d: want %+v, got %+v", i, want[i], poster.calls[i])
+		}
+	}
+	if msg := store.get("1"); msg.Status != app.OutboxSent {
+		t.Errorf("want sent by attachments, got %+v", msg)
+	}
+}
+
+func TestOutboxSender_SendDue_StaleUpdate(t *testing.T) {
+	sender, store, poster := newOutboxSender(
+		&app.OutboxMessage{ID: "1", Channel: "general", Text: "deploy", UpdateKey: "deployment:1"},
+		&app.OutboxMessage{ID: "2", Channel: "general", Text: "success", UpdateKey: "deployment:1", UpdateVersion: 20},
+		// pending status delivered late.
+		&app.OutboxMessage{ID: "3", Channel: "general", Text: "pending", UpdateKey: "deployment:1", UpdateVersion: 10},
+	)
+	sender.SendDue(context.Background())
+
+	if len(poster.calls) != 2 || poster.calls[1].text != "success" {
+		t.Fatalf("stale update is sent: %+v", poster.calls)
+	}
+	if msg := store.get("3"); msg.Status != app.OutboxSent {
+		t.Errorf("stale update should be done without posting, got %+v", msg)
+	}
+}
+
+func TestOutboxSender_SendDue_UpdateKeyLocked(t *testing.T) {
+	sender, store, poster := newOutboxSender(&app.OutboxMessage{ID: "1", Channel: "general", Text: "deploy", UpdateKey: "deployment:1"})
+	// another sender is posting message of the same deployment.
+	store.locked = map[string]bool{"general/deployment:1": true}
+
+	sender.SendDue(context.Background())
+	if len(poster.calls) != 0 {
+		t.Fatalf("message of locked key is sent: %+v", poster.calls)
+	}
+	msg := store.get("1")
+	if msg.Status != app.OutboxPending || msg.Attempts != 0 || !msg.NextAttemptAt.After(sender.Now()) {
+		t.Errorf("want retry later without counting attempt, got %+v", msg)
+	}
+
+	delete(store.locked, "general/deployment:1")
+	store.now = func() time.Time { return msg.NextAttemptAt }
+	sender.Now = store.now
+	sender.SendDue(context.Background())
+	if msg.Status != app.OutboxSent || len(store.locked) != 0 {
+		t.Errorf("want sent and unlocked, got %+v locked=%v", msg, store.locked)
+	}
+}
@@ -0,0 +1,136 @@
+package app
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/juju/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	OutputFormatJSON  = "json"
+	OutputFormatYAML  = "yaml"
+	OutputFormatCSV   = "csv"
+	OutputFormatTable = "table"
+
+	// outputs larger than this are uploaded as a file snippet instead of a message.
+	outputUploadThreshold = 3000
+)
+
+// Tabular is implemented by ls command results to be rendered as csv or table.
+type Tabular interface {
+	Header() []string
+	Rows() [][]string
+}
+
+// Output is a rendered machine readable ls result.
+type Output struct {
+	Format  string
+	Content string
+}
+
+// RenderOutput renders v in format. v must also implement Tabular for csv and table format.
+func RenderOutput(format string, v interface{}) (*Output, error) {
+	var content string
+	var err error
+	switch strings.ToLower(format) {
+	case OutputFormatJSON:
+		content, err = renderJSON(v)
+	case OutputFormatYAML:
+		content, err = renderYAML(v)
+	case OutputFormatCSV, OutputFormatTable:
+		t, ok := v.(Tabular)
+		if !ok {
+			return nil, errors.Errorf("%T does not support %s output", v, format)
+		}
+		if strings.ToLower(format) == OutputFormatCSV {
+			content, err = renderCSV(t)
+		} else {
+			content, err = renderTable(t)
+		}
+	default:
+		return nil, errors.Errorf("unsupported output format %s. available: json, yaml, csv, table", format)
+	}
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &Output{Format: strings.ToLower(format), Content: content}, nil
+}
+
+// Filetype returns slack snippet file type.
+func (o *Output) Filetype() string {
+	switch o.Format {
+	case OutputFormatJSON, OutputFormatYAML, OutputFormatCSV:
+		return o.Format
+	default:
+		return "text"
+	}
+}
+
+// Filename returns upload filename for the resource name.
+func (o *Output) Filename(resource string) string {
+	ext := o.Format
+	if o.Format == OutputFormatTable {
+		ext = "txt"
+	}
+	return fmt.Sprintf("%s.%s", resource, ext)
+}
+
+func (o *Output) shouldUpload() bool {
+	return len(o.Content) > outputUploadThreshold
+}
+
+func renderJSON(v interface{}) (string, error) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	return string(b) + "\n", nil
+}
+
+// renderYAML converts v via json to keep the same keys as json output.
+func renderYAML(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	var generic interface{}
+	if err := yaml.Unmarshal(b, &generic); err != nil {
+		return "", errors.Trace(err)
+	}
+	out, err := yaml.Marshal(generic)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	return string(out), nil
+}
+
+func renderCSV(t Tabular) (string, error) {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	if err := w.Write(t.Header()); err != nil {
+		return "", errors.Trace(err)
+	}
+	if err := w.WriteAll(t.Rows()); err != nil {
+		return "", errors.Trace(err)
+	}
+	return b.String(), nil
+}
+
+func renderTable(t Tabular) (string, error) {
+	var b bytes.Buffer
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(t.Header(), "\t"))
+	for _, row := range t.Rows() {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	if err := w.Flush(); err != nil {
+		return "", errors.Trace(err)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,125 @@
+package app_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/ymgyt/gobot/app"
+)
+
+type outputRow struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type outputRows []outputRow
+
+func (rs outputRows) Header() []string { return []string{"name", "email"} }
+
+func (rs outputRows) Rows() [][]string {
+	rows := make([][]string, len(rs))
+	for i, r := range rs {
+		rows[i] = []string{r.Name, r.Email}
+	}
+	return rows
+}
+
+func TestRenderOutput(t *testing.T) {
+	rows := outputRows{
+		{Name: "ymgyt", Email: "ymgyt@example.com"},
+		{Name: "octo cat", Email: "octocat@example.com"},
+	}
+	tests := map[string]struct {
+		format string
+		v      interface{}
+		want   *app.Output
+	}{
+		"json": {
+			format: "json",
+			v:      rows,
+			want: &app.Output{Format: "json", Content: `[
+  {
+    "name": "ymgyt",
+    "email": "ymgyt@example.com"
+  },
+  {
+    "name": "octo cat",
+    "email": "octocat@example.com"
+  }
+]
+`},
+		},
+		"yaml keeps json keys": {
+			format: "yaml",
+			v:      rows,
+			want: &app.Output{Format: "yaml", Content: `- email: ymgyt@example.com
+  name: ymgyt
+- email: octocat@example.com
+  name: octo cat
+`},
+		},
+		"csv": {
+			format: "csv",
+			v:      rows,
+			want:   &app.Output{Format: "csv", Content: "name,email\nymgyt,ymgyt@example.com\nocto cat,octocat@example.com\n"},
+		},
+		"table": {
+			format: "TABLE",
+			v:      rows,
+			want:   &app.Output{Format: "table", Content: "name      email\nymgyt     ymgyt@example.com\nocto cat  octocat@example.com\n"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := app.RenderOutput(tc.format, tc.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("(-want +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRenderOutput_Error(t *testing.T) {
+	tests := map[string]struct {
+		format string
+		v      interface{}
+	}{
+		"unsupported format": {format: "xml", v: outputRows{}},
+		"not tabular csv":    {format: "csv", v: []string{"ymgyt"}},
+		"not tabular table":  {format: "table", v: []string{"ymgyt"}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := app.RenderOutput(tc.format, tc.v); err == nil {
+				t.Error("want error")
+			}
+		})
+	}
+}
+
+func TestOutput_Filename(t *testing.T) {
+	tests := []struct {
+		format       string
+		wantFilename string
+		wantFiletype string
+	}{
+		{format: "json", wantFilename: "users.json", wantFiletype: "json"},
+		{format: "yaml", wantFilename: "users.yaml", wantFiletype: "yaml"},
+		{format: "csv", wantFilename: "users.csv", wantFiletype: "csv"},
+		{format: "table", wantFilename: "users.txt", wantFiletype: "text"},
+	}
+	for _, tc := range tests {
+		o := &app.Output{Format: tc.format}
+		if got := o.Filename("users"); got != tc.wantFilename {
+			t.Errorf("%s: filename want %s, got %s", tc.format, tc.wantFilename, got)
+		}
+		if got := o.Filetype(); got != tc.wantFiletype {
+			t.Errorf("%s: filetype want %s, got %s", tc.format, tc.wantFiletype, got)
+		}
+	}
+}
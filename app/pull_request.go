@@ -0,0 +1,209 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// pull request states.
+const (
+	PullRequestOpen   = "open"
+	PullRequestClosed = "closed"
+)
+
+// review states. github sends them in lower case on webhook.
+const (
+	ReviewApproved         = "approved"
+	ReviewChangesRequested = "changes_requested"
+	ReviewCommented        = "commented"
+	ReviewDismissed        = "dismissed"
+)
+
+// PullRequest is pull request state tracked from webhook events.
+type PullRequest struct {
+	// ID is owner/repo#number.
+	ID string `json:"id" bson:"_id"`
+	// Repository is lower cased full name of repository.
+	Repository         string   `json:"repository" bson:"repository"`
+	Number             int64    `json:"number" bson:"number"`
+	Title              string   `json:"title" bson:"title"`
+	URL                string   `json:"url" bson:"url"`
+	Author             string   `json:"author" bson:"author"`
+	State              string   `json:"state" bson:"state"`
+	Draft              bool     `json:"draft" bson:"draft"`
+	Labels             []string `json:"labels,omitempty" bson:"labels,omitempty"`
+	HeadSHA            string   `json:"head_sha" bson:"head_sha"`
+	HeadRef            string   `json:"head_ref" bson:"head_ref"`
+	BaseRef            string   `json:"base_ref" bson:"base_ref"`
+	RequestedReviewers []string `json:"requested_reviewers" bson:"requested_reviewers"`
+	RequestedTeams     []string `json:"requested_teams,omitempty" bson:"requested_teams,omitempty"`
+	// timestamps on github. ClosedAt and MergedAt are zero while pull request is open.
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+	ClosedAt  time.Time `json:"closed_at" bson:"closed_at"`
+	MergedAt  time.Time `json:"merged_at" bson:"merged_at"`
+	// GithubUpdatedAt orders events. event older than saved pull request is not saved.
+	GithubUpdatedAt time.Time `json:"github_updated_at" bson:"github_updated_at"`
+
+	// fields below are saved by their own store methods.
+
+	// Reviews are latest reviews keyed by reviewer login.
+	Reviews map[string]*PullRequestReview `json:"reviews,omitempty" bson:"reviews,omitempty"`
+	// ReviewRequestedAt is when review was requested last keyed by reviewer login.
+	ReviewRequestedAt map[string]time.Time `json:"review_requested_at,omitempty" bson:"review_requested_at,omitempty"`
+	// Checks are check runs and commit statuses keyed by PullRequestCheck.Key.
+	Checks map[string]*PullRequestCheck `json:"checks,omitempty" bson:"checks,omitempty"`
+	// Reminders are reminders of pending reviews keyed by reviewer login.
+	Reminders map[string]*ReviewReminderState `json:"reminders,omitempty" bson:"reminders,omitempty"`
+
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// Merged -
+func (pr *PullRequest) Merged() bool {
+	return !pr.MergedAt.IsZero()
+}
+
+// CIStatus aggregates checks of head commit. it returns empty string when no check is reported.
+func (pr *PullRequest) CIStatus() string {
+	status := ""
+	for _, c := range pr.Checks {
+		if c.SHA != pr.HeadSHA {
+			continue
+		}
+		switch {
+		case c.State == CIStateFailure:
+			return CIStateFailure
+		case c.State == CIStatePending:
+			status = CIStatePending
+		case status == "":
+			status = CIStateSuccess
+		}
+	}
+	return status
+}
+
+// PullRequestReview is the latest review of a reviewer.
+type PullRequestReview struct {
+	Reviewer    string    `json:"reviewer" bson:"reviewer"`
+	State       string    `json:"state" bson:"state"`
+	URL         string    `json:"url" bson:"url"`
+	SubmittedAt time.Time `json:"submitted_at" bson:"submitted_at"`
+	// LastBlocking is the latest approved, changes_requested or dismissed review.
+	// comments are also submitted as reviews, so they do not replace it.
+	LastBlocking *PullRequestReview `json:"last_blocking,omitempty" bson:"last_blocking,omitempty"`
+}
+
+// IsBlockingReview reports whether review state decides the result of review.
+func IsBlockingReview(state string) bool {
+	switch state {
+	case ReviewApproved, ReviewChangesRequested, ReviewDismissed:
+		return true
+	default:
+		return false
+	}
+}
+
+// aggregated ci states.
+const (
+	CIStatePending = "pending"
+	CIStateSuccess = "success"
+	CIStateFailure = "failure"
+)
+
+// PullRequestCheck is the latest result of a check run or commit status.
+type PullRequestCheck struct {
+	Name      string    `json:"name" bson:"name"`
+	SHA       string    `json:"sha" bson:"sha"`
+	State     string    `json:"state" bson:"state"`
+	URL       string    `json:"url,omitempty" bson:"url,omitempty"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+var checkKeyReplacer = strings.NewReplacer(".", "_", "$", "_")
+
+// Key is Name usable as document field name.
+func (c *PullRequestCheck) Key() string {
+	return checkKeyReplacer.Replace(c.Name)
+}
+
+// CIState converts status and conclusion of check run or state of commit status to aggregated ci state.
+func CIState(status, conclusion string) string {
+	if status != "" && status != "completed" {
+		return CIStatePending
+	}
+	switch c := strings.ToLower(conclusion); {
+	case IsCIFailure(c), c == "cancelled", c == "action_required":
+		return CIStateFailure
+	case c == "" || c == "pending":
+		return CIStatePending
+	default:
+		return CIStateSuccess
+	}
+}
+
+// LatestReview returns latest review of reviewer or nil.
+func (pr *PullRequest) LatestReview(reviewer string) *PullRequestReview {
+	if pr == nil {
+		return nil
+	}
+	return pr.Reviews[reviewer]
+}
+
+// LastBlockingReview returns latest approved, changes_requested or dismissed review of reviewer or nil.
+func (pr *PullRequest) LastBlockingReview(reviewer string) *PullRequestReview {
+	review := pr.LatestReview(reviewer)
+	switch {
+	case review == nil:
+		return nil
+	case review.LastBlocking != nil:
+		return review.LastBlocking
+	case IsBlockingReview(review.State):
+		return review
+	default:
+		return nil
+	}
+}
+
+// PullRequestID -
+func PullRequestID(repository string, number int64) string {
+	return fmt.Sprintf("%s#%d", strings.ToLower(repository), number)
+}
+
+// PullRequests -
+type PullRequests []*PullRequest
+
+// ListPullRequestsInput filters open pull requests.
+type ListPullRequestsInput struct {
+	// RequestedReviewer is github login of reviewer.
+	RequestedReviewer string
+	// Author is github login of author.
+	Author string
+}
+
+// PullRequestStore persists tracked pull requests.
+type PullRequestStore interface {
+	// GetPullRequest returns NotFound error when pull request is not tracked.
+	GetPullRequest(ctx context.Context, id string) (*PullRequest, error)
+	// SavePullRequest saves pull request except for reviews, review requests, checks and reminders.
+	// it returns ErrStalePullRequest when saved pull request is updated after GithubUpdatedAt.
+	SavePullRequest(context.Context, *PullRequest) error
+	// SaveReview replaces latest review of the reviewer. review older than saved one is ignored.
+	// blocking review is also saved as LastBlocking, which comments do not replace.
+	SaveReview(ctx context.Context, id string, review *PullRequestReview) error
+	// SaveReviewRequest records when review of the reviewer was requested.
+	SaveReviewRequest(ctx context.Context, id, reviewer string, at time.Time) error
+	// ClaimReminder records that reminder or escalation of the review requested at since is sent at.
+	// it returns false when it was already recorded, so that only one replica sends it.
+	ClaimReminder(ctx context.Context, id, reviewer string, action ReminderAction, since, at time.Time) (bool, error)
+	// SaveReminder records reminders sent to the reviewer.
+	SaveReminder(ctx context.Context, id, reviewer string, state *ReviewReminderState) error
+	// SaveCheck saves check to open pull requests whose head is sha.
+	// check of commit which is not head yet is applied when head moves to the commit by SavePullRequest.
+	SaveCheck(ctx context.Context, repository string, check *PullRequestCheck) error
+	// ListOpenPullRequests returns open pull requests matching all conditions of input.
+	ListOpenPullRequests(ctx context.Context, input *ListPullRequestsInput) (PullRequests, error)
+	// FindPullRequestsByHeadSHA returns open pull requests whose head is sha.
+	FindPullRequestsByHeadSHA(ctx context.Context, repository, sha string) (PullRequests, error)
+}
@@ -0,0 +1,73 @@
+package app_test
+
+import (
+	"testing"
+
+	"github.com/ymgyt/gobot/app"
+)
+
+func TestCIState(t *testing.T) {
+	tests := []struct {
+		status, conclusion string
+		want               string
+	}{
+		{status: "queued", want: app.CIStatePending},
+		{status: "in_progress", want: app.CIStatePending},
+		{status: "completed", conclusion: "success", want: app.CIStateSuccess},
+		{status: "completed", conclusion: "neutral", want: app.CIStateSuccess},
+		{status: "completed", conclusion: "timed_out", want: app.CIStateFailure},
+		{status: "completed", conclusion: "cancelled", want: app.CIStateFailure},
+		{conclusion: "pending", want: app.CIStatePending},
+		{conclusion: "error", want: app.CIStateFailure},
+		{conclusion: "success", want: app.CIStateSuccess},
+	}
+
+	for _, tc := range tests {
+		if got := app.CIState(tc.status, tc.conclusion); got != tc.want {
+			t.Errorf("status=%s conclusion=%s: want %s, got %s", tc.status, tc.conclusion, tc.want, got)
+		}
+	}
+}
+
+func TestPullRequest_CIStatus(t *testing.T) {
+	check := func(name, sha, state string) *app.PullRequestCheck {
+		return &app.PullRequestCheck{Name: name, SHA: sha, State: state}
+	}
+	tests := map[string]struct {
+		checks []*app.PullRequestCheck
+		want   string
+	}{
+		"no checks": {
+			want: "",
+		},
+		"success": {
+			checks: []*app.PullRequestCheck{check("test", "head", app.CIStateSuccess), check("lint", "head", app.CIStateSuccess)},
+			want:   app.CIStateSuccess,
+		},
+		"pending": {
+			checks: []*app.PullRequestCheck{check("test", "head", app.CIStateSuccess), check("lint", "head", app.CIStatePending)},
+			want:   app.CIStatePending,
+		},
+		"failure wins": {
+			checks: []*app.PullRequestCheck{check("test", "head", app.CIStatePending), check("lint", "head", app.CIStateFailure)},
+			want:   app.CIStateFailure,
+		},
+		"checks of old commit are ignored": {
+			checks: []*app.PullRequestCheck{check("test", "old", app.CIStateFailure), check("test.go1.12", "head", app.CIStateSuccess)},
+			want:   app.CIStateSuccess,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			pr := &app.PullRequest{HeadSHA: "head", Checks: map[string]*app.PullRequestCheck{}}
+			for _, c := range tc.checks {
+				// same layout as store, so check of old commit is overwritten by check of the same name.
+				pr.Checks[c.Key()] = c
+			}
+			if got := pr.CIStatus(); got != tc.want {
+				t.Errorf("want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/nlopes/slack"
+	"go.uber.org/zap"
+
+	"github.com/ymgyt/gobot/log"
+)
+
+const (
+	// commits more than this are summarized.
+	maxPushCommits = 10
+	maxCommitTitle = 72
+)
+
+// PushMsg is a push to branch.
+type PushMsg struct {
+	Branch          string
+	DefaultBranch   string
+	Pusher          string
+	PusherAvatarURL string
+	Forced          bool
+	Deleted         bool
+	Commits         []*PushCommit
+	CompareURL      string
+	RepoName        string
+	Event           *GithubEvent
+}
+
+// PushCommit -
+type PushCommit struct {
+	ID      string
+	Message string
+	Author  string
+	URL     string
+}
+
+// Alert reports whether push rewrites or deletes branch.
+func (m *PushMsg) Alert() bool {
+	return m.Forced || m.Deleted
+}
+
+func (m *PushMsg) commitLines() string {
+	lines := make([]string, 0, minInt(len(m.Commits), maxPushCommits)+1)
+	for i, c := range m.Commits {
+		if i == maxPushCommits {
+			lines = append(lines, fmt.Sprintf("and %d more commit(s)", len(m.Commits)-maxPushCommits))
+			break
+		}
+		title := c.Message
+		if idx := strings.Index(title, "\n"); idx >= 0 {
+			title = title[:idx]
+		}
+		if r := []rune(title); len(r) > maxCommitTitle {
+			title = string(r[:maxCommitTitle-1]) + "…"
+		}
+		lines = append(lines, fmt.Sprintf("• <%s|%s> %s - %s", c.URL, shortSHA(c.ID), slackTextEscaper.Replace(title), c.Author))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (m *PushMsg) notification() *notification {
+	return &notification{
+		Kind:  TemplatePush,
+		Event: m.Event,
+		Data: map[string]interface{}{
+			"Branch":     m.Branch,
+			"Pusher":     m.Pusher,
+			"Forced":     m.Forced,
+			"Deleted":    m.Deleted,
+			"Alert":      m.Alert(),
+			"Count":      len(m.Commits),
+			"Commits":    m.commitLines(),
+			"CompareURL": m.CompareURL,
+			"RepoName":   m.RepoName,
+			"Repository": m.Event.repository(),
+		},
+		Attachment: slack.Attachment{
+			Fallback:   fmt.Sprintf("push to %s", m.Branch),
+			AuthorName: m.Pusher,
+			AuthorIcon: m.PusherAvatarURL,
+			TitleLink:  m.CompareURL,
+			Footer:     "Github webhook " + footerSuffix(),
+			Ts:         slackTimestamp(),
+			Fields:     repositoryFields(m.RepoName),
+		},
+	}
+}
+
+// NotifyPush notifies pushes to default and protected branches.
+func (n *Notifier) NotifyPush(msg *PushMsg) error {
+	setting := n.repositorySetting(msg.Event.repository())
+	if !setting.IsProtectedBranch(msg.Branch, msg.DefaultBranch) {
+		log.Debug("notifier/ignore push to unprotected branch", zap.String("repository", msg.Event.repository()), zap.String("branch", msg.Branch))
+		return nil
+	}
+	if len(msg.Commits) == 0 && !msg.Alert() {
+		return nil
+	}
+	return n.notify(msg.notification())
+}
+
+// repositorySetting returns nil when repository is not configured.
+func (n *Notifier) repositorySetting(repository string) *RepositorySetting {
+	if n.RepositorySettings == nil || repository == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), subscriptionLookupTimeout)
+	defer cancel()
+	setting, err := n.RepositorySettings.GetRepositorySetting(ctx, strings.ToLower(repository))
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			log.Error("notifier/get repository setting", zap.String("repository", repository), zap.Error(err))
+		}
+		return nil
+	}
+	return setting
+}
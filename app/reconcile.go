@@ -0,0 +1,308 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/nlopes/slack"
+	"go.uber.org/zap"
+
+	"github.com/ymgyt/gobot/log"
+)
+
+// ReconcileFindingKind -
+type ReconcileFindingKind string
+
+const (
+	// slack account was deactivated. user should be soft deleted.
+	ReconcileDeactivated ReconcileFindingKind = "deactivated"
+	// no slack account has the email. user should be soft deleted.
+	ReconcileEmailNotFound ReconcileFindingKind = "email_not_found"
+	// email was changed on slack side. user email should be updated.
+	ReconcileEmailChanged ReconcileFindingKind = "email_changed"
+	// user without slack id matches slack account by email. slack id should be recorded.
+	ReconcileUnlinked ReconcileFindingKind = "unlinked"
+	// user without slack id matches no slack account. email may have been changed before the user was linked,
+	// so the user is not deleted automatically.
+	ReconcileUnmatched ReconcileFindingKind = "unmatched"
+)
+
+// ReconcileFinding is a drift between UserStore and slack workspace.
+type ReconcileFinding struct {
+	Kind      ReconcileFindingKind
+	User      *User
+	SlackUser *slack.User
+}
+
+func (f *ReconcileFinding) String() string {
+	switch f.Kind {
+	case ReconcileEmailChanged:
+		return fmt.Sprintf("%s: slack email changed %s -> %s", f.User.Github.UserName, f.User.Slack.Email, f.SlackUser.Profile.Email)
+	case ReconcileDeactivated:
+		return fmt.Sprintf("%s: slack account %s deactivated", f.User.Github.UserName, f.User.Slack.Email)
+	case ReconcileUnlinked:
+		return fmt.Sprintf("%s: slack account %s not linked (%s)", f.User.Github.UserName, f.User.Slack.Email, f.SlackUser.ID)
+	case ReconcileUnmatched:
+		return fmt.Sprintf("%s: slack account %s not found. email may have changed before linking", f.User.Github.UserName, f.User.Slack.Email)
+	default:
+		return fmt.Sprintf("%s: slack account %s not found", f.User.Github.UserName, f.User.Slack.Email)
+	}
+}
+
+// SuggestedCommand returns gobot command which fixes the finding.
+func (f *ReconcileFinding) SuggestedCommand() string {
+	update := func(slackProfile map[string]string) string {
+		b, _ := json.Marshal(map[string]interface{}{"slack": slackProfile})
+		return fmt.Sprintf("@gobot update user %s %s", f.User.Github.UserName, b)
+	}
+	switch f.Kind {
+	case ReconcileEmailChanged:
+		return update(map[string]string{"email": f.SlackUser.Profile.Email})
+	case ReconcileUnlinked:
+		return update(map[string]string{"id": f.SlackUser.ID})
+	case ReconcileUnmatched:
+		return update(map[string]string{"email": "CURRENT_SLACK_EMAIL"})
+	}
+	filter, _ := json.Marshal(map[string]interface{}{"github": map[string]string{"user_name": f.User.Github.UserName}})
+	return fmt.Sprintf("@gobot delete user %s", filter)
+}
+
+// Fixable reports whether Apply fixes the finding.
+func (f *ReconcileFinding) Fixable() bool {
+	return f.Kind != ReconcileUnmatched
+}
+
+func (f *ReconcileFinding) apply(ctx context.Context, us UserStore) error {
+	switch f.Kind {
+	case ReconcileEmailChanged:
+		return us.UpdateUser(ctx, &UpdateUserInput{
+			Filter: f.User.IdentificationFilter(),
+			User:   f.User.Merge(&User{Slack: SlackProfile{Email: f.SlackUser.Profile.Email}}),
+		})
+	case ReconcileUnlinked:
+		return us.UpdateUser(ctx, &UpdateUserInput{
+			Filter: f.User.IdentificationFilter(),
+			User:   f.User.Merge(&User{Slack: SlackProfile{ID: f.SlackUser.ID}}),
+		})
+	case ReconcileUnmatched:
+		return nil
+	}
+	_, err := us.DeleteUsers(ctx, &DeleteUsersInput{Filter: f.User.IdentificationFilter()})
+	return err
+}
+
+// ReconcileReport -
+type ReconcileReport struct {
+	Checked int
+	// Linked is number of unlinked users linked by scheduled reconciliation.
+	Linked   int
+	Findings []*ReconcileFinding
+}
+
+// LeaseStore grants a lease of key to one replica at a time.
+type LeaseStore interface {
+	// AcquireLease returns false while another replica holds the lease of key. lease expires after d.
+	AcquireLease(ctx context.Context, key string, d time.Duration) (bool, error)
+}
+
+// every replica runs scheduled reconciliation, so the one which acquires the lease runs it.
+const reconcileLeaseKey = "reconcile"
+
+// Reconciler compares UserStore with slack directory.
+type Reconciler struct {
+	UserStore       UserStore
+	AccountResolver *AccountResolver
+	// Leases guards scheduled reconciliation across replicas. every replica runs it when nil.
+	Leases LeaseStore
+	Client *slack.Client
+	// Poster posts report with blocks. report is posted with attachments when nil.
+	Poster *BlockPoster
+	// Channel to post scheduled reports. report is not posted when empty.
+	Channel string
+	// Interval of scheduled reconciliation. disabled when zero.
+	Interval time.Duration
+}
+
+// Run reconciles periodically and posts the report to Channel.
+func (r *Reconciler) Run(ctx context.Context) error {
+	if r.Interval <= 0 {
+		log.Info("reconcile/scheduled reconciliation disabled")
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	tick := time.NewTicker(r.Interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tick.C:
+			r.runOnce(ctx)
+		}
+	}
+}
+
+// runOnce reconciles and posts the report when the lease of this interval is acquired.
+func (r *Reconciler) runOnce(ctx context.Context) {
+	if r.Leases != nil {
+		// lease expires before the next tick of the holder.
+		ok, err := r.Leases.AcquireLease(ctx, reconcileLeaseKey, r.Interval-r.Interval/10)
+		if err != nil {
+			log.Error("reconcile", zap.String("msg", "acquire lease"), zap.Error(err))
+			return
+		}
+		if !ok {
+			log.Info("reconcile", zap.String("msg", "another replica holds lease"))
+			return
+		}
+	}
+	report, err := r.Reconcile(ctx)
+	if err == nil {
+		err = r.link(ctx, report)
+	}
+	if err != nil {
+		log.Error("reconcile", zap.Error(err))
+		return
+	}
+	log.Info("reconcile", zap.Int("checked", report.Checked), zap.Int("linked", report.Linked), zap.Int("findings", len(report.Findings)))
+	if len(report.Findings) > 0 && r.Channel != "" {
+		attachment := report.Attachment(false)
+		attachment.Footer += footerSuffix()
+		if err := r.post(ctx, attachment); err != nil {
+			log.Error("reconcile", zap.String("msg", "post report"), zap.Error(err))
+		}
+	}
+}
+
+func (r *Reconciler) post(ctx context.Context, attachment slack.Attachment) error {
+	if r.Poster == nil {
+		_, _, err := r.Client.PostMessage(r.Channel, slack.MsgOptionAttachments(attachment))
+		return errors.Trace(err)
+	}
+	msg, err := NewBlockMessage("", attachment)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	_, _, err = r.Poster.PostMessage(ctx, r.Channel, msg)
+	if IsBlockError(err) {
+		log.Warn("reconcile", zap.String("msg", "blocks rejected. fall back to attachments"), zap.Error(err))
+		_, _, err = r.Client.PostMessage(r.Channel, slack.MsgOptionAttachments(attachment))
+	}
+	return errors.Trace(err)
+}
+
+// Reconcile detects drifts. it does not modify UserStore.
+func (r *Reconciler) Reconcile(ctx context.Context) (*ReconcileReport, error) {
+	users, err := ListAllUsers(ctx, r.UserStore, false)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	slackUsers, err := r.AccountResolver.SlackUsers(true)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return NewReconcileReport(users, slackUsers), nil
+}
+
+// NewReconcileReport compares users with slack directory. users are matched by slack id first, then by email.
+func NewReconcileReport(users Users, slackUsers []slack.User) *ReconcileReport {
+	byID := make(map[string]*slack.User, len(slackUsers))
+	byEmail := make(map[string]*slack.User, len(slackUsers))
+	for i := range slackUsers {
+		su := &slackUsers[i]
+		if su.IsBot {
+			continue
+		}
+		byID[su.ID] = su
+		if su.Profile.Email != "" {
+			byEmail[strings.ToLower(su.Profile.Email)] = su
+		}
+	}
+
+	report := &ReconcileReport{Checked: len(users)}
+	for _, user := range users {
+		var su *slack.User
+		if user.Slack.ID != "" {
+			su = byID[user.Slack.ID]
+		}
+		if su == nil {
+			su = byEmail[strings.ToLower(user.Slack.Email)]
+		}
+
+		switch {
+		case su == nil && user.Slack.ID == "":
+			report.Findings = append(report.Findings, &ReconcileFinding{Kind: ReconcileUnmatched, User: user})
+		case su == nil:
+			report.Findings = append(report.Findings, &ReconcileFinding{Kind: ReconcileEmailNotFound, User: user})
+		case su.Deleted:
+			report.Findings = append(report.Findings, &ReconcileFinding{Kind: ReconcileDeactivated, User: user, SlackUser: su})
+		case user.Slack.ID == su.ID && !strings.EqualFold(user.Slack.Email, su.Profile.Email):
+			report.Findings = append(report.Findings, &ReconcileFinding{Kind: ReconcileEmailChanged, User: user, SlackUser: su})
+		case user.Slack.ID == "":
+			report.Findings = append(report.Findings, &ReconcileFinding{Kind: ReconcileUnlinked, User: user, SlackUser: su})
+		}
+	}
+	return report
+}
+
+// Apply fixes all findings of the report except for unmatched users, which need manual fix.
+func (r *Reconciler) Apply(ctx context.Context, report *ReconcileReport) error {
+	for _, f := range report.Findings {
+		if err := f.apply(ctx, r.UserStore); err != nil {
+			return errors.Annotatef(err, "%s", f)
+		}
+	}
+	return nil
+}
+
+// link applies unlinked findings, which are safe without review, and removes them from report.
+func (r *Reconciler) link(ctx context.Context, report *ReconcileReport) error {
+	findings := report.Findings[:0]
+	for _, f := range report.Findings {
+		if f.Kind != ReconcileUnlinked {
+			findings = append(findings, f)
+			continue
+		}
+		if err := f.apply(ctx, r.UserStore); err != nil {
+			return errors.Annotatef(err, "%s", f)
+		}
+		report.Linked++
+	}
+	report.Findings = findings
+	return nil
+}
+
+// Attachment renders report. when applied is false, commands to fix each finding are shown.
+func (report *ReconcileReport) Attachment(applied bool) slack.Attachment {
+	text := fmt.Sprintf("user directory reconciliation: %d checked, %d linked, %d drift(s)", report.Checked, report.Linked, len(report.Findings))
+	attachment := slack.Attachment{
+		Fallback: text,
+		Color:    slackColorGreen,
+		Pretext:  text,
+		Footer:   "reconcile ",
+		Ts:       slackTimestamp(),
+	}
+	if len(report.Findings) == 0 {
+		return attachment
+	}
+
+	attachment.Color = slackColorYellow
+	for _, f := range report.Findings {
+		field := slack.AttachmentField{Title: f.String()}
+		if applied && f.Fixable() {
+			field.Value = "fixed"
+		} else {
+			field.Value = LiteralizeLine(f.SuggestedCommand())
+		}
+		attachment.Fields = append(attachment.Fields, field)
+	}
+	if !applied {
+		attachment.Text = "to fix all: " + LiteralizeLine("@gobot reconcile users --apply")
+	}
+	return attachment
+}
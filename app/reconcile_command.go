@@ -0,0 +1,67 @@
+package app
+
+import (
+	"context"
+
+	"github.com/ymgyt/cli"
+)
+
+func NewReconcileCommand(b *CommandBuilder) *cli.Command {
+	cmd := &cli.Command{
+		Name:      "reconcile",
+		ShortDesc: "reconcile resources with external services",
+		LongDesc:  "@gobot reconcile <OPTIONS> <RESOURCE>",
+	}
+	return cmd.AddCommand(NewReconcileUsersCommand(b.Reconciler))
+}
+
+func NewReconcileUsersCommand(r *Reconciler) *cli.Command {
+	reconcileUsersCmd := &reconcileUsersCommand{}
+	cmd := &cli.Command{
+		Name:      "user",
+		Aliases:   []string{"users"},
+		ShortDesc: "reconcile users with slack workspace",
+		LongDesc: "reconcile users\n" +
+			"Usage: @gobot reconcile users <OPTIONS>\n\n" +
+			"# slackで無効化されたaccountやemailの変更を検出する\n" +
+			"@gobot reconcile users\n\n" +
+			"# 検出した差分をすべて修正する(soft delete, email update or slack id link)\n" +
+			"@gobot reconcile users --apply",
+		Run: reconcileUsersCmd.runFunc(r),
+	}
+	if err := cmd.Options().
+		Add(&cli.BoolOpt{Var: &reconcileUsersCmd.printHelp, Long: "help", Description: "print help"}).
+		Add(&cli.BoolOpt{Var: &reconcileUsersCmd.Apply, Long: "apply", Description: "fix all detected drifts"}).
+		Err; err != nil {
+		panic(err)
+	}
+	return cmd
+}
+
+type reconcileUsersCommand struct {
+	baseCommand
+	Apply bool
+}
+
+func (c *reconcileUsersCommand) runFunc(r *Reconciler) commandFunc {
+	return func(ctx context.Context, cmd *cli.Command, args []string) {
+		if c.printHelp {
+			cli.HelpFunc(cmd.Stdout, cmd)
+			return
+		}
+		sm := getSlackMessage(ctx)
+
+		report, err := r.Reconcile(ctx)
+		if err != nil {
+			sm.Fail(err)
+			return
+		}
+		if c.Apply {
+			if err := r.Apply(ctx, report); err != nil {
+				sm.Fail(err)
+				return
+			}
+		}
+		sm.PostAttachment(report.Attachment(c.Apply))
+	}
+}
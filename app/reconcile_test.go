@@ -0,0 +1,143 @@
+package app_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nlopes/slack"
+
+	"github.com/ymgyt/gobot/app"
+)
+
+func slackUser(id, email string, deleted bool) slack.User {
+	return slack.User{ID: id, Deleted: deleted, Profile: slack.UserProfile{Email: email}}
+}
+
+func linkedUser(name, email, slackID string) *app.User {
+	u := user(name, email)
+	u.Slack.ID = slackID
+	return u
+}
+
+func TestNewReconcileReport(t *testing.T) {
+	users := app.Users{
+		linkedUser("ok", "ok@example.com", "U1"),
+		linkedUser("changed", "old@example.com", "U2"),
+		linkedUser("deactivated", "deactivated@example.com", "U3"),
+		linkedUser("gone", "gone@example.com", "U4"),
+		user("unlinked", "Unlinked@example.com"),
+		user("unmatched", "renamed@example.com"),
+	}
+	slackUsers := []slack.User{
+		slackUser("U1", "ok@example.com", false),
+		slackUser("U2", "new@example.com", false),
+		slackUser("U3", "deactivated@example.com", true),
+		slackUser("U5", "unlinked@example.com", false),
+		slackUser("U6", "renamed-on-slack@example.com", false),
+		{ID: "B1", IsBot: true, Profile: slack.UserProfile{Email: "gone@example.com"}},
+	}
+
+	report := app.NewReconcileReport(users, slackUsers)
+
+	var got []string
+	for _, f := range report.Findings {
+		got = append(got, string(f.Kind)+" "+f.SuggestedCommand())
+	}
+	want := []string{
+		`email_changed @gobot update user changed {"slack":{"email":"new@example.com"}}`,
+		`deactivated @gobot delete user {"github":{"user_name":"deactivated"}}`,
+		`email_not_found @gobot delete user {"github":{"user_name":"gone"}}`,
+		`unlinked @gobot update user unlinked {"slack":{"id":"U5"}}`,
+		`unmatched @gobot update user unmatched {"slack":{"email":"CURRENT_SLACK_EMAIL"}}`,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want +got)\n%s", diff)
+	}
+	if report.Checked != len(users) {
+		t.Errorf("checked %d", report.Checked)
+	}
+}
+
+func TestReconciler_Apply(t *testing.T) {
+	ctx := context.Background()
+	us := &fakeUserStore{users: app.Users{
+		linkedUser("changed", "old@example.com", "U2"),
+		linkedUser("deactivated", "deactivated@example.com", "U3"),
+		user("unlinked", "unlinked@example.com"),
+		user("unmatched", "renamed@example.com"),
+	}}
+	report := app.NewReconcileReport(us.users, []slack.User{
+		slackUser("U2", "new@example.com", false),
+		slackUser("U3", "deactivated@example.com", true),
+		slackUser("U5", "unlinked@example.com", false),
+	})
+	before, _ := us.ListUsers(ctx, &app.ListUsersInput{IncludeDeleted: true})
+
+	// building report does not write users.
+	if diff := cmp.Diff(before.Users, us.users); diff != "" {
+		t.Fatalf("users modified (-want +got)\n%s", diff)
+	}
+
+	r := &app.Reconciler{UserStore: us}
+	if err := r.Apply(ctx, report); err != nil {
+		t.Fatal(err)
+	}
+	if got := us.get("changed").Slack.Email; got != "new@example.com" {
+		t.Errorf("changed email %s", got)
+	}
+	if !us.get("deactivated").IsDeleted() {
+		t.Error("deactivated user not deleted")
+	}
+	if got := us.get("unlinked").Slack.ID; got != "U5" {
+		t.Errorf("unlinked slack id %q", got)
+	}
+	if us.get("unmatched").IsDeleted() {
+		t.Error("unmatched user deleted")
+	}
+
+	fields := report.Attachment(true).Fields
+	if got := fields[len(fields)-1].Value; got == "fixed" {
+		t.Errorf("unmatched user reported as fixed")
+	}
+}
+
+// fakeLeaseStore grants lease of a key until it is released by test.
+type fakeLeaseStore map[string]bool
+
+func (s fakeLeaseStore) AcquireLease(ctx context.Context, key string, d time.Duration) (bool, error) {
+	if s[key] {
+		return false, nil
+	}
+	s[key] = true
+	return true, nil
+}
+
+func TestReconciler_RunOnceWithLease(t *testing.T) {
+	us := &fakeUserStore{users: app.Users{user("unlinked", "unlinked@example.com")}}
+	client := slack.New("token", slack.OptionHTTPClient(fakeSlackAPI{slackUser("U5", "unlinked@example.com", false)}))
+	leases := fakeLeaseStore{}
+	// replicas share lease store.
+	replica := func() *app.Reconciler {
+		return &app.Reconciler{
+			UserStore:       us,
+			AccountResolver: &app.AccountResolver{SlackClient: client, UserStore: us, Mu: &sync.Mutex{}},
+			Leases:          leases,
+			Interval:        time.Hour,
+		}
+	}
+
+	replica().RunOnce(context.Background())
+	if got := us.get("unlinked").Slack.ID; got != "U5" {
+		t.Fatalf("user is not linked by lease holder: %q", got)
+	}
+
+	// another replica does not run while lease is held.
+	us.get("unlinked").Slack.ID = ""
+	replica().RunOnce(context.Background())
+	if got := us.get("unlinked").Slack.ID; got != "" {
+		t.Errorf("user is linked without lease: %q", got)
+	}
+}
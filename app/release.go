@@ -0,0 +1,152 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nlopes/slack"
+)
+
+// ReleaseMsg is a published release.
+type ReleaseMsg struct {
+	Author          string
+	AuthorAvatarURL string
+	TagName         string
+	Name            string
+	Body            string // release note
+	URL             string
+	Prerelease      bool
+	RepoName        string
+	Event           *GithubEvent
+}
+
+func (m *ReleaseMsg) notification(n *Notifier) *notification {
+	return &notification{
+		Kind:  TemplateRelease,
+		Event: m.Event,
+		Data: map[string]interface{}{
+			"Author":     m.Author,
+			"TagName":    m.TagName,
+			"Name":       m.Name,
+			"Body":       n.formatBody(m.Body, m.URL),
+			"URL":        m.URL,
+			"Prerelease": m.Prerelease,
+			"RepoName":   m.RepoName,
+			"Repository": m.Event.repository(),
+		},
+		Attachment: slack.Attachment{
+			Fallback:   "release published",
+			AuthorName: m.Author,
+			AuthorIcon: m.AuthorAvatarURL,
+			TitleLink:  m.URL,
+			Footer:     "Github webhook " + footerSuffix(),
+			Ts:         slackTimestamp(),
+			Fields:     repositoryFields(m.RepoName),
+		},
+	}
+}
+
+// NotifyRelease -
+func (n *Notifier) NotifyRelease(msg *ReleaseMsg) error {
+	return n.notify(msg.notification(n))
+}
+
+// TagMsg is a created tag.
+type TagMsg struct {
+	Sender          string
+	SenderAvatarURL string
+	Tag             string
+	URL             string
+	RepoName        string
+	Event           *GithubEvent
+}
+
+func (m *TagMsg) notification() *notification {
+	return &notification{
+		Kind:  TemplateTag,
+		Event: m.Event,
+		Data: map[string]interface{}{
+			"Sender":     m.Sender,
+			"Tag":        m.Tag,
+			"URL":        m.URL,
+			"RepoName":   m.RepoName,
+			"Repository": m.Event.repository(),
+		},
+		Attachment: slack.Attachment{
+			Fallback:   "tag created",
+			AuthorName: m.Sender,
+			AuthorIcon: m.SenderAvatarURL,
+			TitleLink:  m.URL,
+			Footer:     "Github webhook " + footerSuffix(),
+			Ts:         slackTimestamp(),
+			Fields:     repositoryFields(m.RepoName),
+		},
+	}
+}
+
+// NotifyTag -
+func (n *Notifier) NotifyTag(msg *TagMsg) error {
+	return n.notify(msg.notification())
+}
+
+// DeploymentMsg is a deployment or its status. messages of a deployment are updated in place.
+type DeploymentMsg struct {
+	ID               int64
+	Environment      string
+	Ref              string
+	SHA              string
+	State            string // pending, queued, in_progress, success, failure, error, inactive
+	StatusID         int64  // id of deployment status. 0 means deployment event
+	Description      string
+	Creator          string
+	CreatorAvatarURL string
+	URL              string // log or target url
+	EnvironmentURL   string
+	RepoName         string
+	Event            *GithubEvent
+}
+
+func (m *DeploymentMsg) notification() *notification {
+	link := m.URL
+	if link == "" {
+		link = m.EnvironmentURL
+	}
+	return &notification{
+		Kind:  TemplateDeployment,
+		Event: m.Event,
+		Data: map[string]interface{}{
+			"ID":             m.ID,
+			"Environment":    m.Environment,
+			"Ref":            m.Ref,
+			"SHA":            shortSHA(m.SHA),
+			"State":          m.State,
+			"Description":    m.Description,
+			"Creator":        m.Creator,
+			"URL":            m.URL,
+			"EnvironmentURL": m.EnvironmentURL,
+			"RepoName":       m.RepoName,
+			"Repository":     m.Event.repository(),
+		},
+		Attachment: slack.Attachment{
+			Fallback:   fmt.Sprintf("deployment to %s %s", m.Environment, m.State),
+			AuthorName: m.Creator,
+			AuthorIcon: m.CreatorAvatarURL,
+			TitleLink:  link,
+			Footer:     "Github webhook " + footerSuffix(),
+			Ts:         slackTimestamp(),
+			Fields: append(repositoryFields(m.RepoName), slack.AttachmentField{
+				Title: "Environment",
+				Value: slackTextEscaper.Replace(m.Environment),
+				Short: true,
+			}),
+		},
+		UpdateKey: fmt.Sprintf("deployment:%s:%d", strings.ToLower(m.Event.repository()), m.ID),
+		// status ids increase, so the latest status wins. deployment itself has no status.
+		UpdateVersion: m.StatusID,
+	}
+}
+
+// NotifyDeployment posts deployment. later statuses of the deployment update the posted message.
+func (n *Notifier) NotifyDeployment(msg *DeploymentMsg) error {
+	return n.notify(msg.notification())
+}
@@ -0,0 +1,284 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/nlopes/slack"
+	"go.uber.org/zap"
+
+	"github.com/ymgyt/gobot/log"
+)
+
+// where reminders are posted.
+const (
+	ReminderToThread = "thread"
+	ReminderToDM     = "dm"
+)
+
+// DefaultWorkingHours is used when repository does not configure working hours.
+var DefaultWorkingHours = WorkingHours{Start: 9, End: 18}
+
+// WorkingHours is daily working time on weekdays in TimeZone.
+type WorkingHours struct {
+	Start int
+	End   int
+}
+
+// ParseWorkingHours parses hours like 9-18.
+func ParseWorkingHours(s string) (WorkingHours, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return WorkingHours{}, errors.Errorf("invalid working hours %q. format is START-END like 9-18", s)
+	}
+	start, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return WorkingHours{}, errors.Annotatef(err, "working hours=%s", s)
+	}
+	end, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return WorkingHours{}, errors.Annotatef(err, "working hours=%s", s)
+	}
+	if start < 0 || end > 24 || start >= end {
+		return WorkingHours{}, errors.Errorf("invalid working hours %q. 0 <= START < END <= 24", s)
+	}
+	return WorkingHours{Start: start, End: end}, nil
+}
+
+func (w WorkingHours) String() string {
+	return fmt.Sprintf("%d-%d", w.Start, w.End)
+}
+
+// Elapsed returns working time between from and to. weekends are skipped.
+func (w WorkingHours) Elapsed(from, to time.Time) time.Duration {
+	from, to = from.In(TimeZone), to.In(TimeZone)
+	var elapsed time.Duration
+	for day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, TimeZone); day.Before(to); day = day.AddDate(0, 0, 1) {
+		if wd := day.Weekday(); wd == time.Saturday || wd == time.Sunday {
+			continue
+		}
+		start, end := day.Add(time.Duration(w.Start)*time.Hour), day.Add(time.Duration(w.End)*time.Hour)
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if end.After(start) {
+			elapsed += end.Sub(start)
+		}
+	}
+	return elapsed
+}
+
+// workingHours returns configured working hours. invalid value falls back to default.
+func (s *RepositorySetting) workingHours() WorkingHours {
+	if s.WorkingHours == "" {
+		return DefaultWorkingHours
+	}
+	w, err := ParseWorkingHours(s.WorkingHours)
+	if err != nil {
+		return DefaultWorkingHours
+	}
+	return w
+}
+
+// ReviewReminderState records reminders sent to a reviewer.
+type ReviewReminderState struct {
+	RemindedAt  time.Time `json:"reminded_at" bson:"reminded_at"`
+	EscalatedAt time.Time `json:"escalated_at" bson:"escalated_at"`
+}
+
+// ReminderAction is what to do for pending review.
+type ReminderAction int
+
+// reminder actions.
+const (
+	ReminderNone ReminderAction = iota
+	ReminderRemind
+	ReminderEscalate
+)
+
+// ReminderAction decides action for review of reviewer. reminders and escalation are sent once per review request.
+// it also returns working hours elapsed since review was requested.
+func (s *RepositorySetting) ReminderAction(pr *PullRequest, reviewer string, now time.Time) (ReminderAction, time.Duration) {
+	if s == nil || (s.ReminderHours <= 0 && s.EscalationHours <= 0) {
+		return ReminderNone, 0
+	}
+	if pr.Draft || !pr.AwaitingReview(reviewer) {
+		return ReminderNone, 0
+	}
+	since := pr.ReviewRequestedSince(reviewer)
+	// without request time, any elapsed time would escalate immediately.
+	if since.IsZero() {
+		return ReminderNone, 0
+	}
+	elapsed := s.workingHours().Elapsed(since, now)
+
+	var reminded, escalated bool
+	if state := pr.Reminders[reviewer]; state != nil {
+		// reminders of previous request are ignored.
+		reminded = !state.RemindedAt.Before(since)
+		escalated = !state.EscalatedAt.Before(since)
+	}
+	switch {
+	case s.EscalationHours > 0 && elapsed >= time.Duration(s.EscalationHours)*time.Hour && !escalated:
+		return ReminderEscalate, elapsed
+	case s.ReminderHours > 0 && elapsed >= time.Duration(s.ReminderHours)*time.Hour && !reminded && !escalated:
+		return ReminderRemind, elapsed
+	default:
+		return ReminderNone, elapsed
+	}
+}
+
+// ReviewReminder re-pings reviewers of pending review requests periodically.
+// thresholds are read from repository settings through Notifier.
+type ReviewReminder struct {
+	PullRequests PullRequestStore
+	Notifier     *Notifier
+	// Interval of checking pending reviews. disabled when zero.
+	Interval time.Duration
+	Now      func() time.Time
+}
+
+// Run checks pending reviews every Interval.
+func (r *ReviewReminder) Run(ctx context.Context) error {
+	if r.Interval <= 0 {
+		log.Info("reminder/review reminders disabled")
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	tick := time.NewTicker(r.Interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tick.C:
+			if err := r.Remind(ctx); err != nil {
+				log.Error("reminder", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Remind sends reminders and escalations of open pull requests.
+func (r *ReviewReminder) Remind(ctx context.Context) error {
+	prs, err := r.PullRequests.ListOpenPullRequests(ctx, &ListPullRequestsInput{})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	settings := make(map[string]*RepositorySetting)
+	now := r.Now()
+	for _, pr := range prs {
+		setting, ok := settings[pr.Repository]
+		if !ok {
+			setting = r.Notifier.repositorySetting(pr.Repository)
+			settings[pr.Repository] = setting
+		}
+		for _, reviewer := range pr.RequestedReviewers {
+			action, elapsed := setting.ReminderAction(pr, reviewer, now)
+			if action == ReminderNone {
+				continue
+			}
+			// every replica runs reminder, so the one which claims the reminder sends it.
+			claimed, err := r.PullRequests.ClaimReminder(ctx, pr.ID, reviewer, action, pr.ReviewRequestedSince(reviewer), now)
+			if err != nil {
+				log.Error("reminder/claim", zap.String("pull_request", pr.ID), zap.String("reviewer", reviewer), zap.Error(err))
+				continue
+			}
+			if !claimed {
+				continue
+			}
+			msg := &ReviewReminderMsg{
+				PullRequest: pr,
+				Reviewer:    reviewer,
+				Hours:       int(elapsed / time.Hour),
+				Escalated:   action == ReminderEscalate,
+				DM:          setting.ReminderTo == ReminderToDM,
+			}
+			if err := r.Notifier.NotifyReviewReminder(msg); err != nil {
+				log.Error("reminder/notify", zap.String("pull_request", pr.ID), zap.String("reviewer", reviewer), zap.Error(err))
+				// claim is released to retry on next tick.
+				state := &ReviewReminderState{}
+				if prev := pr.Reminders[reviewer]; prev != nil {
+					*state = *prev
+				}
+				if err := r.PullRequests.SaveReminder(ctx, pr.ID, reviewer, state); err != nil {
+					log.Error("reminder/release", zap.String("pull_request", pr.ID), zap.String("reviewer", reviewer), zap.Error(err))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ReviewReminderMsg -
+type ReviewReminderMsg struct {
+	PullRequest *PullRequest
+	Reviewer    string
+	// Hours is working hours since review was requested.
+	Hours     int
+	Escalated bool
+	// DM sends reminder to reviewer by direct message. escalation is always posted to channel.
+	DM bool
+}
+
+func (m *ReviewReminderMsg) notification(n *Notifier) *notification {
+	pr := m.PullRequest
+	return &notification{
+		Kind: TemplateReviewReminder,
+		Event: &GithubEvent{
+			Name:       "review_reminder",
+			Category:   EventCategoryReviews,
+			Repository: pr.Repository,
+			Author:     pr.Author,
+			Base:       pr.BaseRef,
+			Labels:     pr.Labels,
+		},
+		Data: map[string]interface{}{
+			"Reviewer":        m.Reviewer,
+			"ReviewerMention": n.MentionByGithubUsername(m.Reviewer),
+			"Author":          pr.Author,
+			"AuthorMention":   n.MentionByGithubUsername(pr.Author),
+			"Hours":           m.Hours,
+			"Escalated":       m.Escalated,
+			"Number":          pr.Number,
+			"Title":           pr.Title,
+			"URL":             pr.URL,
+			"Repository":      pr.Repository,
+		},
+		Attachment: slack.Attachment{
+			Fallback:  "pull request review reminder",
+			TitleLink: pr.URL,
+			Footer:    "gobot reminder " + footerSuffix(),
+			Ts:        slackTimestamp(),
+			Fields:    repositoryFields(pr.Repository),
+		},
+		ThreadKey: pr.URL,
+		Reply:     !m.Escalated,
+	}
+}
+
+// NotifyReviewReminder posts reminder to pull request thread or reviewer, and escalation to channel.
+func (n *Notifier) NotifyReviewReminder(msg *ReviewReminderMsg) error {
+	nt := msg.notification(n)
+	if !msg.DM || msg.Escalated {
+		return n.notify(nt)
+	}
+	user, err := n.AccountResolver.SlackUserFromGithubUsername(msg.Reviewer)
+	if err != nil {
+		// reviewer who can not be resolved is reminded in thread.
+		log.Warn("notifier/resolve reviewer for dm", zap.String("reviewer", msg.Reviewer), zap.Error(err))
+		return n.notify(nt)
+	}
+	// rules suppress, route and add mentions as well as reminders in thread.
+	nt.ThreadKey, nt.Reply = "", false
+	nt.Channel, nt.Direct = user.ID, true
+	return n.notify(nt)
+}
@@ -0,0 +1,269 @@
+package app_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/ymgyt/gobot/app"
+)
+
+func TestParseWorkingHours(t *testing.T) {
+	tests := []struct {
+		desc    string
+		s       string
+		want    app.WorkingHours
+		wantErr bool
+	}{
+		{desc: "hours", s: "9-18", want: app.WorkingHours{Start: 9, End: 18}},
+		{desc: "spaces", s: " 10 - 19 ", want: app.WorkingHours{Start: 10, End: 19}},
+		{desc: "whole day", s: "0-24", want: app.WorkingHours{Start: 0, End: 24}},
+		{desc: "no separator", s: "9", wantErr: true},
+		{desc: "not number", s: "9-a", wantErr: true},
+		{desc: "reversed", s: "18-9", wantErr: true},
+		{desc: "out of range", s: "9-25", wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := app.ParseWorkingHours(tc.s)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("(-want +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWorkingHoursElapsed(t *testing.T) {
+	// 2019-06-07 is friday.
+	at := func(day, hour int) time.Time { return time.Date(2019, 6, day, hour, 0, 0, 0, app.TimeZone) }
+	w := app.WorkingHours{Start: 9, End: 18}
+	tests := []struct {
+		desc     string
+		from, to time.Time
+		want     time.Duration
+	}{
+		{desc: "same day", from: at(7, 10), to: at(7, 13), want: 3 * time.Hour},
+		{desc: "before working hours", from: at(7, 6), to: at(7, 10), want: time.Hour},
+		{desc: "after working hours", from: at(7, 17), to: at(7, 22), want: time.Hour},
+		{desc: "over weekend", from: at(7, 17), to: at(10, 11), want: 3 * time.Hour},
+		{desc: "weekend only", from: at(8, 10), to: at(9, 20), want: 0},
+		{desc: "reversed", from: at(7, 13), to: at(7, 10), want: 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := w.Elapsed(tc.from, tc.to); got != tc.want {
+				t.Errorf("want %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestRepositorySettingReminderAction(t *testing.T) {
+	// monday 9:00
+	requested := time.Date(2019, 6, 10, 9, 0, 0, 0, app.TimeZone)
+	setting := &app.RepositorySetting{ReminderHours: 4, EscalationHours: 8}
+	pr := func(modify func(pr *app.PullRequest)) *app.PullRequest {
+		pr := &app.PullRequest{
+			ID:                 app.PullRequestID("ymgyt/gobot", 1),
+			State:              app.PullRequestOpen,
+			RequestedReviewers: []string{"ymgyt"},
+			ReviewRequestedAt:  map[string]time.Time{"ymgyt": requested},
+			CreatedAt:          requested,
+		}
+		if modify != nil {
+			modify(pr)
+		}
+		return pr
+	}
+
+	tests := []struct {
+		desc    string
+		setting *app.RepositorySetting
+		pr      *app.PullRequest
+		now     time.Time
+		want    app.ReminderAction
+	}{
+		{desc: "not yet", setting: setting, pr: pr(nil), now: requested.Add(3 * time.Hour), want: app.ReminderNone},
+		{desc: "remind", setting: setting, pr: pr(nil), now: requested.Add(4 * time.Hour), want: app.ReminderRemind},
+		{desc: "escalate", setting: setting, pr: pr(nil), now: requested.Add(8 * time.Hour), want: app.ReminderEscalate},
+		{
+			desc:    "already reminded",
+			setting: setting,
+			pr: pr(func(pr *app.PullRequest) {
+				pr.Reminders = map[string]*app.ReviewReminderState{"ymgyt": {RemindedAt: requested.Add(4 * time.Hour)}}
+			}),
+			now:  requested.Add(5 * time.Hour),
+			want: app.ReminderNone,
+		},
+		{
+			desc:    "already escalated",
+			setting: setting,
+			pr: pr(func(pr *app.PullRequest) {
+				pr.Reminders = map[string]*app.ReviewReminderState{"ymgyt": {RemindedAt: requested.Add(4 * time.Hour), EscalatedAt: requested.Add(8 * time.Hour)}}
+			}),
+			now:  requested.Add(9 * time.Hour),
+			want: app.ReminderNone,
+		},
+		{
+			desc:    "re-requested after reminder",
+			setting: setting,
+			pr: pr(func(pr *app.PullRequest) {
+				pr.Reminders = map[string]*app.ReviewReminderState{"ymgyt": {RemindedAt: requested.Add(-time.Hour)}}
+			}),
+			now:  requested.Add(4 * time.Hour),
+			want: app.ReminderRemind,
+		},
+		{
+			desc:    "reviewed",
+			setting: setting,
+			pr: pr(func(pr *app.PullRequest) {
+				pr.Reviews = map[string]*app.PullRequestReview{"ymgyt": {Reviewer: "ymgyt", State: app.ReviewApproved, SubmittedAt: requested.Add(time.Hour)}}
+			}),
+			now:  requested.Add(8 * time.Hour),
+			want: app.ReminderNone,
+		},
+		{
+			desc:    "unknown request time",
+			setting: setting,
+			pr: pr(func(pr *app.PullRequest) {
+				pr.ReviewRequestedAt, pr.CreatedAt = nil, time.Time{}
+			}),
+			now:  requested.Add(8 * time.Hour),
+			want: app.ReminderNone,
+		},
+		{desc: "draft", setting: setting, pr: pr(func(pr *app.PullRequest) { pr.Draft = true }), now: requested.Add(8 * time.Hour), want: app.ReminderNone},
+		{desc: "disabled", setting: &app.RepositorySetting{}, pr: pr(nil), now: requested.Add(8 * time.Hour), want: app.ReminderNone},
+		{desc: "no setting", pr: pr(nil), now: requested.Add(8 * time.Hour), want: app.ReminderNone},
+	}
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got, _ := tc.setting.ReminderAction(tc.pr, "ymgyt", tc.now); got != tc.want {
+				t.Errorf("want %d, got %d", tc.want, got)
+			}
+		})
+	}
+}
+
+// fakeReminderStore shares reminder states between replicas like mongo.
+type fakeReminderStore struct {
+	app.PullRequestStore
+	mu  sync.Mutex
+	prs app.PullRequests
+}
+
+func (s *fakeReminderStore) ListOpenPullRequests(ctx context.Context, input *app.ListPullRequestsInput) (app.PullRequests, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prs := make(app.PullRequests, len(s.prs))
+	for i, pr := range s.prs {
+		copied := *pr
+		copied.Reminders = make(map[string]*app.ReviewReminderState)
+		for reviewer, state := range pr.Reminders {
+			st := *state
+			copied.Reminders[reviewer] = &st
+		}
+		prs[i] = &copied
+	}
+	return prs, nil
+}
+
+func (s *fakeReminderStore) ClaimReminder(ctx context.Context, id, reviewer string, action app.ReminderAction, since, at time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, pr := range s.prs {
+		if pr.ID != id {
+			continue
+		}
+		if pr.Reminders == nil {
+			pr.Reminders = make(map[string]*app.ReviewReminderState)
+		}
+		state := pr.Reminders[reviewer]
+		if state == nil {
+			state = &app.ReviewReminderState{}
+			pr.Reminders[reviewer] = state
+		}
+		if !state.EscalatedAt.Before(since) || (action == app.ReminderRemind && !state.RemindedAt.Before(since)) {
+			return false, nil
+		}
+		if action == app.ReminderRemind {
+			state.RemindedAt = at
+		} else {
+			state.EscalatedAt = at
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+type fakeRepositorySettings map[string]*app.RepositorySetting
+
+func (s fakeRepositorySettings) GetRepositorySetting(ctx context.Context, repository string) (*app.RepositorySetting, error) {
+	return s[repository], nil
+}
+
+func (s fakeRepositorySettings) SaveRepositorySetting(ctx context.Context, setting *app.RepositorySetting) error {
+	return nil
+}
+
+func (s fakeRepositorySettings) ListRepositorySettings(ctx context.Context) (app.RepositorySettings, error) {
+	return nil, nil
+}
+
+func TestReviewReminder_RemindOnce(t *testing.T) {
+	// monday 9:00
+	requested := time.Date(2019, 6, 10, 9, 0, 0, 0, app.TimeZone)
+	prs := &fakeReminderStore{prs: app.PullRequests{{
+		ID:                 app.PullRequestID("ymgyt/gobot", 1),
+		Repository:         "ymgyt/gobot",
+		State:              app.PullRequestOpen,
+		URL:                "https://github.com/ymgyt/gobot/pull/1",
+		RequestedReviewers: []string{"ymgyt"},
+		ReviewRequestedAt:  map[string]time.Time{"ymgyt": requested},
+	}}}
+	outbox := &fakeOutboxStore{}
+	now := requested.Add(4 * time.Hour)
+	notifier := &app.Notifier{
+		Outbox:             outbox,
+		AccountResolver:    &app.AccountResolver{UserStore: &fakeUserStore{}},
+		RepositorySettings: fakeRepositorySettings{"ymgyt/gobot": {ReminderHours: 4, EscalationHours: 8}},
+		Renderer:           app.RenderAttachments,
+		Channel:            "C1",
+		Now:                func() time.Time { return now },
+	}
+	outbox.now = notifier.Now
+
+	// replicas remind at the same time.
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := &app.ReviewReminder{PullRequests: prs, Notifier: notifier, Now: notifier.Now}
+			if err := r.Remind(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if len(outbox.messages) != 1 {
+		t.Fatalf("want 1 reminder, got %d", len(outbox.messages))
+	}
+
+	// escalation is also sent once.
+	now = requested.Add(8 * time.Hour)
+	for i := 0; i < 2; i++ {
+		r := &app.ReviewReminder{PullRequests: prs, Notifier: notifier, Now: notifier.Now}
+		if err := r.Remind(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(outbox.messages) != 2 {
+		t.Fatalf("want reminder and escalation, got %d messages", len(outbox.messages))
+	}
+}
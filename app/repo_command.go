@@ -0,0 +1,288 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/nlopes/slack"
+	"github.com/ymgyt/cli"
+)
+
+// clearSettingValue clears setting. empty value means not specified.
+const clearSettingValue = "-"
+
+func NewRepoCommand(b *CommandBuilder) *cli.Command {
+	cmd := &cli.Command{
+		Name:      "repo",
+		Aliases:   []string{"repos"},
+		ShortDesc: "operate repository settings",
+		LongDesc:  "@gobot repo <COMMAND> <OPTIONS> <ARGS>",
+	}
+	return cmd.
+		AddCommand(NewRepoSetCommand(b.RepositorySettings, b.UserStore)).
+		AddCommand(NewRepoLsCommand(b.RepositorySettings))
+}
+
+func NewRepoSetCommand(settings RepositorySettingStore, users UserStore) *cli.Command {
+	repoSetCmd := &repoSetCommand{}
+	cmd := &cli.Command{
+		Name:      "set",
+		ShortDesc: "set repository settings",
+		LongDesc: "set repository settings. unspecified settings are kept\n" +
+			"Usage: @gobot repo set <OWNER/REPO> <OPTIONS>\n\n" +
+			"# default branchに加えてrelease/*へのpushも通知\n" +
+			"@gobot repo set ymgyt/gobot --protected-branches=release/*,production\n" +
+			"# security alertをownerにmentionして#secに通知\n" +
+			"@gobot repo set ymgyt/gobot --owners=alice,bob --security-channel=#sec\n" +
+			"# 営業時間で8時間reviewされなければDMでremind, 16時間でchannelにescalation\n" +
+			"@gobot repo set ymgyt/gobot --reminder-hours=8 --escalation-hours=16 --reminder-to=dm --working-hours=10-19\n" +
+			"# 設定を削除\n" +
+			"@gobot repo set ymgyt/gobot --protected-branches=-",
+		Run: repoSetCmd.runFunc(settings, users),
+	}
+	if err := cmd.Options().
+		Add(&cli.BoolOpt{Var: &repoSetCmd.printHelp, Long: "help", Description: "print help"}).
+		Add(&cli.StringOpt{Var: &repoSetCmd.ProtectedBranches, Long: "protected-branches", Description: "comma separated branch globs. pushes to them are notified"}).
+		Add(&cli.StringOpt{Var: &repoSetCmd.Owners, Long: "owners", Description: "comma separated github logins of registered users"}).
+		Add(&cli.StringOpt{Var: &repoSetCmd.SecurityChannel, Long: "security-channel", Description: "channel of security alerts"}).
+		Add(&cli.StringOpt{Var: &repoSetCmd.ReminderHours, Long: "reminder-hours", Description: "working hours until pending review is reminded"}).
+		Add(&cli.StringOpt{Var: &repoSetCmd.EscalationHours, Long: "escalation-hours", Description: "working hours until pending review is escalated to channel"}).
+		Add(&cli.StringOpt{Var: &repoSetCmd.ReminderTo, Long: "reminder-to", Description: "where reminders are posted. thread or dm"}).
+		Add(&cli.StringOpt{Var: &repoSetCmd.WorkingHours, Long: "working-hours", Description: "working hours on weekdays like 9-18"}).
+		Err; err != nil {
+		panic(err)
+	}
+	return cmd
+}
+
+type repoSetCommand struct {
+	baseCommand
+	ProtectedBranches string
+	Owners            string
+	SecurityChannel   string
+	ReminderHours     string
+	EscalationHours   string
+	ReminderTo        string
+	WorkingHours      string
+}
+
+func (c *repoSetCommand) runFunc(settings RepositorySettingStore, users UserStore) commandFunc {
+	return func(ctx context.Context, cmd *cli.Command, args []string) {
+		if c.printHelp || len(args) != 1 {
+			cli.HelpFunc(cmd.Stdout, cmd)
+			return
+		}
+		sm := getSlackMessage(ctx)
+
+		repository, err := NormalizeRepository(args[0])
+		if err != nil {
+			sm.Fail(err)
+			return
+		}
+		setting, err := settings.GetRepositorySetting(ctx, repository)
+		if errors.IsNotFound(err) {
+			setting, err = &RepositorySetting{Repository: repository}, nil
+		}
+		if err != nil {
+			sm.Fail(err)
+			return
+		}
+		if err := c.apply(ctx, setting, users, sm.client); err != nil {
+			sm.Fail(err)
+			return
+		}
+		setting.UpdatedBy = sm.user.ID
+		if err := settings.SaveRepositorySetting(ctx, setting); err != nil {
+			sm.Fail(err)
+			return
+		}
+
+		text := fmt.Sprintf("setting of %s updated", repository)
+		sm.PostAttachment(slack.Attachment{
+			Fallback: text,
+			Color:    slackColorGreen,
+			Pretext:  slackEmojiOKHand + " " + text,
+			Fields:   repositorySettingFields(setting),
+		})
+	}
+}
+
+func (c *repoSetCommand) apply(ctx context.Context, setting *RepositorySetting, users UserStore, channels SlackChannelLister) error {
+	switch c.Owners {
+	case "":
+	case clearSettingValue:
+		setting.Owners = nil
+	default:
+		owners := ParseLogins(c.Owners)
+		for _, owner := range owners {
+			// owners must be registered to be mentioned.
+			_, err := users.FindUsers(ctx, &FindUsersInput{Limit: 1, Filter: &User{Github: GithubProfile{UserName: owner}}})
+			if IsUserNotFound(err) {
+				return errors.Errorf("github user %s is not registered", owner)
+			}
+			if err != nil {
+				return errors.Trace(err)
+			}
+		}
+		setting.Owners = owners
+	}
+
+	switch c.SecurityChannel {
+	case "":
+	case clearSettingValue:
+		setting.SecurityChannel = ""
+	default:
+		channel, err := ResolveSlackChannelID(channels, c.SecurityChannel)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		setting.SecurityChannel = channel
+	}
+
+	switch c.ProtectedBranches {
+	case "":
+	case clearSettingValue:
+		setting.ProtectedBranches = nil
+	default:
+		patterns, err := ParseBranchPatterns(c.ProtectedBranches)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		setting.ProtectedBranches = patterns
+	}
+
+	var err error
+	if setting.ReminderHours, err = c.hours("reminder-hours", c.ReminderHours, setting.ReminderHours); err != nil {
+		return errors.Trace(err)
+	}
+	if setting.EscalationHours, err = c.hours("escalation-hours", c.EscalationHours, setting.EscalationHours); err != nil {
+		return errors.Trace(err)
+	}
+	// reminder is never sent when escalation comes first.
+	if setting.ReminderHours > 0 && setting.EscalationHours > 0 && setting.EscalationHours <= setting.ReminderHours {
+		return errors.Errorf("escalation-hours %d must be greater than reminder-hours %d", setting.EscalationHours, setting.ReminderHours)
+	}
+
+	switch c.ReminderTo {
+	case "":
+	case clearSettingValue:
+		setting.ReminderTo = ""
+	case ReminderToThread, ReminderToDM:
+		setting.ReminderTo = c.ReminderTo
+	default:
+		return errors.Errorf("invalid reminder-to %q. thread or dm", c.ReminderTo)
+	}
+
+	switch c.WorkingHours {
+	case "":
+	case clearSettingValue:
+		setting.WorkingHours = ""
+	default:
+		w, err := ParseWorkingHours(c.WorkingHours)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		setting.WorkingHours = w.String()
+	}
+	return nil
+}
+
+// hours parses hours option. current is returned when option is not specified.
+func (c *repoSetCommand) hours(name, value string, current int) (int, error) {
+	switch value {
+	case "":
+		return current, nil
+	case clearSettingValue:
+		return 0, nil
+	}
+	hours, err := strconv.Atoi(value)
+	if err != nil || hours <= 0 {
+		return 0, errors.Errorf("invalid %s %q. positive integer required", name, value)
+	}
+	return hours, nil
+}
+
+func repositorySettingFields(s *RepositorySetting) []slack.AttachmentField {
+	fields := []slack.AttachmentField{
+		{Title: "Protected branches", Value: strings.Join(append([]string{"(default branch)"}, s.ProtectedBranches...), ", ")},
+	}
+	if len(s.Owners) > 0 {
+		fields = append(fields, slack.AttachmentField{Title: "Owners", Value: strings.Join(s.Owners, ", ")})
+	}
+	if s.SecurityChannel != "" {
+		fields = append(fields, slack.AttachmentField{Title: "Security channel", Value: s.SecurityChannel})
+	}
+	if s.ReminderHours > 0 || s.EscalationHours > 0 {
+		reminderTo := s.ReminderTo
+		if reminderTo == "" {
+			reminderTo = ReminderToThread
+		}
+		fields = append(fields, slack.AttachmentField{
+			Title: "Review reminders",
+			Value: fmt.Sprintf("remind %dh (%s), escalate %dh, working hours %s", s.ReminderHours, reminderTo, s.EscalationHours, s.workingHours()),
+		})
+	}
+	return fields
+}
+
+func NewRepoLsCommand(settings RepositorySettingStore) *cli.Command {
+	repoLsCmd := &repoLsCommand{}
+	cmd := &cli.Command{
+		Name:      "ls",
+		Aliases:   []string{"list"},
+		ShortDesc: "ls repository settings",
+		LongDesc: "ls repository settings\n" +
+			"Usage: @gobot repo ls <OPTIONS>",
+		Run: repoLsCmd.runFunc(settings),
+	}
+	if err := cmd.Options().
+		Add(&cli.BoolOpt{Var: &repoLsCmd.printHelp, Long: "help", Description: "print help"}).
+		Add(repoLsCmd.option()).
+		Err; err != nil {
+		panic(err)
+	}
+	return cmd
+}
+
+type repoLsCommand struct {
+	baseCommand
+	lsOutput
+}
+
+func (c *repoLsCommand) runFunc(settings RepositorySettingStore) commandFunc {
+	return func(ctx context.Context, cmd *cli.Command, args []string) {
+		if c.printHelp {
+			cli.HelpFunc(cmd.Stdout, cmd)
+			return
+		}
+		sm := getSlackMessage(ctx)
+
+		ss, err := settings.ListRepositorySettings(ctx)
+		if err != nil {
+			sm.Fail(err)
+			return
+		}
+		if c.enabled() {
+			c.post(sm, "repository_settings", ss)
+			return
+		}
+
+		text := fmt.Sprintf("%d repository setting(s) found", len(ss))
+		fields := make([]slack.AttachmentField, 0, len(ss))
+		for _, s := range ss {
+			for _, f := range repositorySettingFields(s) {
+				f.Title = s.Repository + " " + strings.ToLower(f.Title)
+				fields = append(fields, f)
+			}
+		}
+		sm.PostAttachment(slack.Attachment{
+			Fallback: text,
+			Color:    slackColorGreen,
+			Pretext:  text,
+			Fields:   fields,
+		})
+	}
+}
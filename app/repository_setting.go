@@ -0,0 +1,113 @@
+package app
+
+import (
+	"context"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+// RepositorySetting is per repository configuration of notifications.
+type RepositorySetting struct {
+	// Repository is lower cased owner/repo.
+	Repository string `json:"repository" bson:"_id"`
+	// ProtectedBranches are glob patterns of branches whose pushes are notified. default branch is always protected.
+	ProtectedBranches []string `json:"protected_branches,omitempty" bson:"protected_branches,omitempty"`
+	// Owners are github logins of users in UserStore. they are mentioned on security alerts.
+	Owners []string `json:"owners,omitempty" bson:"owners,omitempty"`
+	// SecurityChannel overrides channel of security alerts.
+	SecurityChannel string `json:"security_channel,omitempty" bson:"security_channel,omitempty"`
+	// ReminderHours is working hours until reviewers are reminded. zero disables reminders.
+	ReminderHours int `json:"reminder_hours,omitempty" bson:"reminder_hours,omitempty"`
+	// EscalationHours is working hours until pending review is escalated to channel. zero disables escalation.
+	EscalationHours int `json:"escalation_hours,omitempty" bson:"escalation_hours,omitempty"`
+	// ReminderTo is ReminderToThread or ReminderToDM. empty means thread.
+	ReminderTo string `json:"reminder_to,omitempty" bson:"reminder_to,omitempty"`
+	// WorkingHours is like 9-18. empty means DefaultWorkingHours.
+	WorkingHours string    `json:"working_hours,omitempty" bson:"working_hours,omitempty"`
+	UpdatedBy    string    `json:"updated_by" bson:"updated_by"`
+	UpdatedAt    time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// IsProtectedBranch reports whether branch is default branch or matches protected branches.
+func (s *RepositorySetting) IsProtectedBranch(branch, defaultBranch string) bool {
+	if branch != "" && branch == defaultBranch {
+		return true
+	}
+	if s == nil {
+		return false
+	}
+	for _, pattern := range s.ProtectedBranches {
+		if matched, _ := path.Match(pattern, branch); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseBranchPatterns parses comma separated branch globs.
+func ParseBranchPatterns(s string) ([]string, error) {
+	var patterns []string
+	for _, v := range strings.Split(s, ",") {
+		pattern := strings.TrimSpace(v)
+		if pattern == "" || stringsContain(patterns, pattern) {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, errors.Annotatef(err, "pattern=%s", pattern)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// ParseLogins parses comma separated github logins. leading @ is removed.
+func ParseLogins(s string) []string {
+	var logins []string
+	for _, v := range strings.Split(s, ",") {
+		login := strings.TrimPrefix(strings.TrimSpace(v), "@")
+		if login != "" && !stringsContain(logins, login) {
+			logins = append(logins, login)
+		}
+	}
+	return logins
+}
+
+// RepositorySettings -
+type RepositorySettings []*RepositorySetting
+
+// Header implements Tabular.
+func (ss RepositorySettings) Header() []string {
+	return []string{"repository", "protected_branches", "owners", "security_channel", "reminder_hours", "escalation_hours", "reminder_to", "working_hours", "updated_by", "updated_at"}
+}
+
+// Rows implements Tabular.
+func (ss RepositorySettings) Rows() [][]string {
+	rows := make([][]string, 0, len(ss))
+	for _, s := range ss {
+		rows = append(rows, []string{
+			s.Repository,
+			strings.Join(s.ProtectedBranches, ","),
+			strings.Join(s.Owners, ","),
+			s.SecurityChannel,
+			strconv.Itoa(s.ReminderHours),
+			strconv.Itoa(s.EscalationHours),
+			s.ReminderTo,
+			s.WorkingHours,
+			s.UpdatedBy,
+			s.UpdatedAt.In(TimeZone).Format(time.RFC3339),
+		})
+	}
+	return rows
+}
+
+// RepositorySettingStore persists repository settings.
+type RepositorySettingStore interface {
+	// GetRepositorySetting returns NotFound error when repository is not configured.
+	GetRepositorySetting(ctx context.Context, repository string) (*RepositorySetting, error)
+	SaveRepositorySetting(context.Context, *RepositorySetting) error
+	ListRepositorySettings(context.Context) (RepositorySettings, error)
+}
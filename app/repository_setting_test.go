@@ -0,0 +1,55 @@
+package app_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/ymgyt/gobot/app"
+)
+
+func TestRepositorySetting_IsProtectedBranch(t *testing.T) {
+	setting := &app.RepositorySetting{ProtectedBranches: []string{"release/*", "production"}}
+	tests := map[string]struct {
+		setting *app.RepositorySetting
+		branch  string
+		want    bool
+	}{
+		"default branch":             {setting: setting, branch: "master", want: true},
+		"glob":                       {setting: setting, branch: "release/v1", want: true},
+		"exact":                      {setting: setting, branch: "production", want: true},
+		"unprotected":                {setting: setting, branch: "feature/x", want: false},
+		"nested":                     {setting: setting, branch: "release/v1/hotfix", want: false},
+		"not configured":             {setting: nil, branch: "master", want: true},
+		"not configured unprotected": {setting: nil, branch: "release/v1", want: false},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tc.setting.IsProtectedBranch(tc.branch, "master"); got != tc.want {
+				t.Errorf("want %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseBranchPatterns(t *testing.T) {
+	got, err := app.ParseBranchPatterns("release/*, production,,release/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"release/*", "production"}, got); diff != "" {
+		t.Errorf("(-want +got)\n%s", diff)
+	}
+
+	if _, err := app.ParseBranchPatterns("release/["); err == nil {
+		t.Error("want error, got nil")
+	}
+}
+
+func TestParseLogins(t *testing.T) {
+	got := app.ParseLogins("@alice, bob,,alice")
+	if diff := cmp.Diff([]string{"alice", "bob"}, got); diff != "" {
+		t.Errorf("(-want +got)\n%s", diff)
+	}
+}
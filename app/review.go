@@ -0,0 +1,99 @@
+package app
+
+import (
+	"github.com/nlopes/slack"
+)
+
+// PRReviewDismissedMsg is notified to reviewer whose review is dismissed.
+type PRReviewDismissedMsg struct {
+	Reviewer    string
+	DismissedBy string
+	Number      int64
+	Title       string
+	URL         string
+	ReviewURL   string
+	ReviewState string
+	RepoName    string
+	Event       *GithubEvent
+}
+
+func (m *PRReviewDismissedMsg) notification(n *Notifier) *notification {
+	return &notification{
+		Kind:  TemplateReviewDismissed,
+		Event: m.Event,
+		Data: map[string]interface{}{
+			"Reviewer":        m.Reviewer,
+			"ReviewerMention": n.MentionByGithubUsername(m.Reviewer),
+			"DismissedBy":     m.DismissedBy,
+			"Number":          m.Number,
+			"Title":           m.Title,
+			"URL":             m.URL,
+			"ReviewURL":       m.ReviewURL,
+			"ReviewState":     m.ReviewState,
+			"RepoName":        m.RepoName,
+			"Repository":      m.Event.repository(),
+		},
+		Attachment: slack.Attachment{
+			Fallback:  "pull request review dismissed",
+			TitleLink: m.ReviewURL,
+			Footer:    "Github webhook " + footerSuffix(),
+			Ts:        slackTimestamp(),
+			Fields:    repositoryFields(m.RepoName),
+		},
+		ThreadKey: m.URL,
+	}
+}
+
+// NotifyPRReviewDismissed -
+func (n *Notifier) NotifyPRReviewDismissed(msg *PRReviewDismissedMsg) error {
+	return n.notify(msg.notification(n))
+}
+
+// PRReviewRequestRemovedMsg is notified to reviewer or team whose review request is withdrawn.
+type PRReviewRequestRemovedMsg struct {
+	Reviewer  string // empty when team is removed
+	Team      string
+	RemovedBy string
+	Number    int64
+	Title     string
+	URL       string
+	RepoName  string
+	Event     *GithubEvent
+}
+
+func (m *PRReviewRequestRemovedMsg) notification(n *Notifier) *notification {
+	mention := ""
+	if m.Reviewer != "" {
+		mention = n.MentionByGithubUsername(m.Reviewer)
+	} else {
+		mention = n.teamMention(m.Team)
+	}
+	return &notification{
+		Kind:  TemplateReviewRequestRemoved,
+		Event: m.Event,
+		Data: map[string]interface{}{
+			"Reviewer":        m.Reviewer,
+			"Team":            m.Team,
+			"ReviewerMention": mention,
+			"RemovedBy":       m.RemovedBy,
+			"Number":          m.Number,
+			"Title":           m.Title,
+			"URL":             m.URL,
+			"RepoName":        m.RepoName,
+			"Repository":      m.Event.repository(),
+		},
+		Attachment: slack.Attachment{
+			Fallback:  "pull request review request removed",
+			TitleLink: m.URL,
+			Footer:    "Github webhook " + footerSuffix(),
+			Ts:        slackTimestamp(),
+			Fields:    repositoryFields(m.RepoName),
+		},
+		ThreadKey: m.URL,
+	}
+}
+
+// NotifyPRReviewRequestRemoved -
+func (n *Notifier) NotifyPRReviewRequestRemoved(msg *PRReviewRequestRemovedMsg) error {
+	return n.notify(msg.notification(n))
+}
@@ -0,0 +1,103 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AwaitingReview reports whether reviewer is requested and has not reviewed since the request.
+func (pr *PullRequest) AwaitingReview(reviewer string) bool {
+	if pr.State != PullRequestOpen || !stringsContain(pr.RequestedReviewers, reviewer) {
+		return false
+	}
+	review := pr.LatestReview(reviewer)
+	return review == nil || review.SubmittedAt.Before(pr.ReviewRequestedSince(reviewer))
+}
+
+// ReviewRequestedSince returns when review of reviewer was requested. creation time is used for untracked requests.
+// it returns zero time when neither is known.
+func (pr *PullRequest) ReviewRequestedSince(reviewer string) time.Time {
+	if at, ok := pr.ReviewRequestedAt[reviewer]; ok {
+		return at
+	}
+	return pr.CreatedAt
+}
+
+// ReviewQueue is pull requests waiting on a github user and pull requests of the user waiting on others.
+type ReviewQueue struct {
+	User string
+	// Requested are pull requests the user is requested to review. oldest request first.
+	Requested PullRequests
+	// Waiting are pull requests of the user waiting on reviewers. oldest first.
+	Waiting PullRequests
+}
+
+// NewReviewQueue builds queue of user from open pull requests the user is requested to review and the user authored.
+func NewReviewQueue(user string, requested, authored PullRequests) *ReviewQueue {
+	q := &ReviewQueue{User: user}
+	for _, pr := range requested {
+		if pr.AwaitingReview(user) {
+			q.Requested = append(q.Requested, pr)
+		}
+	}
+	for _, pr := range authored {
+		if pr.State == PullRequestOpen && !pr.Draft && len(pr.RequestedReviewers)+len(pr.RequestedTeams) > 0 {
+			q.Waiting = append(q.Waiting, pr)
+		}
+	}
+	sort.SliceStable(q.Requested, func(i, j int) bool {
+		return q.Requested[i].ReviewRequestedSince(user).Before(q.Requested[j].ReviewRequestedSince(user))
+	})
+	sort.SliceStable(q.Waiting, func(i, j int) bool {
+		return q.Waiting[i].CreatedAt.Before(q.Waiting[j].CreatedAt)
+	})
+	return q
+}
+
+// RequestedLines renders Requested as slack mrkdwn list.
+func (q *ReviewQueue) RequestedLines(now time.Time) []string {
+	lines := make([]string, len(q.Requested))
+	for i, pr := range q.Requested {
+		age := "?"
+		if since := pr.ReviewRequestedSince(q.User); !since.IsZero() {
+			age = formatAge(now.Sub(since))
+		}
+		lines[i] = fmt.Sprintf("• %s %s by %s", pr.slackLink(), age, pr.Author) + pr.ciSuffix()
+	}
+	return lines
+}
+
+// WaitingLines renders Waiting as slack mrkdwn list.
+func (q *ReviewQueue) WaitingLines(now time.Time) []string {
+	lines := make([]string, len(q.Waiting))
+	for i, pr := range q.Waiting {
+		reviewers := append(append([]string{}, pr.RequestedReviewers...), pr.RequestedTeams...)
+		lines[i] = fmt.Sprintf("• %s %s waiting on %s", pr.slackLink(), formatAge(now.Sub(pr.CreatedAt)), strings.Join(reviewers, ", ")) + pr.ciSuffix()
+	}
+	return lines
+}
+
+func (pr *PullRequest) slackLink() string {
+	return fmt.Sprintf("<%s|%s> %s", pr.URL, pr.ID, pr.Title)
+}
+
+func (pr *PullRequest) ciSuffix() string {
+	if status := pr.CIStatus(); status != "" {
+		return " (CI " + status + ")"
+	}
+	return ""
+}
+
+// formatAge formats duration in the largest unit. ex. 3d, 5h, 12m
+func formatAge(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	default:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+}
@@ -0,0 +1,61 @@
+package app_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/ymgyt/gobot/app"
+)
+
+func TestNewReviewQueue(t *testing.T) {
+	base := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	pr := func(number int64, author string, created time.Time, reviewers ...string) *app.PullRequest {
+		return &app.PullRequest{
+			ID:                 app.PullRequestID("ymgyt/gobot", number),
+			URL:                "https://github.com/ymgyt/gobot/pull/1",
+			Title:              "fix",
+			Author:             author,
+			State:              app.PullRequestOpen,
+			RequestedReviewers: reviewers,
+			CreatedAt:          created,
+		}
+	}
+
+	old := pr(1, "octocat", base, "ymgyt")
+	recent := pr(2, "octocat", base.Add(-48*time.Hour), "ymgyt")
+	recent.ReviewRequestedAt = map[string]time.Time{"ymgyt": base.Add(time.Hour)}
+	reviewed := pr(3, "octocat", base, "ymgyt")
+	reviewed.Reviews = map[string]*app.PullRequestReview{"ymgyt": {Reviewer: "ymgyt", State: app.ReviewCommented, SubmittedAt: base.Add(time.Minute)}}
+	rerequested := pr(4, "octocat", base, "ymgyt")
+	rerequested.ReviewRequestedAt = map[string]time.Time{"ymgyt": base.Add(2 * time.Hour)}
+	rerequested.Reviews = map[string]*app.PullRequestReview{"ymgyt": {Reviewer: "ymgyt", State: app.ReviewChangesRequested, SubmittedAt: base.Add(time.Minute)}}
+
+	mine := pr(5, "ymgyt", base, "octocat")
+	draft := pr(6, "ymgyt", base, "octocat")
+	draft.Draft = true
+	noReviewer := pr(7, "ymgyt", base)
+
+	q := app.NewReviewQueue("ymgyt", app.PullRequests{rerequested, recent, reviewed, old}, app.PullRequests{draft, noReviewer, mine})
+
+	ids := func(prs app.PullRequests) []string {
+		var ids []string
+		for _, pr := range prs {
+			ids = append(ids, pr.ID)
+		}
+		return ids
+	}
+	if diff := cmp.Diff([]string{"ymgyt/gobot#1", "ymgyt/gobot#2", "ymgyt/gobot#4"}, ids(q.Requested)); diff != "" {
+		t.Errorf("requested (-want +got)\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"ymgyt/gobot#5"}, ids(q.Waiting)); diff != "" {
+		t.Errorf("waiting (-want +got)\n%s", diff)
+	}
+
+	now := base.Add(50 * time.Hour)
+	want := []string{"• <https://github.com/ymgyt/gobot/pull/1|ymgyt/gobot#5> fix 2d waiting on octocat"}
+	if diff := cmp.Diff(want, q.WaitingLines(now)); diff != "" {
+		t.Errorf("waiting lines (-want +got)\n%s", diff)
+	}
+}
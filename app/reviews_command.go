@@ -0,0 +1,101 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/nlopes/slack"
+	"github.com/ymgyt/cli"
+)
+
+func NewReviewsCommand(b *CommandBuilder) *cli.Command {
+	reviewsCmd := &reviewsCommand{}
+	cmd := &cli.Command{
+		Name:      "reviews",
+		Aliases:   []string{"review"},
+		ShortDesc: "ls pull requests waiting on review",
+		LongDesc: "ls pull requests waiting on your review and your pull requests waiting on others\n" +
+			"Usage: @gobot reviews <OPTIONS>\n\n" +
+			"# 他のuserのqueueを表示. github user nameも指定できる\n" +
+			"@gobot reviews --user @ymgyt",
+		Run: reviewsCmd.runFunc(b.AccountResolver, b.PullRequests),
+	}
+	if err := cmd.Options().
+		Add(&cli.BoolOpt{Var: &reviewsCmd.printHelp, Long: "help", Description: "print help"}).
+		Add(&cli.StringOpt{Var: &reviewsCmd.User, Long: "user", Description: "slack user or github user name"}).
+		Err; err != nil {
+		panic(err)
+	}
+	return cmd
+}
+
+type reviewsCommand struct {
+	baseCommand
+	User string
+}
+
+func (c *reviewsCommand) runFunc(resolver *AccountResolver, prs PullRequestStore) commandFunc {
+	return func(ctx context.Context, cmd *cli.Command, args []string) {
+		if c.printHelp {
+			cli.HelpFunc(cmd.Stdout, cmd)
+			return
+		}
+		sm := getSlackMessage(ctx)
+
+		login, err := c.githubUser(resolver, sm.user.ID)
+		if err != nil {
+			sm.Fail(err)
+			return
+		}
+		requested, err := prs.ListOpenPullRequests(ctx, &ListPullRequestsInput{RequestedReviewer: login})
+		if err != nil {
+			sm.Fail(err)
+			return
+		}
+		authored, err := prs.ListOpenPullRequests(ctx, &ListPullRequestsInput{Author: login})
+		if err != nil {
+			sm.Fail(err)
+			return
+		}
+		q := NewReviewQueue(login, requested, authored)
+
+		now := Now()
+		text := fmt.Sprintf("%d pull request(s) waiting on %s", len(q.Requested), login)
+		sm.PostAttachment(slack.Attachment{
+			Fallback: text,
+			Color:    slackColorGreen,
+			Pretext:  text,
+			Fields: []slack.AttachmentField{
+				{Title: "Review requested", Value: linesOrNone(q.RequestedLines(now))},
+				{Title: "Waiting on others", Value: linesOrNone(q.WaitingLines(now))},
+			},
+		})
+	}
+}
+
+// githubUser resolves --user or caller to github user name.
+func (c *reviewsCommand) githubUser(resolver *AccountResolver, callerID string) (string, error) {
+	slackUserID := callerID
+	if c.User != "" {
+		ids, err := ParseSlackUsers([]string{c.User})
+		if err != nil {
+			// not a slack mention. it is github user name.
+			return strings.TrimPrefix(c.User, "@"), nil
+		}
+		slackUserID = ids[0]
+	}
+	login, err := resolver.GithubUsernameFromSlackUser(slackUserID)
+	if IsUserNotFound(err) {
+		return "", errors.Errorf("github user of <@%s> is not registered", slackUserID)
+	}
+	return login, errors.Trace(err)
+}
+
+func linesOrNone(lines []string) string {
+	if len(lines) == 0 {
+		return "none"
+	}
+	return strings.Join(lines, "\n")
+}
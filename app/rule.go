@@ -0,0 +1,227 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+// RuleAction -
+type RuleAction string
+
+const (
+	// RuleRoute notifies additional channel.
+	RuleRoute RuleAction = "route"
+	// RuleSuppress stops evaluation and drops notifications to default and subscribed channels.
+	RuleSuppress RuleAction = "suppress"
+	// RuleMention adds mentions to notification.
+	RuleMention RuleAction = "mention"
+)
+
+// Rule is a notification rule. rules with higher priority are evaluated first.
+//
+//	repo=ymgyt/*          glob match on repository(owner/repo)
+//	author=dependabot*    glob match on pull request or issue author
+//	label=security        glob match on any label
+//	base=release/*        glob match on base branch or pushed branch
+//	event=pulls           glob match on category(pulls,reviews,issues,comments,ci,releases,deployments,pushes,security) or X-GitHub-Event
+//	action=opened         glob match on action
+//	env=prod*             glob match on deployment environment
+type Rule struct {
+	ID         string          `json:"id" bson:"_id"`
+	Priority   int             `json:"priority" bson:"priority"`
+	Conditions []RuleCondition `json:"conditions" bson:"conditions"`
+	Action     RuleAction      `json:"action" bson:"action"`
+	// Channel is routed channel for RuleRoute.
+	Channel string `json:"channel,omitempty" bson:"channel,omitempty"`
+	// Mentions are slack mentions(<@U123>) or github logins for RuleMention.
+	Mentions  []string  `json:"mentions,omitempty" bson:"mentions,omitempty"`
+	CreatedBy string    `json:"created_by" bson:"created_by"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
+
+// RuleCondition is a single glob match of rule.
+type RuleCondition struct {
+	Key     string `json:"key" bson:"key"`
+	Pattern string `json:"pattern" bson:"pattern"`
+}
+
+var ruleConditionKeys = []string{"repo", "author", "label", "base", "event", "action", "env"}
+
+// ParseRuleConditions parses key=glob terms.
+func ParseRuleConditions(terms []string) ([]RuleCondition, error) {
+	conditions := make([]RuleCondition, 0, len(terms))
+	for _, term := range terms {
+		idx := strings.Index(term, "=")
+		if idx <= 0 {
+			return nil, errors.Errorf("invalid condition %q. condition must be key=pattern", term)
+		}
+		c := RuleCondition{Key: strings.ToLower(term[:idx]), Pattern: term[idx+1:]}
+		if !stringsContain(ruleConditionKeys, c.Key) {
+			return nil, errors.Errorf("unknown condition key %q. available keys are %s", c.Key, strings.Join(ruleConditionKeys, ","))
+		}
+		if _, err := path.Match(c.Pattern, ""); err != nil {
+			return nil, errors.Annotatef(err, "pattern=%s", c.Pattern)
+		}
+		conditions = append(conditions, c)
+	}
+	return conditions, nil
+}
+
+// Match reports whether all conditions match event.
+func (r *Rule) Match(event *GithubEvent) bool {
+	for _, c := range r.Conditions {
+		if !c.match(event) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c RuleCondition) match(event *GithubEvent) bool {
+	switch c.Key {
+	case "repo":
+		return globMatch(c.Pattern, event.Repository)
+	case "author":
+		return globMatch(c.Pattern, event.Author)
+	case "label":
+		for _, label := range event.Labels {
+			if globMatch(c.Pattern, label) {
+				return true
+			}
+		}
+		return false
+	case "base":
+		return globMatch(c.Pattern, event.Base)
+	case "event":
+		return globMatch(c.Pattern, string(event.Category)) || globMatch(c.Pattern, event.Name)
+	case "action":
+		return globMatch(c.Pattern, event.Action)
+	case "env":
+		return globMatch(c.Pattern, event.Environment)
+	default:
+		return false
+	}
+}
+
+// globMatch matches case insensitively. pattern is validated on parse.
+func globMatch(pattern, s string) bool {
+	matched, _ := path.Match(strings.ToLower(pattern), strings.ToLower(s))
+	return matched
+}
+
+func stringsContain(ss []string, s string) bool {
+	for i := range ss {
+		if ss[i] == s {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns rule in command syntax.
+func (r *Rule) String() string {
+	terms := make([]string, 0, len(r.Conditions)+1)
+	for _, c := range r.Conditions {
+		terms = append(terms, c.Key+"="+c.Pattern)
+	}
+	switch r.Action {
+	case RuleRoute:
+		terms = append(terms, "--route="+r.Channel)
+	case RuleSuppress:
+		terms = append(terms, "--suppress")
+	case RuleMention:
+		terms = append(terms, "--mention="+strings.Join(r.Mentions, ","))
+	}
+	return strings.Join(terms, " ")
+}
+
+// Rules -
+type Rules []*Rule
+
+// Header implements Tabular.
+func (rs Rules) Header() []string {
+	return []string{"id", "priority", "rule", "created_by", "created_at"}
+}
+
+// Rows implements Tabular.
+func (rs Rules) Rows() [][]string {
+	rows := make([][]string, 0, len(rs))
+	for _, r := range rs {
+		rows = append(rows, []string{
+			r.ID,
+			fmt.Sprintf("%d", r.Priority),
+			r.String(),
+			r.CreatedBy,
+			r.CreatedAt.In(TimeZone).Format(time.RFC3339),
+		})
+	}
+	return rows
+}
+
+// RuleResult is outcome of rules evaluation.
+type RuleResult struct {
+	// Suppressed means default and subscribed channels are not notified.
+	Suppressed bool
+	Channels   []string
+	Mentions   []string
+	Matched    Rules
+}
+
+// Evaluate applies rules in priority order.
+// channels and mentions added by rules prior to suppress rule are kept.
+func (rs Rules) Evaluate(event *GithubEvent) *RuleResult {
+	sorted := make(Rules, len(rs))
+	copy(sorted, rs)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Priority > sorted[j].Priority })
+
+	result := &RuleResult{}
+	for _, r := range sorted {
+		if !r.Match(event) {
+			continue
+		}
+		result.Matched = append(result.Matched, r)
+		switch r.Action {
+		case RuleRoute:
+			if !stringsContain(result.Channels, r.Channel) {
+				result.Channels = append(result.Channels, r.Channel)
+			}
+		case RuleMention:
+			for _, m := range r.Mentions {
+				if !stringsContain(result.Mentions, m) {
+					result.Mentions = append(result.Mentions, m)
+				}
+			}
+		case RuleSuppress:
+			result.Suppressed = true
+			return result
+		}
+	}
+	return result
+}
+
+var slackChannelRegexp = regexp.MustCompile(`^<#([A-Z0-9]+)(\|[^>]*)?>$`)
+
+// ParseSlackChannel accepts channel link(<#C123|general>) and #name.
+func ParseSlackChannel(s string) (string, error) {
+	if m := slackChannelRegexp.FindStringSubmatch(s); m != nil {
+		return m[1], nil
+	}
+	if name := strings.TrimPrefix(s, "#"); name != "" && !strings.ContainsAny(name, "<>|") {
+		return name, nil
+	}
+	return "", errors.Errorf("invalid channel %q", s)
+}
+
+// RuleStore persists notification rules.
+type RuleStore interface {
+	AddRule(context.Context, *Rule) error
+	ListRules(context.Context) (Rules, error)
+	DeleteRule(ctx context.Context, id string) error
+}
@@ -0,0 +1,67 @@
+package app_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/ymgyt/gobot/app"
+)
+
+func mustRule(t *testing.T, priority int, action app.RuleAction, terms ...string) *app.Rule {
+	t.Helper()
+	conditions, err := app.ParseRuleConditions(terms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &app.Rule{ID: string(action), Priority: priority, Conditions: conditions, Action: action}
+}
+
+func TestRules_Evaluate(t *testing.T) {
+	security := mustRule(t, 10, app.RuleRoute, "event=pulls", "label=secu*")
+	security.Channel = "sec"
+	dependabot := mustRule(t, 0, app.RuleSuppress, "author=dependabot*")
+	release := mustRule(t, 5, app.RuleMention, "repo=ymgyt/*", "base=release/*")
+	release.Mentions = []string{"<@U123>"}
+	rules := app.Rules{dependabot, release, security}
+
+	tests := map[string]struct {
+		event *app.GithubEvent
+		want  *app.RuleResult
+	}{
+		"no match": {
+			event: &app.GithubEvent{Name: "pull_request", Category: app.EventCategoryPulls, Repository: "ymgyt/gobot", Base: "master"},
+			want:  &app.RuleResult{},
+		},
+		"route": {
+			event: &app.GithubEvent{Name: "pull_request", Category: app.EventCategoryPulls, Repository: "ymgyt/gobot", Labels: []string{"bug", "Security"}},
+			want:  &app.RuleResult{Channels: []string{"sec"}, Matched: app.Rules{security}},
+		},
+		"route is kept when suppressed by lower priority": {
+			event: &app.GithubEvent{Name: "pull_request", Category: app.EventCategoryPulls, Repository: "ymgyt/gobot", Author: "dependabot[bot]", Labels: []string{"security"}},
+			want:  &app.RuleResult{Suppressed: true, Channels: []string{"sec"}, Matched: app.Rules{security, dependabot}},
+		},
+		"mention": {
+			event: &app.GithubEvent{Name: "issues", Category: app.EventCategoryIssues, Repository: "YMGYT/gobot", Base: "release/1.0"},
+			want:  &app.RuleResult{Mentions: []string{"<@U123>"}, Matched: app.Rules{release}},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := rules.Evaluate(tc.event)
+			if diff := cmp.Diff(tc.want, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("(-want +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseRuleConditions(t *testing.T) {
+	for _, invalid := range []string{"security", "unknown=x", "repo=[a"} {
+		if _, err := app.ParseRuleConditions([]string{invalid}); err == nil {
+			t.Errorf("%s should be invalid", invalid)
+		}
+	}
+}
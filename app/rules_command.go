@@ -0,0 +1,323 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/nlopes/slack"
+	"github.com/ymgyt/cli"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func NewRulesCommand(b *CommandBuilder) *cli.Command {
+	cmd := &cli.Command{
+		Name:      "rules",
+		Aliases:   []string{"rule"},
+		ShortDesc: "operate notification rules",
+		LongDesc: "@gobot rules <COMMAND> <OPTIONS> <ARGS>\n\n" +
+			"conditions(glob): repo=owner/* author=dependabot* label=security base=release/* event=pulls action=opened env=prod*\n" +
+			"rules are evaluated in descending priority. suppress stops evaluation.",
+	}
+	return cmd.
+		AddCommand(NewRulesAddCommand(b.Rules)).
+		AddCommand(NewRulesLsCommand(b.Rules)).
+		AddCommand(NewRulesDeleteCommand(b.Rules)).
+		AddCommand(NewRulesTestCommand(b.Rules))
+}
+
+func NewRulesAddCommand(rules RuleStore) *cli.Command {
+	rulesAddCmd := &rulesAddCommand{}
+	cmd := &cli.Command{
+		Name:      "add",
+		ShortDesc: "add notification rule",
+		LongDesc: "add notification rule\n" +
+			"Usage: @gobot rules add <CONDITION>... <--route=CHANNEL|--suppress|--mention=MENTIONS> <OPTIONS>\n\n" +
+			"# securityラベルのPRを#secにも通知\n" +
+			"@gobot rules add event=pulls label=security --route=#sec --priority=10\n" +
+			"# dependabotのPRは通知しない\n" +
+			"@gobot rules add author=dependabot* --suppress\n" +
+			"# release/*向けのPRでreleaseチームにmention\n" +
+			"@gobot rules add base=release/* --mention=@alice,@bob",
+		Run: rulesAddCmd.runFunc(rules),
+	}
+	if err := cmd.Options().
+		Add(&cli.BoolOpt{Var: &rulesAddCmd.printHelp, Long: "help", Description: "print help"}).
+		Add(&cli.IntOpt{Var: &rulesAddCmd.Priority, Long: "priority", Description: "higher priority is evaluated first. default 0"}).
+		Add(&cli.StringOpt{Var: &rulesAddCmd.Route, Long: "route", Description: "route notification to channel"}).
+		Add(&cli.BoolOpt{Var: &rulesAddCmd.Suppress, Long: "suppress", Description: "suppress notification"}).
+		Add(&cli.StringOpt{Var: &rulesAddCmd.Mention, Long: "mention", Description: "comma separated slack users or github logins to mention"}).
+		Err; err != nil {
+		panic(err)
+	}
+	return cmd
+}
+
+type rulesAddCommand struct {
+	baseCommand
+	Priority int
+	Route    string
+	Suppress bool
+	Mention  string
+}
+
+func (c *rulesAddCommand) runFunc(rules RuleStore) commandFunc {
+	return func(ctx context.Context, cmd *cli.Command, args []string) {
+		if c.printHelp {
+			cli.HelpFunc(cmd.Stdout, cmd)
+			return
+		}
+		sm := getSlackMessage(ctx)
+
+		rule, err := c.rule(sm.client, args)
+		if err != nil {
+			sm.Fail(err)
+			return
+		}
+		rule.CreatedBy = sm.user.ID
+		if err := rules.AddRule(ctx, rule); err != nil {
+			sm.Fail(err)
+			return
+		}
+
+		text := fmt.Sprintf("rule %s added", rule.ID)
+		sm.PostAttachment(slack.Attachment{
+			Fallback: text,
+			Color:    slackColorGreen,
+			Pretext:  slackEmojiOKHand + " " + text,
+			Text:     LiteralizeLine(rule.String()),
+		})
+	}
+}
+
+func (c *rulesAddCommand) rule(channels SlackChannelLister, args []string) (*Rule, error) {
+	conditions, err := ParseRuleConditions(args)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	rule := &Rule{
+		ID:         primitive.NewObjectID().Hex(),
+		Priority:   c.Priority,
+		Conditions: conditions,
+	}
+
+	var actions int
+	if c.Route != "" {
+		actions++
+		rule.Action = RuleRoute
+		if rule.Channel, err = ResolveSlackChannelID(channels, c.Route); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	if c.Suppress {
+		actions++
+		rule.Action = RuleSuppress
+	}
+	if c.Mention != "" {
+		actions++
+		rule.Action = RuleMention
+		for _, m := range strings.Split(c.Mention, ",") {
+			if m = strings.TrimSpace(m); m != "" {
+				rule.Mentions = append(rule.Mentions, m)
+			}
+		}
+	}
+	if actions != 1 {
+		return nil, errors.New("specify exactly one of --route, --suppress and --mention")
+	}
+	return rule, nil
+}
+
+func NewRulesLsCommand(rules RuleStore) *cli.Command {
+	rulesLsCmd := &rulesLsCommand{}
+	cmd := &cli.Command{
+		Name:      "ls",
+		Aliases:   []string{"list"},
+		ShortDesc: "ls notification rules",
+		LongDesc: "ls notification rules in evaluation order\n" +
+			"Usage: @gobot rules ls <OPTIONS>",
+		Run: rulesLsCmd.runFunc(rules),
+	}
+	if err := cmd.Options().
+		Add(&cli.BoolOpt{Var: &rulesLsCmd.printHelp, Long: "help", Description: "print help"}).
+		Add(rulesLsCmd.option()).
+		Err; err != nil {
+		panic(err)
+	}
+	return cmd
+}
+
+type rulesLsCommand struct {
+	baseCommand
+	lsOutput
+}
+
+func (c *rulesLsCommand) runFunc(rules RuleStore) commandFunc {
+	return func(ctx context.Context, cmd *cli.Command, args []string) {
+		if c.printHelp {
+			cli.HelpFunc(cmd.Stdout, cmd)
+			return
+		}
+		sm := getSlackMessage(ctx)
+
+		rs, err := rules.ListRules(ctx)
+		if err != nil {
+			sm.Fail(err)
+			return
+		}
+		if c.enabled() {
+			c.post(sm, "rules", rs)
+			return
+		}
+
+		text := fmt.Sprintf("%d rule(s) found", len(rs))
+		sm.PostAttachment(slack.Attachment{
+			Fallback: text,
+			Color:    slackColorGreen,
+			Pretext:  text,
+			Fields:   ruleFields(rs),
+		})
+	}
+}
+
+func ruleFields(rs Rules) []slack.AttachmentField {
+	fields := make([]slack.AttachmentField, 0, len(rs))
+	for _, r := range rs {
+		fields = append(fields, slack.AttachmentField{
+			Title: fmt.Sprintf("%s (priority %d)", r.ID, r.Priority),
+			Value: LiteralizeLine(r.String()),
+		})
+	}
+	return fields
+}
+
+func NewRulesDeleteCommand(rules RuleStore) *cli.Command {
+	rulesDeleteCmd := &rulesDeleteCommand{}
+	cmd := &cli.Command{
+		Name:      "delete",
+		Aliases:   []string{"rm"},
+		ShortDesc: "delete notification rules",
+		LongDesc: "delete notification rules\n" +
+			"Usage: @gobot rules delete <ID>...",
+		Run: rulesDeleteCmd.runFunc(rules),
+	}
+	if err := cmd.Options().
+		Add(&cli.BoolOpt{Var: &rulesDeleteCmd.printHelp, Long: "help", Description: "print help"}).
+		Err; err != nil {
+		panic(err)
+	}
+	return cmd
+}
+
+type rulesDeleteCommand struct {
+	baseCommand
+}
+
+func (c *rulesDeleteCommand) runFunc(rules RuleStore) commandFunc {
+	return func(ctx context.Context, cmd *cli.Command, args []string) {
+		if c.printHelp || len(args) == 0 {
+			cli.HelpFunc(cmd.Stdout, cmd)
+			return
+		}
+		sm := getSlackMessage(ctx)
+
+		for _, id := range args {
+			if err := rules.DeleteRule(ctx, id); err != nil {
+				sm.Fail(err)
+				return
+			}
+		}
+		text := fmt.Sprintf("%d rule(s) deleted", len(args))
+		sm.PostAttachment(slack.Attachment{
+			Fallback: text,
+			Color:    slackColorGreen,
+			Pretext:  slackEmojiOKHand + " " + text,
+		})
+	}
+}
+
+func NewRulesTestCommand(rules RuleStore) *cli.Command {
+	rulesTestCmd := &rulesTestCommand{}
+	cmd := &cli.Command{
+		Name:      "test",
+		ShortDesc: "dry-run notification rules",
+		LongDesc: "dry-run notification rules against event fixture\n" +
+			"Usage: @gobot rules test <EVENT_JSON>\n\n" +
+			"# fixtureはjsonで指定. fileを添付してもよい\n" +
+			`@gobot rules test {"name":"pull_request","action":"opened","repository":"ymgyt/gobot","author":"dependabot[bot]","labels":["security"],"base":"master"}`,
+		Run: rulesTestCmd.runFunc(rules),
+	}
+	if err := cmd.Options().
+		Add(&cli.BoolOpt{Var: &rulesTestCmd.printHelp, Long: "help", Description: "print help"}).
+		Err; err != nil {
+		panic(err)
+	}
+	return cmd
+}
+
+type rulesTestCommand struct {
+	baseCommand
+}
+
+func (c *rulesTestCommand) runFunc(rules RuleStore) commandFunc {
+	return func(ctx context.Context, cmd *cli.Command, args []string) {
+		sm := getSlackMessage(ctx)
+		if c.printHelp || (len(args) == 0 && len(sm.Files()) == 0) {
+			cli.HelpFunc(cmd.Stdout, cmd)
+			return
+		}
+
+		event, err := readEventFixture(ctx, sm, args)
+		if err != nil {
+			sm.Fail(err)
+			return
+		}
+		rs, err := rules.ListRules(ctx)
+		if err != nil {
+			sm.Fail(err)
+			return
+		}
+		result := rs.Evaluate(event)
+
+		text := fmt.Sprintf("%d rule(s) matched", len(result.Matched))
+		fields := ruleFields(result.Matched)
+		fields = append(fields,
+			slack.AttachmentField{Title: "suppressed", Value: fmt.Sprintf("%v", result.Suppressed), Short: true},
+			slack.AttachmentField{Title: "routed channels", Value: strings.Join(result.Channels, ","), Short: true},
+			slack.AttachmentField{Title: "mentions", Value: strings.Join(result.Mentions, " "), Short: true},
+		)
+		sm.PostAttachment(slack.Attachment{
+			Fallback: text,
+			Color:    slackColorGreen,
+			Pretext:  text,
+			Fields:   fields,
+		})
+	}
+}
+
+var smartQuoteReplacer = strings.NewReplacer("“", `"`, "”", `"`)
+
+// readEventFixture reads GithubEvent json from args or attached file.
+func readEventFixture(ctx context.Context, sm *SlackMessage, args []string) (*GithubEvent, error) {
+	// slack client may convert quotes to smart quotes.
+	data := []byte(smartQuoteReplacer.Replace(strings.Join(args, " ")))
+	if len(args) == 0 {
+		files := sm.Files()
+		content, err := sm.DownloadFile(ctx, &files[0])
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		data = content
+	}
+
+	var event GithubEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, errors.Annotate(err, "invalid event fixture")
+	}
+	if event.Category == "" {
+		event.Category = CategoryOf(event.Name)
+	}
+	return &event, nil
+}
@@ -0,0 +1,121 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nlopes/slack"
+)
+
+// security alert states.
+const (
+	SecurityAlertOpen      = "open"
+	SecurityAlertDismissed = "dismissed"
+	SecurityAlertFixed     = "fixed"
+	SecurityAlertWithdrawn = "withdrawn"
+)
+
+// SecurityAlertMsg is a vulnerable dependency alert or security advisory.
+type SecurityAlertMsg struct {
+	// Key identifies alert. messages of an alert are posted to one thread.
+	Key             string
+	Action          string
+	State           string
+	Severity        string // low, moderate(medium), high, critical
+	Ecosystem       string
+	Package         string
+	VulnerableRange string
+	PatchedVersion  string
+	Identifier      string // GHSA or CVE id
+	Summary         string
+	Manifest        string
+	URL             string
+	DismissedBy     string
+	DismissReason   string
+	RepoName        string
+	Event           *GithubEvent
+}
+
+// Opened reports whether alert is created or reopened. owners are mentioned only then.
+func (m *SecurityAlertMsg) Opened() bool {
+	return m.State == SecurityAlertOpen
+}
+
+func (m *SecurityAlertMsg) notification(n *Notifier, setting *RepositorySetting) *notification {
+	mentions := []string{}
+	if m.Opened() && setting != nil {
+		for _, owner := range setting.Owners {
+			if mention, ok := n.slackMention(owner); ok && !stringsContain(mentions, mention) {
+				mentions = append(mentions, mention)
+			}
+		}
+	}
+	var fields []slack.AttachmentField
+	if m.RepoName != "" {
+		fields = repositoryFields(m.RepoName)
+	}
+	if m.Severity != "" {
+		fields = append(fields, slack.AttachmentField{Title: "Severity", Value: slackTextEscaper.Replace(m.Severity), Short: true})
+	}
+	if m.PatchedVersion != "" {
+		fields = append(fields, slack.AttachmentField{Title: "Patched version", Value: slackTextEscaper.Replace(m.PatchedVersion), Short: true})
+	}
+	return &notification{
+		Kind:  TemplateSecurityAlert,
+		Event: m.Event,
+		Data: map[string]interface{}{
+			"Mentions":        strings.Join(mentions, " "),
+			"Action":          m.Action,
+			"State":           m.State,
+			"Severity":        strings.ToLower(m.Severity),
+			"Ecosystem":       m.Ecosystem,
+			"Package":         m.Package,
+			"VulnerableRange": m.VulnerableRange,
+			"PatchedVersion":  m.PatchedVersion,
+			"Identifier":      m.Identifier,
+			"Summary":         m.Summary,
+			"Manifest":        m.Manifest,
+			"URL":             m.URL,
+			"DismissedBy":     m.DismissedBy,
+			"DismissReason":   m.DismissReason,
+			"RepoName":        m.RepoName,
+			"Repository":      m.Event.repository(),
+		},
+		Attachment: slack.Attachment{
+			Fallback:  fmt.Sprintf("security alert %s %s", m.Package, m.State),
+			TitleLink: m.URL,
+			Footer:    "Github webhook " + footerSuffix(),
+			Ts:        slackTimestamp(),
+			Fields:    fields,
+		},
+		Channel: securityChannel(n, setting),
+	}
+}
+
+func securityChannel(n *Notifier, setting *RepositorySetting) string {
+	if setting != nil && setting.SecurityChannel != "" {
+		return setting.SecurityChannel
+	}
+	return n.SecurityChannel
+}
+
+// NotifySecurityAlert posts alert to security channel.
+// the first message of an alert is updated with its state, and state changes are posted to its thread.
+func (n *Notifier) NotifySecurityAlert(msg *SecurityAlertMsg) error {
+	setting := n.repositorySetting(msg.Event.repository())
+
+	root := msg.notification(n, setting)
+	root.ThreadKey = msg.Key
+	root.UpdateKey = msg.Key
+	if err := n.notify(root); err != nil {
+		return err
+	}
+	if msg.Opened() && msg.Action != "reintroduced" && msg.Action != "reopened" {
+		return nil
+	}
+
+	reply := msg.notification(n, setting)
+	reply.ThreadKey = msg.Key
+	reply.Reply = true
+	return n.notify(reply)
+}
@@ -19,6 +19,8 @@ const (
 	slackColorGray   = "#586069"
 	slackColorYellow = "#dbab09"
 	slackColorRed    = "#cb2431"
+	slackColorOrange = "#e36209"
+	slackColorMaroon = "#86181d"
 
 	slackEmojiOKHand       = ":ok_hand:"
 	slackEmojiWritingHand  = ":writing_hand:"
@@ -28,7 +30,8 @@ const (
 
 // SlackOptions -
 type SlackOptions struct {
-	GithubPRNotificationChannel string
+	BotToken string
+	Renderer SlackRenderer
 }
 
 // SlackMessageHandler -
@@ -36,18 +39,23 @@ type SlackMessageHandler interface {
 	Handle(*SlackMessage)
 }
 
+// Deduplicator reports whether an event is seen first time within d.
+// DuplicationChecker is in-process implementation. store.Deduplications persists events to share them across replicas.
+type Deduplicator interface {
+	CheckDuplicateNotification(eventKey string, d time.Duration) bool
+	// Forget removes eventKey, so that the event is processed again.
+	Forget(eventKey string)
+}
+
 // Slack -
 type Slack struct {
 	*SlackOptions
-	Client             *slack.Client
-	AccountResolver    *AccountResolver
-	DuplicationChecker *DuplicationChecker
-	MessageHandler     SlackMessageHandler
-
-	user          string
-	userID        string
-	rtm           *slack.RTM
-	githubChannel *slack.Channel
+	Client         *slack.Client
+	MessageHandler SlackMessageHandler
+
+	user   string
+	userID string
+	rtm    *slack.RTM
 }
 
 // Run -
@@ -60,13 +68,7 @@ func (s *Slack) Run(ctx context.Context) error {
 }
 
 func (s *Slack) init() error {
-	if err := s.authorize(); err != nil {
-		return err
-	}
-	if err := s.populateChannel(); err != nil {
-		return err
-	}
-	return nil
+	return s.authorize()
 }
 
 func (s *Slack) authorize() error {
@@ -81,30 +83,32 @@ func (s *Slack) authorize() error {
 	return nil
 }
 
-func (s *Slack) populateChannel() error {
-	channels, err := s.getChannels()
+// SlackChannelLister lists slack channels.
+type SlackChannelLister interface {
+	GetChannels(excludeArchived bool, options ...slack.GetChannelsOption) ([]slack.Channel, error)
+}
+
+// ResolveSlackChannelID returns id of the channel. channel is name(with or without #), <#ID|name> or id.
+// subscriptions and templates store channel ids, so default channels have to be resolved to compare with them.
+func ResolveSlackChannelID(lister SlackChannelLister, channel string) (string, error) {
+	channel, err := ParseSlackChannel(channel)
 	if err != nil {
-		return err
+		return "", err
+	}
+	excludeArchive := true
+	channels, err := lister.GetChannels(excludeArchive, slack.GetChannelsOptionExcludeMembers())
+	if err != nil {
+		return "", errors.Trace(err)
 	}
 	for i := range channels {
-		if channels[i].Name == s.GithubPRNotificationChannel {
-			s.githubChannel = &channels[i]
+		if channels[i].ID == channel || channels[i].Name == channel {
+			log.Debug("slack channel found",
+				zap.String("channel_id", channels[i].ID),
+				zap.String("channel_name", channels[i].NameNormalized))
+			return channels[i].ID, nil
 		}
 	}
-
-	if s.githubChannel == nil {
-		return errors.Errorf("github pull request notification channel(%s) not found", s.GithubPRNotificationChannel)
-	}
-	log.Debug("github pr notification channel found",
-		zap.String("channel_id", s.githubChannel.ID),
-		zap.String("channel_name", s.githubChannel.NameNormalized))
-	return nil
-}
-
-func (s *Slack) getChannels() ([]slack.Channel, error) {
-	excludeArchive := true
-	channels, err := s.Client.GetChannels(excludeArchive, slack.GetChannelsOptionExcludeMembers())
-	return channels, errors.Trace(err)
+	return "", errors.NotFoundf("slack channel(%s)", channel)
 }
 
 func (s *Slack) run(ctx context.Context) error {
@@ -184,143 +188,18 @@ func (s *Slack) handleMessage(msg *slack.MessageEvent) {
 		return
 	}
 
-	go s.MessageHandler.Handle(&SlackMessage{event: msg, user: user, client: s.Client, isDirect: isDirect})
-}
-
-// github actions
-
-// PRReviewRequestedMsg githubのPullRequestでReviewerを指定した際にslackに通知するための情報.
-type PRReviewRequestedMsg struct {
-	Owner              string // prを作成したuser name(login)
-	OwnerAvatarURL     string
-	URL                string   // prへのlink
-	Title              string   // prのtitle
-	Body               string   // prのcomment
-	RepoName           string   // prが紐づくrepositoryの名前
-	RequestedReviewers []string // reviewerとして指定されたuser name(login)
-}
-
-func (m *PRReviewRequestedMsg) attachment(s *Slack) slack.Attachment {
-	pretext := func(reviewers []string) string {
-		var mention string
-		for _, reviewer := range reviewers {
-			mention += s.MentionByGithubUsername(reviewer) + " "
-		}
-		msg := fmt.Sprintf(":point_right: %s your review is requested", mention)
-		return msg
-	}
-	return slack.Attachment{
-		Fallback:   "pull request review requested message",
-		Color:      slackColorGreen,
-		Pretext:    pretext(m.RequestedReviewers),
-		AuthorName: m.Owner,
-		AuthorIcon: m.OwnerAvatarURL,
-		Title:      m.Title,
-		TitleLink:  m.URL,
-		Text:       m.Body,
-		Footer:     "Github webhook " + footerSuffix(),
-		Ts:         json.Number(fmt.Sprintf("%d", time.Now().Unix())),
-		Fields: []slack.AttachmentField{
-			{
-				Title: "Repository",
-				Value: m.RepoName,
-				Short: true,
-			},
-		},
-	}
-}
-
-// NotifyPRReviewRequested -
-func (s *Slack) NotifyPRReviewRequested(msg *PRReviewRequestedMsg) error {
-	// https://github.com/ymgyt/gobot/issues/7
-	// when multiple reviewer are requested, multiple event emitted.
-	var err error
-	if ok := s.DuplicationChecker.CheckDuplicateNotification(msg.URL, (4 * time.Second)); ok {
-		_, _, err = s.Client.PostMessage(s.githubChannel.ID, slack.MsgOptionAttachments(msg.attachment(s)))
-	}
-	return err
-}
-
-// PRReviewSubmittedMsg -
-type PRReviewSubmittedMsg struct {
-	Owner             string
-	Title             string
-	RepoName          string
-	Reviewer          string
-	ReviewerAvatarURL string
-	ReviewBody        string
-	ReviewState       string
-	ReviewURL         string
-}
-
-func (m *PRReviewSubmittedMsg) attachments(s *Slack) slack.Attachment {
-	var emoji string
-	var color string
-	switch strings.ToLower(m.ReviewState) {
-	case "commented":
-		emoji = slackEmojiWritingHand
-		color = slackColorGray
-	case "changes_requested":
-		emoji = slackEmojiPointUp
-		color = slackColorYellow
-	case "approved":
-		emoji = slackEmojiOKHand
-		color = slackColorGreen
-	default: // 基本はいらないはず
-		emoji = slackEmojiMiddleFinger
-		color = slackColorRed
-	}
-	pretext := func() string {
-		mention := s.MentionByGithubUsername(m.Owner)
-		return fmt.Sprintf("%s %s your PR *%s*", emoji, mention, m.ReviewState)
-	}
-	return slack.Attachment{
-		Fallback:   "pull request review submitted",
-		Color:      color,
-		Pretext:    pretext(),
-		AuthorName: m.Reviewer,
-		AuthorIcon: m.ReviewerAvatarURL,
-		Title:      fmt.Sprintf("PR (%s) review", m.Title),
-		TitleLink:  m.ReviewURL,
-		Text:       m.ReviewBody,
-		Footer:     "Github webhook " + footerSuffix(),
-		Ts:         json.Number(fmt.Sprintf("%d", time.Now().Unix())),
-		Fields: []slack.AttachmentField{
-			{
-				Title: "Repository",
-				Value: m.RepoName,
-				Short: true,
-			},
-		},
-	}
-}
-
-// NotifyPRReviewSubmitted -
-func (s *Slack) NotifyPRReviewSubmitted(msg *PRReviewSubmittedMsg) error {
-	// https://github.com/ymgyt/gobot/issues/6
-	// ignore self comment
-	if msg.Owner == msg.Reviewer {
-		log.Info("notify_prreview_submitted", zap.String("msg", "ignore event for self comment"), zap.String("pr_owner", msg.Owner), zap.String("reviewer", msg.Reviewer))
-		return nil
-	}
-	_, _, err := s.Client.PostMessage(s.githubChannel.ID, slack.MsgOptionAttachments(msg.attachments(s)))
-	return err
-}
-
-// MentionByGithubUsername githubのusernameをslackでmentionできるようにする.
-func (s *Slack) MentionByGithubUsername(name string) string {
-	user, err := s.AccountResolver.SlackUserFromGithubUsername(name)
-	// 見つからなければそれがわかるように元の名前で返す
-	if IsUserNotFound(err) {
-		return fmt.Sprintf("<@%s> (could not resolve slack user by github user name)", name)
-	}
-	if err != nil {
-		return fmt.Sprintf("<@%s> (%s)", name, err)
-	}
-
-	return Mentiorize(user.ID)
+	go s.MessageHandler.Handle(&SlackMessage{
+		event:    msg,
+		user:     user,
+		client:   s.Client,
+		token:    s.BotToken,
+		isDirect: isDirect,
+		renderer: s.Renderer,
+		poster:   &BlockPoster{Token: s.BotToken},
+	})
 }
 
+// DuplicationChecker is in-memory Deduplicator. records are lost on restart.
 type DuplicationChecker struct {
 	sync.Mutex
 	m map[string]time.Time
@@ -348,6 +227,14 @@ func (dc *DuplicationChecker) CheckDuplicateNotification(eventURL string, d time
 	return !duplicate
 }
 
+// Forget -
+func (dc *DuplicationChecker) Forget(eventURL string) {
+	dc.lasyInit()
+	dc.Lock()
+	defer dc.Unlock()
+	delete(dc.m, eventURL)
+}
+
 func (dc *DuplicationChecker) lookup(eventURL string) (time.Time, bool) {
 	t, found := dc.m[eventURL]
 	return t, found
@@ -4,6 +4,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/juju/errors"
+	"github.com/nlopes/slack"
+
 	"github.com/ymgyt/gobot/app"
 )
 
@@ -22,3 +25,28 @@ func TestDuplicationChecker_CheckDuplicateNotification(t *testing.T) {
 		t.Fatal("duplicate notification should not be ok, bug got ok")
 	}
 }
+
+type fakeChannelLister []slack.Channel
+
+func (l fakeChannelLister) GetChannels(excludeArchived bool, options ...slack.GetChannelsOption) ([]slack.Channel, error) {
+	return l, nil
+}
+
+func TestResolveSlackChannelID(t *testing.T) {
+	general := slack.Channel{}
+	general.ID, general.Name = "C123", "general"
+	lister := fakeChannelLister{general}
+
+	for _, channel := range []string{"general", "#general", "<#C123|general>", "C123"} {
+		got, err := app.ResolveSlackChannelID(lister, channel)
+		if err != nil {
+			t.Fatalf("%s: %v", channel, err)
+		}
+		if got != "C123" {
+			t.Errorf("%s: want C123, got %s", channel, got)
+		}
+	}
+	if _, err := app.ResolveSlackChannelID(lister, "random"); !errors.IsNotFound(err) {
+		t.Errorf("want not found, got %v", err)
+	}
+}
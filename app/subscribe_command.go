@@ -0,0 +1,218 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/nlopes/slack"
+	"github.com/ymgyt/cli"
+)
+
+func NewSubscribeCommand(b *CommandBuilder) *cli.Command {
+	subscribeCmd := &subscribeCommand{}
+	cmd := &cli.Command{
+		Name:      "subscribe",
+		Aliases:   []string{"sub"},
+		ShortDesc: "subscribe github events of repository in this channel",
+		LongDesc: "subscribe github events of repository in this channel\n" +
+			"Usage: @gobot subscribe <OWNER/REPO> [CATEGORIES] <OPTIONS>\n\n" +
+			"# categoryはカンマ区切りで指定. 省略した場合はすべて(pulls,reviews,issues,comments,ci,releases,deployments,pushes,security)\n" +
+			"@gobot subscribe ymgyt/gobot pulls,reviews\n\n" +
+			"# deploymentsを特定のenvironmentに限定する\n" +
+			"@gobot subscribe ymgyt/gobot deployments --env production",
+		Run: subscribeCmd.runFunc(b.Subscriptions),
+	}
+	if err := cmd.Options().
+		Add(&cli.BoolOpt{Var: &subscribeCmd.printHelp, Long: "help", Description: "print help"}).
+		Add(&cli.StringOpt{Var: &subscribeCmd.Environments, Long: "env", Description: "comma separated deployment environments"}).
+		Err; err != nil {
+		panic(err)
+	}
+	return cmd
+}
+
+type subscribeCommand struct {
+	baseCommand
+	Environments string
+}
+
+func (c *subscribeCommand) runFunc(subs SubscriptionStore) commandFunc {
+	return func(ctx context.Context, cmd *cli.Command, args []string) {
+		if c.printHelp || len(args) == 0 || len(args) > 2 {
+			cli.HelpFunc(cmd.Stdout, cmd)
+			return
+		}
+		sm := getSlackMessage(ctx)
+
+		repository, categories, err := parseSubscriptionArgs(args)
+		if err != nil {
+			sm.Fail(err)
+			return
+		}
+		envs := ParseEnvironments(c.Environments)
+		err = subs.Subscribe(ctx, &Subscription{
+			Channel:      sm.event.Channel,
+			Repository:   repository,
+			Categories:   categories,
+			Environments: envs,
+			CreatedBy:    sm.user.ID,
+		})
+		if err != nil {
+			sm.Fail(err)
+			return
+		}
+
+		text := fmt.Sprintf("this channel subscribes %s of %s", joinCategories(categories), repository)
+		if len(envs) > 0 {
+			text += fmt.Sprintf(" (environments: %s)", strings.Join(envs, ","))
+		}
+		sm.PostAttachment(slack.Attachment{
+			Fallback: text,
+			Color:    slackColorGreen,
+			Pretext:  slackEmojiOKHand + " " + text,
+		})
+	}
+}
+
+func NewUnsubscribeCommand(b *CommandBuilder) *cli.Command {
+	unsubscribeCmd := &unsubscribeCommand{}
+	cmd := &cli.Command{
+		Name:      "unsubscribe",
+		Aliases:   []string{"unsub"},
+		ShortDesc: "unsubscribe github events of repository in this channel",
+		LongDesc: "unsubscribe github events of repository in this channel\n" +
+			"Usage: @gobot unsubscribe <OWNER/REPO> [CATEGORIES]\n\n" +
+			"# categoryを省略した場合はsubscriptionを削除する\n" +
+			"@gobot unsubscribe ymgyt/gobot issues",
+		Run: unsubscribeCmd.runFunc(b.Subscriptions),
+	}
+	if err := cmd.Options().
+		Add(&cli.BoolOpt{Var: &unsubscribeCmd.printHelp, Long: "help", Description: "print help"}).
+		Err; err != nil {
+		panic(err)
+	}
+	return cmd
+}
+
+type unsubscribeCommand struct {
+	baseCommand
+}
+
+func (c *unsubscribeCommand) runFunc(subs SubscriptionStore) commandFunc {
+	return func(ctx context.Context, cmd *cli.Command, args []string) {
+		if c.printHelp || len(args) == 0 || len(args) > 2 {
+			cli.HelpFunc(cmd.Stdout, cmd)
+			return
+		}
+		sm := getSlackMessage(ctx)
+
+		repository, categories, err := parseSubscriptionArgs(args)
+		if err != nil {
+			sm.Fail(err)
+			return
+		}
+		if err := subs.Unsubscribe(ctx, sm.event.Channel, repository, categories); err != nil {
+			sm.Fail(err)
+			return
+		}
+
+		text := fmt.Sprintf("this channel unsubscribed %s of %s", joinCategories(categories), repository)
+		sm.PostAttachment(slack.Attachment{
+			Fallback: text,
+			Color:    slackColorGreen,
+			Pretext:  slackEmojiOKHand + " " + text,
+		})
+	}
+}
+
+func parseSubscriptionArgs(args []string) (string, []EventCategory, error) {
+	repository, err := NormalizeRepository(args[0])
+	if err != nil {
+		return "", nil, errors.Trace(err)
+	}
+	var categories string
+	if len(args) > 1 {
+		categories = args[1]
+	}
+	parsed, err := ParseEventCategories(categories)
+	return repository, parsed, errors.Trace(err)
+}
+
+func NewLsSubscriptionsCommand(subs SubscriptionStore) *cli.Command {
+	lsSubscriptionsCmd := &lsSubscriptionsCommand{}
+	cmd := &cli.Command{
+		Name:      "subscription",
+		Aliases:   []string{"subscriptions", "subs"},
+		ShortDesc: "ls subscriptions",
+		LongDesc: "ls subscriptions of this channel\n" +
+			"Usage: @gobot ls subscriptions <OPTIONS>\n\n" +
+			"# すべてのchannelのsubscriptionを表示\n" +
+			"@gobot ls subscriptions --all",
+		Run: lsSubscriptionsCmd.runFunc(subs),
+	}
+	if err := cmd.Options().
+		Add(&cli.BoolOpt{Var: &lsSubscriptionsCmd.printHelp, Long: "help", Description: "print help"}).
+		Add(&cli.BoolOpt{Var: &lsSubscriptionsCmd.All, Long: "all", Description: "ls subscriptions of all channels"}).
+		Add(&cli.StringOpt{Var: &lsSubscriptionsCmd.Repository, Long: "repo", Description: "filter by repository(owner/repo)"}).
+		Add(lsSubscriptionsCmd.option()).
+		Err; err != nil {
+		panic(err)
+	}
+	return cmd
+}
+
+type lsSubscriptionsCommand struct {
+	baseCommand
+	lsOutput
+	All        bool
+	Repository string
+}
+
+func (c *lsSubscriptionsCommand) runFunc(subs SubscriptionStore) commandFunc {
+	return func(ctx context.Context, cmd *cli.Command, args []string) {
+		if c.printHelp {
+			cli.HelpFunc(cmd.Stdout, cmd)
+			return
+		}
+		sm := getSlackMessage(ctx)
+
+		input := &ListSubscriptionsInput{}
+		if !c.All {
+			input.Channel = sm.event.Channel
+		}
+		if c.Repository != "" {
+			repository, err := NormalizeRepository(c.Repository)
+			if err != nil {
+				sm.Fail(err)
+				return
+			}
+			input.Repository = repository
+		}
+		ss, err := subs.ListSubscriptions(ctx, input)
+		if err != nil {
+			sm.Fail(err)
+			return
+		}
+		if c.enabled() {
+			c.post(sm, "subscriptions", ss)
+			return
+		}
+
+		text := fmt.Sprintf("%d subscription(s) found", len(ss))
+		fields := make([]slack.AttachmentField, 0, len(ss))
+		for _, s := range ss {
+			fields = append(fields, slack.AttachmentField{
+				Title: s.Repository,
+				Value: fmt.Sprintf("<#%s> %s %s", s.Channel, joinCategories(s.Categories), strings.Join(s.Environments, ",")),
+			})
+		}
+		sm.PostAttachment(slack.Attachment{
+			Fallback: text,
+			Color:    slackColorGreen,
+			Pretext:  text,
+			Fields:   fields,
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package app
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+// EventCategory groups github events which channels can subscribe.
+type EventCategory string
+
+const (
+	EventCategoryPulls       EventCategory = "pulls"
+	EventCategoryReviews     EventCategory = "reviews"
+	EventCategoryIssues      EventCategory = "issues"
+	EventCategoryComments    EventCategory = "comments"
+	EventCategoryCI          EventCategory = "ci"
+	EventCategoryReleases    EventCategory = "releases"
+	EventCategoryDeployments EventCategory = "deployments"
+	EventCategoryPushes      EventCategory = "pushes"
+	EventCategorySecurity    EventCategory = "security"
+)
+
+// EventCategories is all categories available for subscription.
+var EventCategories = []EventCategory{
+	EventCategoryPulls,
+	EventCategoryReviews,
+	EventCategoryIssues,
+	EventCategoryComments,
+	EventCategoryCI,
+	EventCategoryReleases,
+	EventCategoryDeployments,
+	EventCategoryPushes,
+	EventCategorySecurity,
+}
+
+// ParseEventCategories parses comma separated categories. empty string means all categories.
+func ParseEventCategories(s string) ([]EventCategory, error) {
+	if s == "" {
+		return EventCategories, nil
+	}
+	var categories []EventCategory
+	seen := make(map[EventCategory]bool)
+	for _, v := range strings.Split(s, ",") {
+		c := EventCategory(strings.ToLower(strings.TrimSpace(v)))
+		if !c.valid() {
+			return nil, errors.Errorf("unknown event category %q. available categories are %s", v, joinCategories(EventCategories))
+		}
+		if !seen[c] {
+			seen[c] = true
+			categories = append(categories, c)
+		}
+	}
+	return categories, nil
+}
+
+func (c EventCategory) valid() bool {
+	for _, category := range EventCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+func joinCategories(categories []EventCategory) string {
+	ss := make([]string, len(categories))
+	for i := range categories {
+		ss[i] = string(categories[i])
+	}
+	return strings.Join(ss, ",")
+}
+
+var repositoryFullNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+$`)
+
+// NormalizeRepository validates owner/repo and returns it in lower case.
+// github repository names are case insensitive.
+func NormalizeRepository(fullName string) (string, error) {
+	if !repositoryFullNameRegexp.MatchString(fullName) {
+		return "", errors.Errorf("invalid repository %q. repository must be owner/repo", fullName)
+	}
+	return strings.ToLower(fullName), nil
+}
+
+// Subscription represents slack channel subscribing github events of repository.
+type Subscription struct {
+	Channel      string          `json:"channel" bson:"channel"`
+	Repository   string          `json:"repository" bson:"repository"`
+	Categories   []EventCategory `json:"categories" bson:"categories"`
+	Environments []string        `json:"environments,omitempty" bson:"environments,omitempty"` // deployments are restricted to them. empty means all.
+	CreatedBy    string          `json:"created_by" bson:"created_by"`
+	CreatedAt    time.Time       `json:"created_at" bson:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at" bson:"updated_at"`
+}
+
+// Subscriptions -
+type Subscriptions []*Subscription
+
+// Header implements Tabular.
+func (ss Subscriptions) Header() []string {
+	return []string{"channel", "repository", "categories", "environments", "created_by", "created_at"}
+}
+
+// Rows implements Tabular.
+func (ss Subscriptions) Rows() [][]string {
+	rows := make([][]string, 0, len(ss))
+	for _, s := range ss {
+		rows = append(rows, []string{
+			s.Channel,
+			s.Repository,
+			joinCategories(s.Categories),
+			strings.Join(s.Environments, ","),
+			s.CreatedBy,
+			s.CreatedAt.In(TimeZone).Format(time.RFC3339),
+		})
+	}
+	return rows
+}
+
+// ListSubscriptionsInput -
+type ListSubscriptionsInput struct {
+	Channel    string
+	Repository string
+}
+
+// SubscriptionStore persists subscriptions. a channel has at most one subscription per repository.
+type SubscriptionStore interface {
+	// Subscribe adds categories and environments to subscription of channel and repository.
+	Subscribe(context.Context, *Subscription) error
+	// Unsubscribe removes categories from subscription. subscription without categories is deleted.
+	Unsubscribe(ctx context.Context, channel, repository string, categories []EventCategory) error
+	ListSubscriptions(context.Context, *ListSubscriptionsInput) (Subscriptions, error)
+	// SubscribedChannels returns channels subscribing category of repository.
+	// when environment is not empty, subscriptions restricted to other environments are excluded.
+	SubscribedChannels(ctx context.Context, repository string, category EventCategory, environment string) ([]string, error)
+}
+
+// ParseEnvironments parses comma separated deployment environments.
+func ParseEnvironments(s string) []string {
+	var envs []string
+	for _, v := range strings.Split(s, ",") {
+		if env := strings.TrimSpace(v); env != "" && !stringsContain(envs, env) {
+			envs = append(envs, env)
+		}
+	}
+	return envs
+}
+
+// uniqueChannels removes empty and duplicated channels keeping order.
+func uniqueChannels(channels ...string) []string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, ch := range channels {
+		if ch == "" || seen[ch] {
+			continue
+		}
+		seen[ch] = true
+		unique = append(unique, ch)
+	}
+	return unique
+}
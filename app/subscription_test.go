@@ -0,0 +1,67 @@
+package app_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/ymgyt/gobot/app"
+)
+
+func TestParseEventCategories(t *testing.T) {
+	tests := map[string]struct {
+		input string
+		want  []app.EventCategory
+		err   bool
+	}{
+		"all":       {input: "", want: app.EventCategories},
+		"single":    {input: "pulls", want: []app.EventCategory{app.EventCategoryPulls}},
+		"multiple":  {input: "Reviews, issues,reviews", want: []app.EventCategory{app.EventCategoryReviews, app.EventCategoryIssues}},
+		"undefined": {input: "pulls,push", err: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := app.ParseEventCategories(tc.input)
+			if tc.err {
+				if err == nil {
+					t.Fatal("want error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("(-want +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNormalizeRepository(t *testing.T) {
+	got, err := app.NormalizeRepository("YMGYT/GoBot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "ymgyt/gobot" {
+		t.Errorf("want ymgyt/gobot, got %s", got)
+	}
+
+	for _, invalid := range []string{"gobot", "ymgyt/gobot/pulls", "<https://github.com/ymgyt/gobot>"} {
+		if _, err := app.NormalizeRepository(invalid); err == nil {
+			t.Errorf("%s should be invalid", invalid)
+		}
+	}
+}
+
+func TestParseEnvironments(t *testing.T) {
+	got := app.ParseEnvironments(" production,staging,,production ")
+	want := []string{"production", "staging"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want +got)\n%s", diff)
+	}
+	if got := app.ParseEnvironments(""); len(got) != 0 {
+		t.Errorf("want empty, got %v", got)
+	}
+}
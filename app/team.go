@@ -0,0 +1,113 @@
+package app
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+var (
+	slackUserGroupRegexp = regexp.MustCompile(`^<!subteam\^([A-Z0-9]+)(\|[^>]*)?>$`)
+	slackUserRegexp      = regexp.MustCompile(`^<@([A-Z0-9]+)(\|[^>]*)?>$`)
+	slackIDRegexp        = regexp.MustCompile(`^[A-Z0-9]+$`)
+)
+
+// Team maps github team to slack user group or slack members.
+type Team struct {
+	// Slug is lower cased github team slug. organization is not distinguished.
+	Slug string `json:"slug" bson:"_id"`
+	// UserGroupID is slack user group id. it takes precedence over Members.
+	UserGroupID string `json:"usergroup_id,omitempty" bson:"usergroup_id,omitempty"`
+	// Members are slack user ids.
+	Members   []string  `json:"members,omitempty" bson:"members,omitempty"`
+	UpdatedBy string    `json:"updated_by" bson:"updated_by"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// Mention returns slack mention of team.
+func (t *Team) Mention() string {
+	if t.UserGroupID != "" {
+		return "<!subteam^" + t.UserGroupID + ">"
+	}
+	mentions := make([]string, len(t.Members))
+	for i, member := range t.Members {
+		mentions[i] = Mentiorize(member)
+	}
+	return strings.Join(mentions, " ")
+}
+
+// NormalizeTeamSlug accepts slug, @org/slug and org/slug.
+func NormalizeTeamSlug(s string) (string, error) {
+	slug := strings.TrimPrefix(strings.TrimSpace(s), "@")
+	if i := strings.LastIndex(slug, "/"); i >= 0 {
+		slug = slug[i+1:]
+	}
+	if slug == "" || strings.ContainsAny(slug, " <>|") {
+		return "", errors.Errorf("invalid team %q", s)
+	}
+	return strings.ToLower(slug), nil
+}
+
+// ParseSlackUserGroup parses user group mention or user group id.
+func ParseSlackUserGroup(s string) (string, error) {
+	if m := slackUserGroupRegexp.FindStringSubmatch(s); m != nil {
+		return m[1], nil
+	}
+	if slackIDRegexp.MatchString(s) {
+		return s, nil
+	}
+	return "", errors.Errorf("invalid user group %q. mention user group like @backend-team", s)
+}
+
+// ParseSlackUsers parses user mentions or user ids.
+func ParseSlackUsers(ss []string) ([]string, error) {
+	var ids []string
+	for _, s := range ss {
+		id := ""
+		if m := slackUserRegexp.FindStringSubmatch(s); m != nil {
+			id = m[1]
+		} else if slackIDRegexp.MatchString(s) {
+			id = s
+		} else {
+			return nil, errors.Errorf("invalid user %q. mention user like @ymgyt", s)
+		}
+		if !stringsContain(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// Teams -
+type Teams []*Team
+
+// Header implements Tabular.
+func (ts Teams) Header() []string {
+	return []string{"slug", "usergroup_id", "members", "updated_by", "updated_at"}
+}
+
+// Rows implements Tabular.
+func (ts Teams) Rows() [][]string {
+	rows := make([][]string, 0, len(ts))
+	for _, t := range ts {
+		rows = append(rows, []string{
+			t.Slug,
+			t.UserGroupID,
+			strings.Join(t.Members, ","),
+			t.UpdatedBy,
+			t.UpdatedAt.In(TimeZone).Format(time.RFC3339),
+		})
+	}
+	return rows
+}
+
+// TeamStore persists github team mappings.
+type TeamStore interface {
+	// GetTeam returns NotFound error when team is not mapped.
+	GetTeam(ctx context.Context, slug string) (*Team, error)
+	SaveTeam(context.Context, *Team) error
+	ListTeams(context.Context) (Teams, error)
+}
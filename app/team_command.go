@@ -0,0 +1,142 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/juju/errors"
+	"github.com/nlopes/slack"
+	"github.com/ymgyt/cli"
+)
+
+func NewAddTeamCommand(teams TeamStore) *cli.Command {
+	addTeamCmd := &addTeamCommand{}
+	cmd := &cli.Command{
+		Name:      "team",
+		ShortDesc: "add github team mapping",
+		LongDesc: "map github team to slack user group or members\n" +
+			"Usage: @gobot add team <TEAM_SLUG> <OPTIONS> [@MEMBER]...\n\n" +
+			"# review requestされたteamをuser groupでmention\n" +
+			"@gobot add team backend --usergroup=@backend-team\n" +
+			"# user groupがない場合はmemberを指定\n" +
+			"@gobot add team ymgyt/infra @ymgyt @octocat",
+		Run: addTeamCmd.runFunc(teams),
+	}
+	if err := cmd.Options().
+		Add(&cli.BoolOpt{Var: &addTeamCmd.printHelp, Long: "help", Description: "print help"}).
+		Add(&cli.StringOpt{Var: &addTeamCmd.UserGroup, Long: "usergroup", Description: "slack user group mentioned instead of members"}).
+		Err; err != nil {
+		panic(err)
+	}
+	return cmd
+}
+
+type addTeamCommand struct {
+	baseCommand
+	UserGroup string
+}
+
+func (c *addTeamCommand) runFunc(teams TeamStore) commandFunc {
+	return func(ctx context.Context, cmd *cli.Command, args []string) {
+		if c.printHelp || len(args) < 1 {
+			cli.HelpFunc(cmd.Stdout, cmd)
+			return
+		}
+		sm := getSlackMessage(ctx)
+
+		team, err := c.team(args[0], args[1:])
+		if err != nil {
+			sm.Fail(err)
+			return
+		}
+		team.UpdatedBy = sm.user.ID
+		if err := teams.SaveTeam(ctx, team); err != nil {
+			sm.Fail(err)
+			return
+		}
+
+		text := fmt.Sprintf("team %s mapped", team.Slug)
+		sm.PostAttachment(slack.Attachment{
+			Fallback: text,
+			Color:    slackColorGreen,
+			Pretext:  slackEmojiOKHand + " " + text,
+			Fields:   []slack.AttachmentField{{Title: team.Slug, Value: team.Mention()}},
+		})
+	}
+}
+
+func (c *addTeamCommand) team(slug string, members []string) (*Team, error) {
+	slug, err := NormalizeTeamSlug(slug)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	team := &Team{Slug: slug}
+	if c.UserGroup != "" {
+		if team.UserGroupID, err = ParseSlackUserGroup(c.UserGroup); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	if team.Members, err = ParseSlackUsers(members); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if team.UserGroupID == "" && len(team.Members) == 0 {
+		return nil, errors.New("--usergroup or members required")
+	}
+	return team, nil
+}
+
+func NewLsTeamsCommand(teams TeamStore) *cli.Command {
+	lsTeamsCmd := &lsTeamsCommand{}
+	cmd := &cli.Command{
+		Name:      "team",
+		Aliases:   []string{"teams"},
+		ShortDesc: "ls github team mappings",
+		LongDesc: "ls github team mappings\n" +
+			"Usage: @gobot ls teams <OPTIONS>",
+		Run: lsTeamsCmd.runFunc(teams),
+	}
+	if err := cmd.Options().
+		Add(&cli.BoolOpt{Var: &lsTeamsCmd.printHelp, Long: "help", Description: "print help"}).
+		Add(lsTeamsCmd.option()).
+		Err; err != nil {
+		panic(err)
+	}
+	return cmd
+}
+
+type lsTeamsCommand struct {
+	baseCommand
+	lsOutput
+}
+
+func (c *lsTeamsCommand) runFunc(teams TeamStore) commandFunc {
+	return func(ctx context.Context, cmd *cli.Command, args []string) {
+		if c.printHelp {
+			cli.HelpFunc(cmd.Stdout, cmd)
+			return
+		}
+		sm := getSlackMessage(ctx)
+
+		ts, err := teams.ListTeams(ctx)
+		if err != nil {
+			sm.Fail(err)
+			return
+		}
+		if c.enabled() {
+			c.post(sm, "teams", ts)
+			return
+		}
+
+		text := fmt.Sprintf("%d team(s) found", len(ts))
+		fields := make([]slack.AttachmentField, 0, len(ts))
+		for _, t := range ts {
+			fields = append(fields, slack.AttachmentField{Title: t.Slug, Value: t.Mention()})
+		}
+		sm.PostAttachment(slack.Attachment{
+			Fallback: text,
+			Color:    slackColorGreen,
+			Pretext:  text,
+			Fields:   fields,
+		})
+	}
+}
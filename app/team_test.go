@@ -0,0 +1,76 @@
+package app_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/ymgyt/gobot/app"
+)
+
+func TestTeam_Mention(t *testing.T) {
+	tests := map[string]struct {
+		team *app.Team
+		want string
+	}{
+		"user group": {
+			team: &app.Team{Slug: "backend", UserGroupID: "S0123", Members: []string{"U1"}},
+			want: "<!subteam^S0123>",
+		},
+		"members": {
+			team: &app.Team{Slug: "backend", Members: []string{"U1", "U2"}},
+			want: "<@U1> <@U2>",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tc.team.Mention(); got != tc.want {
+				t.Errorf("want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestNormalizeTeamSlug(t *testing.T) {
+	for _, s := range []string{"backend", "@ymgyt/Backend", "ymgyt/backend"} {
+		got, err := app.NormalizeTeamSlug(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != "backend" {
+			t.Errorf("%s: want backend, got %s", s, got)
+		}
+	}
+	if _, err := app.NormalizeTeamSlug("ymgyt/"); err == nil {
+		t.Error("want error, got nil")
+	}
+}
+
+func TestParseSlackUserGroup(t *testing.T) {
+	for _, s := range []string{"<!subteam^S0123|@backend-team>", "<!subteam^S0123>", "S0123"} {
+		got, err := app.ParseSlackUserGroup(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != "S0123" {
+			t.Errorf("%s: want S0123, got %s", s, got)
+		}
+	}
+	if _, err := app.ParseSlackUserGroup("@backend-team"); err == nil {
+		t.Error("want error, got nil")
+	}
+}
+
+func TestParseSlackUsers(t *testing.T) {
+	got, err := app.ParseSlackUsers([]string{"<@U1>", "<@U2|ymgyt>", "U1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"U1", "U2"}, got); diff != "" {
+		t.Errorf("(-want +got)\n%s", diff)
+	}
+	if _, err := app.ParseSlackUsers([]string{"@ymgyt"}); err == nil {
+		t.Error("want error, got nil")
+	}
+}
@@ -0,0 +1,345 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/nlopes/slack"
+	"github.com/ymgyt/cli"
+)
+
+func NewTemplateCommand(b *CommandBuilder) *cli.Command {
+	cmd := &cli.Command{
+		Name:      "template",
+		Aliases:   []string{"templates"},
+		ShortDesc: "operate notification templates",
+		LongDesc: "@gobot template <COMMAND> <OPTIONS> <ARGS>\n\n" +
+			"templates: review_requested,review_submitted,review_dismissed,review_request_removed,review_reminder,issue,comment,ci_failure,release,tag,deployment,push,security_alert\n" +
+			"fields: emoji,color,pretext,title,text (go text/template)",
+	}
+	return cmd.
+		AddCommand(NewTemplateSetCommand(b.Templates)).
+		AddCommand(NewTemplateLsCommand(b.Templates)).
+		AddCommand(NewTemplateDeleteCommand(b.Templates)).
+		AddCommand(NewTemplatePreviewCommand(b.Templates))
+}
+
+// templateScope is embedded in template sub commands to specify repository and channel.
+type templateScope struct {
+	Repository string
+	Channel    string
+	Here       bool
+}
+
+func (s *templateScope) repoOption() *cli.StringOpt {
+	return &cli.StringOpt{Var: &s.Repository, Long: "repo", Description: "apply to repository(owner/repo)"}
+}
+
+func (s *templateScope) channelOption() *cli.StringOpt {
+	return &cli.StringOpt{Var: &s.Channel, Long: "channel", Description: "apply to channel"}
+}
+
+func (s *templateScope) hereOption() *cli.BoolOpt {
+	return &cli.BoolOpt{Var: &s.Here, Long: "here", Description: "apply to this channel"}
+}
+
+func (s *templateScope) resolve(sm *SlackMessage) (repository, channel string, err error) {
+	if s.Repository != "" {
+		if repository, err = NormalizeRepository(s.Repository); err != nil {
+			return "", "", errors.Trace(err)
+		}
+	}
+	switch {
+	case s.Here && s.Channel != "":
+		return "", "", errors.New("--here and --channel can not be specified together")
+	case s.Here:
+		channel = sm.event.Channel
+	case s.Channel != "":
+		if channel, err = ResolveSlackChannelID(sm.client, s.Channel); err != nil {
+			return "", "", errors.Trace(err)
+		}
+	}
+	return repository, channel, nil
+}
+
+func NewTemplateSetCommand(templates TemplateStore) *cli.Command {
+	templateSetCmd := &templateSetCommand{}
+	cmd := &cli.Command{
+		Name:      "set",
+		ShortDesc: "set notification template",
+		LongDesc: "set notification template\n" +
+			"Usage: @gobot template set <OPTIONS> <TEMPLATE> <FIELD> <TEXT>...\n\n" +
+			"# gobot repositoryのreview結果のemojiを変更\n" +
+			"@gobot template set --repo=ymgyt/gobot review_submitted emoji {{if eq .ReviewState \"approved\"}}:tada:{{else}}:eyes:{{end}}\n" +
+			"# このchannelだけpretextを変更\n" +
+			"@gobot template set --here review_requested pretext {{.Emoji}} {{.ReviewerMentions}} please review {{.Title}}",
+		Run: templateSetCmd.runFunc(templates),
+	}
+	if err := cmd.Options().
+		Add(&cli.BoolOpt{Var: &templateSetCmd.printHelp, Long: "help", Description: "print help"}).
+		Add(templateSetCmd.repoOption()).
+		Add(templateSetCmd.channelOption()).
+		Add(templateSetCmd.hereOption()).
+		Err; err != nil {
+		panic(err)
+	}
+	return cmd
+}
+
+type templateSetCommand struct {
+	baseCommand
+	templateScope
+}
+
+func (c *templateSetCommand) runFunc(templates TemplateStore) commandFunc {
+	return func(ctx context.Context, cmd *cli.Command, args []string) {
+		if c.printHelp || len(args) < 3 {
+			cli.HelpFunc(cmd.Stdout, cmd)
+			return
+		}
+		sm := getSlackMessage(ctx)
+
+		kind, err := ParseTemplateKind(args[0])
+		if err != nil {
+			sm.Fail(err)
+			return
+		}
+		field, text := args[1], smartQuoteReplacer.Replace(strings.Join(args[2:], " "))
+		if err := ValidateTemplate(kind, field, text); err != nil {
+			sm.Fail(err)
+			return
+		}
+		repository, channel, err := c.resolve(sm)
+		if err != nil {
+			sm.Fail(err)
+			return
+		}
+
+		tmpl := &NotificationTemplate{Kind: kind, Repository: repository, Channel: channel, UpdatedBy: sm.user.ID}
+		if err := templates.SetTemplate(ctx, tmpl, field, text); err != nil {
+			sm.Fail(err)
+			return
+		}
+
+		msg := fmt.Sprintf("%s template of %s updated", field, tmpl)
+		sm.PostAttachment(slack.Attachment{
+			Fallback: msg,
+			Color:    slackColorGreen,
+			Pretext:  slackEmojiOKHand + " " + msg,
+			Text:     LiteralizeLine(text),
+		})
+	}
+}
+
+func NewTemplateLsCommand(templates TemplateStore) *cli.Command {
+	templateLsCmd := &templateLsCommand{}
+	cmd := &cli.Command{
+		Name:      "ls",
+		Aliases:   []string{"list"},
+		ShortDesc: "ls notification templates",
+		LongDesc: "ls customized notification templates\n" +
+			"Usage: @gobot template ls <OPTIONS> [TEMPLATE]\n\n" +
+			"# 組み込みのtemplateを表示\n" +
+			"@gobot template ls --default review_submitted",
+		Run: templateLsCmd.runFunc(templates),
+	}
+	if err := cmd.Options().
+		Add(&cli.BoolOpt{Var: &templateLsCmd.printHelp, Long: "help", Description: "print help"}).
+		Add(&cli.BoolOpt{Var: &templateLsCmd.Default, Long: "default", Description: "ls built-in templates"}).
+		Add(templateLsCmd.option()).
+		Err; err != nil {
+		panic(err)
+	}
+	return cmd
+}
+
+type templateLsCommand struct {
+	baseCommand
+	lsOutput
+	Default bool
+}
+
+func (c *templateLsCommand) runFunc(templates TemplateStore) commandFunc {
+	return func(ctx context.Context, cmd *cli.Command, args []string) {
+		if c.printHelp {
+			cli.HelpFunc(cmd.Stdout, cmd)
+			return
+		}
+		sm := getSlackMessage(ctx)
+
+		var kind TemplateKind
+		if len(args) > 0 {
+			var err error
+			if kind, err = ParseTemplateKind(args[0]); err != nil {
+				sm.Fail(err)
+				return
+			}
+		}
+
+		var ts NotificationTemplates
+		if c.Default {
+			for _, k := range TemplateKinds {
+				if kind == "" || kind == k {
+					ts = append(ts, DefaultNotificationTemplates[k])
+				}
+			}
+		} else {
+			var err error
+			if ts, err = templates.ListTemplates(ctx, kind); err != nil {
+				sm.Fail(err)
+				return
+			}
+		}
+		if c.enabled() {
+			c.post(sm, "templates", ts)
+			return
+		}
+
+		text := fmt.Sprintf("%d template(s) found", len(ts))
+		var fields []slack.AttachmentField
+		for _, row := range ts.Rows() {
+			fields = append(fields, slack.AttachmentField{
+				Title: strings.Join([]string{row[0], row[1], row[2], row[3]}, " "),
+				Value: LiteralizeLine(row[4]),
+			})
+		}
+		sm.PostAttachment(slack.Attachment{
+			Fallback: text,
+			Color:    slackColorGreen,
+			Pretext:  text,
+			Fields:   fields,
+		})
+	}
+}
+
+func NewTemplateDeleteCommand(templates TemplateStore) *cli.Command {
+	templateDeleteCmd := &templateDeleteCommand{}
+	cmd := &cli.Command{
+		Name:      "delete",
+		Aliases:   []string{"rm"},
+		ShortDesc: "delete notification template",
+		LongDesc: "delete customized notification template. built-in template is used again.\n" +
+			"Usage: @gobot template delete <OPTIONS> <TEMPLATE>\n\n" +
+			"@gobot template delete --repo=ymgyt/gobot review_submitted",
+		Run: templateDeleteCmd.runFunc(templates),
+	}
+	if err := cmd.Options().
+		Add(&cli.BoolOpt{Var: &templateDeleteCmd.printHelp, Long: "help", Description: "print help"}).
+		Add(templateDeleteCmd.repoOption()).
+		Add(templateDeleteCmd.channelOption()).
+		Add(templateDeleteCmd.hereOption()).
+		Err; err != nil {
+		panic(err)
+	}
+	return cmd
+}
+
+type templateDeleteCommand struct {
+	baseCommand
+	templateScope
+}
+
+func (c *templateDeleteCommand) runFunc(templates TemplateStore) commandFunc {
+	return func(ctx context.Context, cmd *cli.Command, args []string) {
+		if c.printHelp || len(args) != 1 {
+			cli.HelpFunc(cmd.Stdout, cmd)
+			return
+		}
+		sm := getSlackMessage(ctx)
+
+		kind, err := ParseTemplateKind(args[0])
+		if err != nil {
+			sm.Fail(err)
+			return
+		}
+		repository, channel, err := c.resolve(sm)
+		if err != nil {
+			sm.Fail(err)
+			return
+		}
+		if err := templates.DeleteTemplate(ctx, kind, repository, channel); err != nil {
+			sm.Fail(err)
+			return
+		}
+
+		tmpl := &NotificationTemplate{Kind: kind, Repository: repository, Channel: channel}
+		text := fmt.Sprintf("template %s deleted", tmpl)
+		sm.PostAttachment(slack.Attachment{
+			Fallback: text,
+			Color:    slackColorGreen,
+			Pretext:  slackEmojiOKHand + " " + text,
+		})
+	}
+}
+
+func NewTemplatePreviewCommand(templates TemplateStore) *cli.Command {
+	templatePreviewCmd := &templatePreviewCommand{}
+	cmd := &cli.Command{
+		Name:      "preview",
+		ShortDesc: "preview notification template",
+		LongDesc: "render notification template with sample data\n" +
+			"Usage: @gobot template preview <OPTIONS> <TEMPLATE>\n\n" +
+			"@gobot template preview --repo=ymgyt/gobot --here review_submitted",
+		Run: templatePreviewCmd.runFunc(templates),
+	}
+	if err := cmd.Options().
+		Add(&cli.BoolOpt{Var: &templatePreviewCmd.printHelp, Long: "help", Description: "print help"}).
+		Add(templatePreviewCmd.repoOption()).
+		Add(templatePreviewCmd.channelOption()).
+		Add(templatePreviewCmd.hereOption()).
+		Err; err != nil {
+		panic(err)
+	}
+	return cmd
+}
+
+type templatePreviewCommand struct {
+	baseCommand
+	templateScope
+}
+
+func (c *templatePreviewCommand) runFunc(templates TemplateStore) commandFunc {
+	return func(ctx context.Context, cmd *cli.Command, args []string) {
+		if c.printHelp || len(args) != 1 {
+			cli.HelpFunc(cmd.Stdout, cmd)
+			return
+		}
+		sm := getSlackMessage(ctx)
+
+		kind, err := ParseTemplateKind(args[0])
+		if err != nil {
+			sm.Fail(err)
+			return
+		}
+		repository, channel, err := c.resolve(sm)
+		if err != nil {
+			sm.Fail(err)
+			return
+		}
+		ts, err := templates.ListTemplates(ctx, kind)
+		if err != nil {
+			sm.Fail(err)
+			return
+		}
+
+		data := SampleTemplateData(kind)
+		if repository != "" {
+			data["Repository"] = repository
+		}
+		rendered, err := RenderTemplate(kind, ts.Resolve(kind, repository, channel), data)
+		if err != nil {
+			sm.Fail(err)
+			return
+		}
+		sm.PostAttachment(slack.Attachment{
+			Fallback: "template preview",
+			Color:    rendered.Color,
+			Pretext:  rendered.Pretext,
+			Title:    rendered.Title,
+			Text:     rendered.Text,
+			Footer:   "preview " + footerSuffix(),
+		})
+	}
+}
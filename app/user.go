@@ -26,6 +26,20 @@ type FindUsersInput struct {
 	IncludeDeleted bool
 }
 
+type ListUsersInput struct {
+	Query          *UserQuery
+	Filter         *User
+	Sort           string
+	Cursor         string
+	Limit          int64
+	IncludeDeleted bool
+}
+
+type ListUsersOutput struct {
+	Users      Users
+	NextCursor string
+}
+
 type DeleteUsersInput struct {
 	Filter *User
 	All    bool
@@ -41,6 +55,7 @@ type UserStore interface {
 	AddUser(context.Context, *User) error
 	UpdateUser(context.Context, *UpdateUserInput) error
 	FindUsers(context.Context, *FindUsersInput) (Users, error)
+	ListUsers(context.Context, *ListUsersInput) (*ListUsersOutput, error)
 	DeleteUsers(context.Context, *DeleteUsersInput) (*DeleteUsersOutput, error)
 }
 
@@ -72,11 +87,16 @@ func (gp GithubProfile) Merge(other GithubProfile) GithubProfile {
 }
 
 type SlackProfile struct {
+	// ID is slack user id. it is recorded by reconciliation to detect email changes on slack side.
+	ID    string `json:"id,omitempty" bson:"id,omitempty"`
 	Email string `json:"email" bson:"email,omitempty"`
 }
 
 func (sp SlackProfile) BsonD() bson.D {
 	d := bson.D{}
+	if sp.ID != "" {
+		d = append(d, primitive.E{Key: "id", Value: sp.ID})
+	}
 	if sp.Email != "" {
 		d = append(d, primitive.E{Key: "email", Value: sp.Email})
 	}
@@ -84,6 +104,9 @@ func (sp SlackProfile) BsonD() bson.D {
 }
 
 func (sp SlackProfile) Merge(other SlackProfile) SlackProfile {
+	if other.ID != "" {
+		sp.ID = other.ID
+	}
 	if other.Email != "" {
 		sp.Email = other.Email
 	}
@@ -177,6 +200,24 @@ func (u *User) Merge(other *User) *User {
 	return clone
 }
 
+// ListAllUsers fetches every user page by page.
+func ListAllUsers(ctx context.Context, us UserStore, includeDeleted bool) (Users, error) {
+	const pageSize = 100
+	all := Users{}
+	input := &ListUsersInput{Limit: pageSize, IncludeDeleted: includeDeleted}
+	for {
+		output, err := us.ListUsers(ctx, input)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		all = append(all, output.Users...)
+		if output.NextCursor == "" {
+			return all, nil
+		}
+		input.Cursor = output.NextCursor
+	}
+}
+
 func (u *User) Debug() string {
 	return spew.Sdump(u)
 }
@@ -218,6 +259,32 @@ func (users Users) SlackAttachmentFields(tmpl *template.Template) ([]slack.Attac
 	return fields, nil
 }
 
+// Header implements Tabular.
+func (users Users) Header() []string {
+	return []string{"github.user_name", "slack.email", "created_at", "updated_at", "deleted_at"}
+}
+
+// Rows implements Tabular.
+func (users Users) Rows() [][]string {
+	format := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+	rows := make([][]string, 0, len(users))
+	for _, user := range users {
+		rows = append(rows, []string{
+			user.Github.UserName,
+			user.Slack.Email,
+			format(user.CreatedAt),
+			format(user.UpdatedAt),
+			format(user.DeletedAt),
+		})
+	}
+	return rows
+}
+
 var userInputReplacer = strings.NewReplacer(`”`, `"`, `“`, `"`, `‘`, `"`, `’`, `"`, "`", "")
 
 func ReadUserFromSlackInput(s string) (*User, error) {
@@ -0,0 +1,241 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+const (
+	userImportPlanTTL = 30 * time.Minute
+)
+
+// UserImportAction -
+type UserImportAction string
+
+const (
+	UserImportCreate UserImportAction = "create"
+	UserImportUpdate UserImportAction = "update"
+	UserImportSkip   UserImportAction = "skip"
+)
+
+// ReadUsersFromFile reads users exported by export users command. csv and json are supported.
+func ReadUsersFromFile(filename string, content []byte) (Users, error) {
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".json":
+		var users Users
+		if err := json.Unmarshal(content, &users); err != nil {
+			return nil, errors.Annotatef(err, "failed to parse json. file=%s", filename)
+		}
+		return users, nil
+	case ".csv":
+		return readUsersFromCSV(content)
+	default:
+		return nil, errors.Errorf("unsupported file %s. csv or json required", filename)
+	}
+}
+
+func readUsersFromCSV(content []byte) (Users, error) {
+	r := csv.NewReader(bytes.NewReader(content))
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, errors.Annotate(err, "failed to read csv header")
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	nameIdx, ok := columns["github.user_name"]
+	if !ok {
+		return nil, errors.New("csv header github.user_name required")
+	}
+	emailIdx, ok := columns["slack.email"]
+	if !ok {
+		return nil, errors.New("csv header slack.email required")
+	}
+
+	var users Users
+	for line := 2; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Annotatef(err, "line=%d", line)
+		}
+		get := func(idx int) string {
+			if idx < len(record) {
+				return strings.TrimSpace(record[idx])
+			}
+			return ""
+		}
+		users = append(users, &User{
+			Github: GithubProfile{UserName: get(nameIdx)},
+			Slack:  SlackProfile{Email: get(emailIdx)},
+		})
+	}
+	return users, nil
+}
+
+// UserImportEntry is a planned operation for an imported user.
+type UserImportEntry struct {
+	Action  UserImportAction
+	User    *User
+	Current *User
+	Reason  string
+}
+
+func (e *UserImportEntry) String() string {
+	switch e.Action {
+	case UserImportUpdate:
+		return fmt.Sprintf("update %s %s -> %s", e.User.Github.UserName, e.Current.Slack.Email, e.User.Slack.Email)
+	case UserImportSkip:
+		return fmt.Sprintf("skip   %s (%s)", e.User.Github.UserName, e.Reason)
+	default:
+		return fmt.Sprintf("%-6s %s %s", e.Action, e.User.Github.UserName, e.User.Slack.Email)
+	}
+}
+
+// UserImportPlan is a set of operations waiting for confirmation.
+type UserImportPlan struct {
+	ID          string
+	RequestedBy string
+	CreatedAt   time.Time
+	Entries     []*UserImportEntry
+}
+
+// PlanUserImport validates every user and decides whether to create, update or skip.
+func PlanUserImport(ctx context.Context, us UserStore, users Users) (*UserImportPlan, error) {
+	plan := &UserImportPlan{ID: newImportPlanID(), CreatedAt: Now()}
+	seen := make(map[string]bool)
+
+	for _, user := range users {
+		user = SanitizeUser(user)
+		entry := &UserImportEntry{Action: UserImportSkip, User: user}
+		plan.Entries = append(plan.Entries, entry)
+
+		if err := user.Validate(); err != nil {
+			entry.Reason = err.Error()
+			continue
+		}
+		if seen[user.Github.UserName] {
+			entry.Reason = "duplicated in file"
+			continue
+		}
+		seen[user.Github.UserName] = true
+
+		found, err := us.FindUsers(ctx, &FindUsersInput{
+			Limit:          1,
+			Filter:         user.IdentificationFilter(),
+			IncludeDeleted: true,
+		})
+		if IsUserNotFound(err) {
+			entry.Action = UserImportCreate
+			continue
+		}
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		entry.Current = found[0]
+		if entry.Current.Slack.Email == user.Slack.Email {
+			entry.Reason = "unchanged"
+			continue
+		}
+		entry.Action = UserImportUpdate
+	}
+	return plan, nil
+}
+
+// Count returns number of entries of action.
+func (p *UserImportPlan) Count(action UserImportAction) int {
+	var n int
+	for _, e := range p.Entries {
+		if e.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// Summary -
+func (p *UserImportPlan) Summary() string {
+	lines := make([]string, 0, len(p.Entries))
+	for _, e := range p.Entries {
+		lines = append(lines, e.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Apply executes planned operations. it stops at the first error.
+func (p *UserImportPlan) Apply(ctx context.Context, us UserStore) error {
+	for _, e := range p.Entries {
+		var err error
+		switch e.Action {
+		case UserImportCreate:
+			err = us.AddUser(ctx, e.User.Clone())
+		case UserImportUpdate:
+			err = us.UpdateUser(ctx, &UpdateUserInput{
+				Filter: e.Current.IdentificationFilter(),
+				User:   e.Current.Merge(e.User),
+			})
+		}
+		if err != nil {
+			return errors.Annotatef(err, "%s", e)
+		}
+	}
+	return nil
+}
+
+func newImportPlanID() string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// userImportPlans holds plans until requester confirms them.
+type userImportPlans struct {
+	sync.Mutex
+	m map[string]*UserImportPlan
+}
+
+func (ps *userImportPlans) put(plan *UserImportPlan) {
+	ps.Lock()
+	defer ps.Unlock()
+	if ps.m == nil {
+		ps.m = make(map[string]*UserImportPlan)
+	}
+	for id, p := range ps.m {
+		if time.Since(p.CreatedAt) > userImportPlanTTL {
+			delete(ps.m, id)
+		}
+	}
+	ps.m[plan.ID] = plan
+}
+
+// take removes and returns plan. only the user who requested the import can take it.
+func (ps *userImportPlans) take(id, requestedBy string) (*UserImportPlan, error) {
+	ps.Lock()
+	defer ps.Unlock()
+	plan, found := ps.m[id]
+	if !found || time.Since(plan.CreatedAt) > userImportPlanTTL {
+		return nil, errors.Errorf("import plan %s not found or expired", id)
+	}
+	if plan.RequestedBy != requestedBy {
+		return nil, errors.Errorf("import plan %s was requested by other user", id)
+	}
+	delete(ps.m, id)
+	return plan, nil
+}
@@ -0,0 +1,221 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/ymgyt/gobot/app"
+)
+
+// fakeUserStore keeps users in memory. users are identified by github user name.
+type fakeUserStore struct {
+	users app.Users
+	// errs fails write operations of the github user.
+	errs map[string]error
+}
+
+func (s *fakeUserStore) AddUser(ctx context.Context, user *app.User) error {
+	if err := s.errs[user.Github.UserName]; err != nil {
+		return err
+	}
+	s.users = append(s.users, user.Clone())
+	return nil
+}
+
+func (s *fakeUserStore) UpdateUser(ctx context.Context, input *app.UpdateUserInput) error {
+	if err := s.errs[input.Filter.Github.UserName]; err != nil {
+		return err
+	}
+	for i, u := range s.users {
+		if matchUser(u, input.Filter) {
+			s.users[i] = input.User.Clone()
+			return nil
+		}
+	}
+	return app.ErrUserNotFound
+}
+
+func (s *fakeUserStore) FindUsers(ctx context.Context, input *app.FindUsersInput) (app.Users, error) {
+	var found app.Users
+	for _, u := range s.users {
+		if (input.IncludeDeleted || !u.IsDeleted()) && matchUser(u, input.Filter) {
+			found = append(found, u.Clone())
+		}
+	}
+	if len(found) == 0 {
+		return nil, app.ErrUserNotFound
+	}
+	return found, nil
+}
+
+func (s *fakeUserStore) ListUsers(ctx context.Context, input *app.ListUsersInput) (*app.ListUsersOutput, error) {
+	output := &app.ListUsersOutput{}
+	for _, u := range s.users {
+		if input.IncludeDeleted || !u.IsDeleted() {
+			output.Users = append(output.Users, u.Clone())
+		}
+	}
+	return output, nil
+}
+
+func (s *fakeUserStore) DeleteUsers(ctx context.Context, input *app.DeleteUsersInput) (*app.DeleteUsersOutput, error) {
+	output := &app.DeleteUsersOutput{}
+	for i, u := range s.users {
+		if !u.IsDeleted() && matchUser(u, input.Filter) {
+			s.users[i].DeletedAt = app.Now()
+			output.SoftDeletedCount++
+		}
+	}
+	return output, nil
+}
+
+func (s *fakeUserStore) get(name string) *app.User {
+	for _, u := range s.users {
+		if u.Github.UserName == name {
+			return u
+		}
+	}
+	return nil
+}
+
+func matchUser(u, filter *app.User) bool {
+	if filter == nil {
+		return true
+	}
+	return (filter.Github.UserName == "" || filter.Github.UserName == u.Github.UserName) &&
+		(filter.Slack.ID == "" || filter.Slack.ID == u.Slack.ID) &&
+		(filter.Slack.Email == "" || filter.Slack.Email == u.Slack.Email)
+}
+
+func user(name, email string) *app.User {
+	return &app.User{Github: app.GithubProfile{UserName: name}, Slack: app.SlackProfile{Email: email}}
+}
+
+func TestReadUsersFromFile(t *testing.T) {
+	tests := map[string]struct {
+		filename string
+		content  string
+		want     app.Users
+		wantErr  string
+	}{
+		"csv": {
+			filename: "users.csv",
+			content:  "slack.email,github.user_name,created_at\nymgyt@example.com, ymgyt ,2019-01-01\noctocat@example.com,octocat\n",
+			want:     app.Users{user("ymgyt", "ymgyt@example.com"), user("octocat", "octocat@example.com")},
+		},
+		"json": {
+			filename: "USERS.JSON",
+			content:  `[{"github":{"user_name":"ymgyt"},"slack":{"email":"ymgyt@example.com"}}]`,
+			want:     app.Users{user("ymgyt", "ymgyt@example.com")},
+		},
+		"csv without email header": {
+			filename: "users.csv",
+			content:  "github.user_name\nymgyt\n",
+			wantErr:  "slack.email required",
+		},
+		"broken json": {
+			filename: "users.json",
+			content:  `[{"github":`,
+			wantErr:  "failed to parse json",
+		},
+		"unsupported file": {
+			filename: "users.txt",
+			content:  "ymgyt",
+			wantErr:  "unsupported file",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := app.ReadUsersFromFile(tc.filename, []byte(tc.content))
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("want error %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("(-want +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPlanUserImport(t *testing.T) {
+	deleted := user("deleted", "deleted@example.com")
+	deleted.DeletedAt = app.Now()
+	us := &fakeUserStore{users: app.Users{
+		user("ymgyt", "ymgyt@example.com"),
+		user("octocat", "old@example.com"),
+		deleted,
+	}}
+
+	plan, err := app.PlanUserImport(context.Background(), us, app.Users{
+		user("ymgyt", "ymgyt@example.com"),
+		user("octocat", "<mailto:new@example.com|new@example.com>"),
+		user("alice", "alice@example.com"),
+		user("alice", "alice2@example.com"),
+		user("deleted", "restored@example.com"),
+		user("", "noname@example.com"),
+		user("bob", "invalid"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, e := range plan.Entries {
+		got = append(got, e.String())
+	}
+	want := []string{
+		"skip   ymgyt (unchanged)",
+		"update octocat old@example.com -> new@example.com",
+		"create alice alice@example.com",
+		"skip   alice (duplicated in file)",
+		"update deleted deleted@example.com -> restored@example.com",
+		"skip    (github.user_name required)",
+		"skip   bob (invalid email address)",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want +got)\n%s", diff)
+	}
+	if c, u, s := plan.Count(app.UserImportCreate), plan.Count(app.UserImportUpdate), plan.Count(app.UserImportSkip); c != 1 || u != 2 || s != 4 {
+		t.Errorf("counts create=%d update=%d skip=%d", c, u, s)
+	}
+}
+
+func TestUserImportPlan_Apply(t *testing.T) {
+	ctx := context.Background()
+	us := &fakeUserStore{users: app.Users{user("octocat", "old@example.com")}}
+	plan, err := app.PlanUserImport(ctx, us, app.Users{
+		user("octocat", "new@example.com"),
+		user("alice", "alice@example.com"),
+		user("bob", "bob@example.com"),
+		user("carol", "carol@example.com"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	us.errs = map[string]error{"bob": errors.New("connection reset")}
+
+	err = plan.Apply(ctx, us)
+	if err == nil || !strings.Contains(err.Error(), "create bob bob@example.com") || !strings.Contains(err.Error(), "connection reset") {
+		t.Fatalf("want error of bob, got %v", err)
+	}
+	// entries before the failure are applied and the rest are not.
+	if got := us.get("octocat").Slack.Email; got != "new@example.com" {
+		t.Errorf("octocat email %s", got)
+	}
+	if us.get("alice") == nil {
+		t.Error("alice not created")
+	}
+	if us.get("carol") != nil {
+		t.Error("carol created after failure")
+	}
+}
@@ -0,0 +1,287 @@
+package app
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	userQueryDateLayout = "2006-01-02"
+)
+
+// UserQuery is a parsed form of the ls users query language.
+//
+//	name=ymgyt            exact match on github user name
+//	name^ym               prefix match
+//	name~^ym.*t$          regular expression
+//	email=..., email^..., email~...
+//	created>2019-01-01    created_at comparison (>, >=, <, <=)
+//	updated-since=72h     updated_at since date or duration
+//	deleted               soft deleted users only
+type UserQuery struct {
+	Conditions  []UserCondition
+	DeletedOnly bool
+}
+
+// UserCondition is a single term of UserQuery.
+type UserCondition struct {
+	Key      string // mongo document key. ex. github.user_name
+	Operator string
+	Value    interface{}
+}
+
+var userQueryFields = map[string]string{
+	"name":    "github.user_name",
+	"email":   "slack.email",
+	"created": "created_at",
+	"updated": "updated_at",
+}
+
+// ParseUserQuery parses ls users query terms.
+func ParseUserQuery(terms []string, now time.Time) (*UserQuery, error) {
+	q := &UserQuery{}
+	for _, term := range terms {
+		if term == "deleted" {
+			q.DeletedOnly = true
+			continue
+		}
+		cond, err := parseUserCondition(term, now)
+		if err != nil {
+			return nil, errors.Annotatef(err, "term=%s", term)
+		}
+		q.Conditions = append(q.Conditions, cond)
+	}
+	return q, nil
+}
+
+func parseUserCondition(term string, now time.Time) (UserCondition, error) {
+	if strings.HasPrefix(term, "updated-since=") {
+		since, err := parseUserQueryTime(strings.TrimPrefix(term, "updated-since="), now)
+		if err != nil {
+			return UserCondition{}, err
+		}
+		return UserCondition{Key: userQueryFields["updated"], Operator: ">=", Value: since}, nil
+	}
+
+	idx := strings.IndexAny(term, "=^~<>")
+	if idx <= 0 {
+		return UserCondition{}, errors.New("operator not found")
+	}
+	op := term[idx : idx+1]
+	if (op == ">" || op == "<") && strings.HasPrefix(term[idx+1:], "=") {
+		op += "="
+	}
+	name, value := term[:idx], term[idx+len(op):]
+	key, ok := userQueryFields[name]
+	if !ok {
+		return UserCondition{}, errors.Errorf("unknown field %s", name)
+	}
+
+	switch name {
+	case "name", "email":
+		if name == "email" {
+			value = SanitizeEmail(value)
+		}
+		switch op {
+		case "=":
+			return UserCondition{Key: key, Operator: op, Value: value}, nil
+		case "^":
+			return UserCondition{Key: key, Operator: "~", Value: "^" + regexp.QuoteMeta(value)}, nil
+		case "~":
+			if _, err := regexp.Compile(value); err != nil {
+				return UserCondition{}, errors.Annotate(err, "invalid regexp")
+			}
+			return UserCondition{Key: key, Operator: op, Value: value}, nil
+		}
+	default:
+		if op == "^" || op == "~" {
+			break
+		}
+		t, err := parseUserQueryTime(value, now)
+		if err != nil {
+			return UserCondition{}, err
+		}
+		return UserCondition{Key: key, Operator: op, Value: t}, nil
+	}
+	return UserCondition{}, errors.Errorf("operator %s is not supported for %s", op, name)
+}
+
+// parseUserQueryTime accepts date(2019-01-01), RFC3339 or duration(72h) which means now - duration.
+func parseUserQueryTime(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation(userQueryDateLayout, s, TimeZone); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, errors.Errorf("invalid time %s. use 2019-01-01, RFC3339 or duration like 72h", s)
+	}
+	return t, nil
+}
+
+// BsonD returns mongo filter. includeDeleted is ignored when query requires deleted users only.
+func (q *UserQuery) BsonD(includeDeleted bool) bson.D {
+	var conds bson.A
+	if q != nil {
+		for _, c := range q.Conditions {
+			conds = append(conds, c.BsonD())
+		}
+	}
+	switch {
+	case q != nil && q.DeletedOnly:
+		conds = append(conds, bson.D{{Key: "deleted_at", Value: bson.D{{Key: "$gt", Value: time.Time{}}}}})
+	case !includeDeleted:
+		conds = append(conds, bson.D{{Key: "deleted_at", Value: time.Time{}}})
+	}
+	if len(conds) == 0 {
+		return bson.D{}
+	}
+	return bson.D{{Key: "$and", Value: conds}}
+}
+
+// BsonD -
+func (c UserCondition) BsonD() bson.D {
+	var op string
+	switch c.Operator {
+	case "=":
+		return bson.D{{Key: c.Key, Value: c.Value}}
+	case "~":
+		return bson.D{{Key: c.Key, Value: primitive.Regex{Pattern: fmt.Sprint(c.Value)}}}
+	case ">":
+		op = "$gt"
+	case ">=":
+		op = "$gte"
+	case "<":
+		op = "$lt"
+	case "<=":
+		op = "$lte"
+	}
+	return bson.D{{Key: c.Key, Value: bson.D{{Key: op, Value: c.Value}}}}
+}
+
+// UserSort represents ls users --sort option. ex. "name", "-created"
+type UserSort struct {
+	Field      string
+	Key        string
+	Descending bool
+}
+
+const userSortTieBreakerKey = "github.user_name"
+
+// ParseUserSort -
+func ParseUserSort(s string) (*UserSort, error) {
+	if s == "" {
+		s = "name"
+	}
+	sort := &UserSort{}
+	if strings.HasPrefix(s, "-") {
+		sort.Descending = true
+		s = s[1:]
+	}
+	key, ok := userQueryFields[s]
+	if !ok {
+		return nil, errors.Errorf("unknown sort field %s. available: name, email, created, updated", s)
+	}
+	sort.Field, sort.Key = s, key
+	return sort, nil
+}
+
+func (s *UserSort) direction() int {
+	if s.Descending {
+		return -1
+	}
+	return 1
+}
+
+func (s *UserSort) comparison() string {
+	if s.Descending {
+		return "$lt"
+	}
+	return "$gt"
+}
+
+// BsonD returns mongo sort document. github user name is used as tie breaker.
+func (s *UserSort) BsonD() bson.D {
+	d := bson.D{{Key: s.Key, Value: s.direction()}}
+	if s.Key != userSortTieBreakerKey {
+		d = append(d, primitive.E{Key: userSortTieBreakerKey, Value: s.direction()})
+	}
+	return d
+}
+
+// After returns mongo filter which matches users after cursor in this sort order.
+func (s *UserSort) After(c *UserCursor) bson.D {
+	cmp := s.comparison()
+	if s.Key == userSortTieBreakerKey {
+		return bson.D{{Key: s.Key, Value: bson.D{{Key: cmp, Value: c.UserName}}}}
+	}
+	return bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: s.Key, Value: bson.D{{Key: cmp, Value: c.Value}}}},
+		bson.D{
+			{Key: s.Key, Value: c.Value},
+			{Key: userSortTieBreakerKey, Value: bson.D{{Key: cmp, Value: c.UserName}}},
+		},
+	}}}
+}
+
+// UserCursor points the last user of a page.
+type UserCursor struct {
+	Value    interface{}
+	UserName string
+}
+
+type userCursorJSON struct {
+	Field    string `json:"f"`
+	Value    string `json:"v"`
+	UserName string `json:"n"`
+}
+
+// Cursor returns cursor which points user.
+func (s *UserSort) Cursor(user *User) string {
+	c := userCursorJSON{Field: s.Field, UserName: user.Github.UserName}
+	switch s.Field {
+	case "email":
+		c.Value = user.Slack.Email
+	case "created":
+		c.Value = user.CreatedAt.UTC().Format(time.RFC3339Nano)
+	case "updated":
+		c.Value = user.UpdatedAt.UTC().Format(time.RFC3339Nano)
+	}
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor decodes cursor which was created by same sort field.
+func (s *UserSort) DecodeCursor(cursor string) (*UserCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.Annotate(err, "invalid cursor")
+	}
+	var c userCursorJSON
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, errors.Annotate(err, "invalid cursor")
+	}
+	if c.Field != s.Field {
+		return nil, errors.Errorf("cursor was created with --sort=%s", c.Field)
+	}
+
+	uc := &UserCursor{Value: c.Value, UserName: c.UserName}
+	if s.Field == "created" || s.Field == "updated" {
+		t, err := time.Parse(time.RFC3339Nano, c.Value)
+		if err != nil {
+			return nil, errors.Annotate(err, "invalid cursor")
+		}
+		uc.Value = t
+	}
+	return uc, nil
+}
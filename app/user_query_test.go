@@ -0,0 +1,87 @@
+package app_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/ymgyt/gobot/app"
+)
+
+func TestParseUserQuery(t *testing.T) {
+	now := time.Date(2019, time.May, 1, 12, 0, 0, 0, app.TimeZone)
+
+	tests := map[string]struct {
+		terms   []string
+		want    *app.UserQuery
+		wantErr bool
+	}{
+		"exact, prefix and regexp": {
+			terms: []string{"name=ymgyt", "name^ym.g", "email~@example"},
+			want: &app.UserQuery{Conditions: []app.UserCondition{
+				{Key: "github.user_name", Operator: "=", Value: "ymgyt"},
+				{Key: "github.user_name", Operator: "~", Value: `^ym\.g`},
+				{Key: "slack.email", Operator: "~", Value: "@example"},
+			}},
+		},
+		"time comparison": {
+			terms: []string{"created>=2019-01-01", "updated-since=24h", "deleted"},
+			want: &app.UserQuery{
+				Conditions: []app.UserCondition{
+					{Key: "created_at", Operator: ">=", Value: time.Date(2019, time.January, 1, 0, 0, 0, 0, app.TimeZone)},
+					{Key: "updated_at", Operator: ">=", Value: now.Add(-24 * time.Hour)},
+				},
+				DeletedOnly: true,
+			},
+		},
+		"unknown field": {
+			terms:   []string{"age>20"},
+			wantErr: true,
+		},
+		"prefix on time field": {
+			terms:   []string{"created^2019"},
+			wantErr: true,
+		},
+	}
+
+	for desc, tc := range tests {
+		t.Run(desc, func(t *testing.T) {
+			got, err := app.ParseUserQuery(tc.terms, now)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("want error, but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Errorf("(-got +want)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestUserSort_Cursor(t *testing.T) {
+	sort, err := app.ParseUserSort("-created")
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := time.Date(2019, time.April, 26, 17, 23, 30, 0, time.UTC)
+	cursor := sort.Cursor(&app.User{Github: app.GithubProfile{UserName: "ymgyt"}, CreatedAt: created})
+
+	got, err := sort.DecodeCursor(cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.UserName != "ymgyt" || !got.Value.(time.Time).Equal(created) {
+		t.Errorf("unexpected cursor %#v", got)
+	}
+
+	other, _ := app.ParseUserSort("name")
+	if _, err := other.DecodeCursor(cursor); err == nil {
+		t.Error("cursor created by other sort field should be rejected")
+	}
+}
@@ -0,0 +1,111 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+const (
+	redactedHeaderValue = "[REDACTED]"
+)
+
+// headers which must not be archived.
+var secretHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"X-Hub-Signature",
+	"X-Hub-Signature-256",
+}
+
+// WebhookDelivery is a raw webhook request archived for investigation and replay.
+type WebhookDelivery struct {
+	ID         string            `json:"id" bson:"_id"`
+	Event      string            `json:"event" bson:"event"`
+	Headers    map[string]string `json:"headers" bson:"headers"`
+	Body       string            `json:"body" bson:"body"`
+	ReceivedAt time.Time         `json:"received_at" bson:"received_at"`
+	ExpireAt   time.Time         `json:"expire_at" bson:"expire_at"`
+}
+
+// NewWebhookDelivery creates delivery from request. secret headers are redacted.
+func NewWebhookDelivery(r *http.Request, body []byte, now time.Time, retention time.Duration) *WebhookDelivery {
+	headers := make(map[string]string, len(r.Header))
+	for key := range r.Header {
+		headers[key] = r.Header.Get(key)
+	}
+	for _, key := range secretHeaders {
+		if _, found := headers[http.CanonicalHeaderKey(key)]; found {
+			headers[http.CanonicalHeaderKey(key)] = redactedHeaderValue
+		}
+	}
+
+	id := r.Header.Get("X-GitHub-Delivery")
+	if id == "" {
+		id = NewOutboxID()
+	}
+	return &WebhookDelivery{
+		ID:         id,
+		Event:      r.Header.Get("X-GitHub-Event"),
+		Headers:    headers,
+		Body:       string(body),
+		ReceivedAt: now,
+		ExpireAt:   now.Add(retention),
+	}
+}
+
+// Header returns http header restored from archived headers.
+func (d *WebhookDelivery) Header() http.Header {
+	h := make(http.Header, len(d.Headers))
+	for key, value := range d.Headers {
+		if value == redactedHeaderValue {
+			continue
+		}
+		h.Set(key, value)
+	}
+	return h
+}
+
+// WebhookDeliveries -
+type WebhookDeliveries []*WebhookDelivery
+
+// Header implements Tabular.
+func (ds WebhookDeliveries) Header() []string {
+	return []string{"id", "event", "action", "received_at"}
+}
+
+// Rows implements Tabular.
+func (ds WebhookDeliveries) Rows() [][]string {
+	rows := make([][]string, 0, len(ds))
+	for _, d := range ds {
+		rows = append(rows, []string{d.ID, d.Event, d.action(), d.ReceivedAt.In(TimeZone).Format(time.RFC3339)})
+	}
+	return rows
+}
+
+func (d *WebhookDelivery) action() string {
+	var payload struct {
+		Action string `json:"action"`
+	}
+	_ = json.Unmarshal([]byte(d.Body), &payload)
+	return payload.Action
+}
+
+// ListWebhookDeliveriesInput -
+type ListWebhookDeliveriesInput struct {
+	Event string
+	Limit int64
+}
+
+// WebhookDeliveryStore archives raw webhook deliveries.
+type WebhookDeliveryStore interface {
+	SaveDelivery(context.Context, *WebhookDelivery) error
+	GetDelivery(ctx context.Context, id string) (*WebhookDelivery, error)
+	ListDeliveries(context.Context, *ListWebhookDeliveriesInput) (WebhookDeliveries, error)
+}
+
+// WebhookReplayer pushes archived delivery back through the webhook pipeline.
+type WebhookReplayer interface {
+	Replay(ctx context.Context, deliveryID string) error
+}
@@ -0,0 +1,138 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nlopes/slack"
+	"github.com/ymgyt/cli"
+)
+
+func NewWebhookCommand(b *CommandBuilder) *cli.Command {
+	cmd := &cli.Command{
+		Name:      "webhook",
+		ShortDesc: "operate archived github webhook deliveries",
+		LongDesc:  "@gobot webhook <COMMAND> <OPTIONS> <ARGS>",
+	}
+	return cmd.
+		AddCommand(NewWebhookLsCommand(b.WebhookDeliveries)).
+		AddCommand(NewWebhookReplayCommand(b.WebhookReplayer, b.Admins))
+}
+
+func NewWebhookLsCommand(deliveries WebhookDeliveryStore) *cli.Command {
+	webhookLsCmd := &webhookLsCommand{}
+	cmd := &cli.Command{
+		Name:      "ls",
+		Aliases:   []string{"list"},
+		ShortDesc: "ls archived webhook deliveries",
+		LongDesc: "ls archived webhook deliveries\n" +
+			"Usage: @gobot webhook ls <OPTIONS>\n\n" +
+			"@gobot webhook ls --event=pull_request --limit=5",
+		Run: webhookLsCmd.runFunc(deliveries),
+	}
+	if err := cmd.Options().
+		Add(&cli.BoolOpt{Var: &webhookLsCmd.printHelp, Long: "help", Description: "print help"}).
+		Add(&cli.StringOpt{Var: &webhookLsCmd.Event, Long: "event", Description: "filter by X-GitHub-Event"}).
+		Add(&cli.IntOpt{Var: &webhookLsCmd.Limit, Long: "limit", Description: "delivery limit. default 20"}).
+		Add(webhookLsCmd.option()).
+		Err; err != nil {
+		panic(err)
+	}
+	return cmd
+}
+
+type webhookLsCommand struct {
+	baseCommand
+	lsOutput
+	Event string
+	Limit int
+}
+
+func (c *webhookLsCommand) runFunc(deliveries WebhookDeliveryStore) commandFunc {
+	return func(ctx context.Context, cmd *cli.Command, args []string) {
+		if c.printHelp {
+			cli.HelpFunc(cmd.Stdout, cmd)
+			return
+		}
+		sm := getSlackMessage(ctx)
+
+		limit := c.Limit
+		if limit <= 0 {
+			limit = 20
+		}
+		ds, err := deliveries.ListDeliveries(ctx, &ListWebhookDeliveriesInput{Event: c.Event, Limit: int64(limit)})
+		if err != nil {
+			sm.Fail(err)
+			return
+		}
+		if c.enabled() {
+			c.post(sm, "webhooks", ds)
+			return
+		}
+
+		text := fmt.Sprintf("%d delivery(s) found", len(ds))
+		fields := make([]slack.AttachmentField, 0, len(ds))
+		for _, d := range ds {
+			fields = append(fields, slack.AttachmentField{
+				Title: d.ID,
+				Value: fmt.Sprintf("event=%s action=%s received_at=%s", d.Event, d.action(), d.ReceivedAt.In(TimeZone).Format(time.RFC3339)),
+			})
+		}
+		sm.PostAttachment(slack.Attachment{
+			Fallback: text,
+			Color:    slackColorGreen,
+			Pretext:  text,
+			Fields:   fields,
+		})
+	}
+}
+
+func NewWebhookReplayCommand(replayer WebhookReplayer, admins []string) *cli.Command {
+	webhookReplayCmd := &webhookReplayCommand{}
+	cmd := &cli.Command{
+		Name:      "replay",
+		ShortDesc: "replay archived webhook deliveries",
+		LongDesc: "replay archived webhook deliveries\n" +
+			"Usage: @gobot webhook replay <DELIVERY_ID>...\n\n" +
+			"# deliveryは重複チェックされずに再処理される。adminのみ実行可能\n" +
+			"@gobot webhook replay 72d3162e-cc78-11e3-81ab-4c9367dc0958",
+		Run: webhookReplayCmd.runFunc(replayer, admins),
+	}
+	if err := cmd.Options().
+		Add(&cli.BoolOpt{Var: &webhookReplayCmd.printHelp, Long: "help", Description: "print help"}).
+		Err; err != nil {
+		panic(err)
+	}
+	return cmd
+}
+
+type webhookReplayCommand struct {
+	baseCommand
+}
+
+func (c *webhookReplayCommand) runFunc(replayer WebhookReplayer, admins []string) commandFunc {
+	return func(ctx context.Context, cmd *cli.Command, args []string) {
+		if c.printHelp || len(args) == 0 {
+			cli.HelpFunc(cmd.Stdout, cmd)
+			return
+		}
+		sm := getSlackMessage(ctx)
+		if !requireAdmin(admins, sm) {
+			return
+		}
+
+		for _, id := range args {
+			if err := replayer.Replay(ctx, id); err != nil {
+				sm.Fail(err)
+				return
+			}
+		}
+		text := fmt.Sprintf("%d delivery(s) replayed", len(args))
+		sm.PostAttachment(slack.Attachment{
+			Fallback: text,
+			Color:    slackColorGreen,
+			Pretext:  slackEmojiOKHand + " " + text,
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package app_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/ymgyt/gobot/app"
+)
+
+func TestNewWebhookDelivery(t *testing.T) {
+	r, err := http.NewRequest(http.MethodPost, "/github/webhook", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("X-GitHub-Event", "pull_request")
+	r.Header.Set("X-GitHub-Delivery", "72d3162e-cc78-11e3-81ab-4c9367dc0958")
+	r.Header.Set("X-Hub-Signature", "sha1=7d38cdd689735b008b3c702edd92eea23791c5f6")
+	r.Header.Set("Content-Type", "application/json")
+
+	now := time.Date(2019, 5, 1, 10, 0, 0, 0, time.UTC)
+	d := app.NewWebhookDelivery(r, []byte(`{"action":"opened"}`), now, time.Hour)
+
+	if d.ID != "72d3162e-cc78-11e3-81ab-4c9367dc0958" || d.Event != "pull_request" {
+		t.Fatalf("unexpected delivery id=%s event=%s", d.ID, d.Event)
+	}
+	if got := d.Headers["X-Hub-Signature"]; got != "[REDACTED]" {
+		t.Errorf("signature should be redacted, got %s", got)
+	}
+	if !d.ExpireAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("expire_at want %s, got %s", now.Add(time.Hour), d.ExpireAt)
+	}
+
+	want := http.Header{
+		"X-Github-Event":    []string{"pull_request"},
+		"X-Github-Delivery": []string{"72d3162e-cc78-11e3-81ab-4c9367dc0958"},
+		"Content-Type":      []string{"application/json"},
+	}
+	if diff := cmp.Diff(want, d.Header()); diff != "" {
+		t.Errorf("(-want +got)\n%s", diff)
+	}
+}
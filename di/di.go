@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -26,6 +27,9 @@ import (
 
 const (
 	cleanupTimeoutSeconds = 3
+	userMigrationTimeout  = time.Minute
+	// github webhook queue is drained before other resources are closed.
+	webhookDrainTimeout = 20 * time.Second
 )
 
 // Config -
@@ -33,22 +37,53 @@ type Config struct {
 	SlackBotUserOAuthAccessToken string `envvar:"GOBOT_SLACK_BOT_USER_OAUTH_ACCESS_TOKEN,required"`
 	LoggingLevel                 string `envvar:"GOBOT_LOGGING_LEVEL,default=info"`
 	EnableSlackLog               string `envvar:"GOBOT_ENABLE_SLACK_LOG,default=false"`
+	SlackRenderer                string `envvar:"GOBOT_SLACK_RENDERER,default=blocks"` // blocks or attachments
 	Port                         string `envvar:"GOBOT_PORT,default=443"`
 	GCPProjectID                 string `envvar:"GOBOT_GCP_PROJECT_ID,required"`
 	GCPServiceAccountCredential  string `envvar:"GOBOT_GCP_SERVICE_ACCOUNT_CREDENTIAL,required"`
-	GithubWebhookSecret          string `envvar:"GOBOT_GITHUB_WEBHOOK_SECRET,required"`
+	GithubWebhookSecret          string `envvar:"GOBOT_GITHUB_WEBHOOK_SECRET,required"` // comma separated while rotating
 	GithubPRNotificationChannel  string `envvar:"GOBOT_GITHUB_PR_NOTIFICATION_CHANNEL,required"`
+	GithubWebhookWorkers         string `envvar:"GOBOT_GITHUB_WEBHOOK_WORKERS,default=4"`
+	GithubWebhookQueueSize       string `envvar:"GOBOT_GITHUB_WEBHOOK_QUEUE_SIZE,default=100"`
+	// raw webhook deliveries are kept for retention. "0" disables archiving.
+	WebhookArchiveRetention string `envvar:"GOBOT_WEBHOOK_ARCHIVE_RETENTION,default=336h"`
+	// bearer token for admin endpoints. empty token disables them.
+	AdminToken string `envvar:"GOBOT_ADMIN_TOKEN"`
+	// comma separated slack user ids allowed to run admin commands. empty disables them.
+	SlackAdmins string `envvar:"GOBOT_SLACK_ADMINS"`
+	// failures of the same check suite within window are notified at once.
+	CIBatchWindow string `envvar:"GOBOT_CI_BATCH_WINDOW,default=1m"`
+	// security alerts are posted to this channel instead of pr notification channel.
+	SecurityChannel string `envvar:"GOBOT_SECURITY_CHANNEL"`
+
+	// outgoing slack messages are dead-lettered after max attempts.
+	OutboxMaxAttempts string `envvar:"GOBOT_OUTBOX_MAX_ATTEMPTS,default=8"`
+
+	// reconciliation of users and slack workspace. "0" disables scheduled reconciliation.
+	ReconcileInterval string `envvar:"GOBOT_RECONCILE_INTERVAL,default=24h"`
+	// channel to post reconciliation report. default is GOBOT_GITHUB_PR_NOTIFICATION_CHANNEL
+	ReconcileChannel string `envvar:"GOBOT_RECONCILE_CHANNEL"`
+
+	// pending reviews are checked every interval. "0" disables review reminders.
+	ReviewReminderInterval string `envvar:"GOBOT_REVIEW_REMINDER_INTERVAL,default=15m"`
 
 	// mongodb://localhost:27017
 	MongoDSN      string `envvar:"GOBOT_MONGO_DSN,required"`
 	MongoDatabase string `envvar:"GOBOT_MONGO_DATABASE,required"`
+
+	// base64 encoded 32 bytes key. when set, personal data of users is encrypted at rest.
+	UserEncryptionKey string `envvar:"GOBOT_USER_ENCRYPTION_KEY"`
 }
 
 // Services -
 type Service struct {
-	Slack  *app.Slack
-	Server *server.Server
-	Config *Config
+	Slack          *app.Slack
+	Server         *server.Server
+	Reconciler     *app.Reconciler
+	OutboxSender   *app.OutboxSender
+	ReviewReminder *app.ReviewReminder
+	Handlers       *HandlerGroup
+	Config         *Config
 
 	mongo *store.Mongo
 }
@@ -57,9 +92,14 @@ func (s *Service) Run(ctx context.Context) {
 
 	log.Info("start service", zap.String("port", s.Config.Port))
 
+	s.Handlers.Github.Start()
+
 	errCh := make(chan error)
 	go func() { errCh <- s.Slack.Run(ctx) }()
 	go func() { errCh <- s.Server.Run() }()
+	go func() { errCh <- s.Reconciler.Run(ctx) }()
+	go func() { errCh <- s.OutboxSender.Run(ctx) }()
+	go func() { errCh <- s.ReviewReminder.Run(ctx) }()
 
 	var err error
 	select {
@@ -78,10 +118,18 @@ func (s *Service) Run(ctx context.Context) {
 
 type HandlerGroup struct {
 	Github *handlers.Github
+	Admin  *handlers.Admin
 }
 
-func ProvideService(cfg *Config, slk *app.Slack, serv *server.Server, mongo *store.Mongo) (*Service, func()) {
+func ProvideService(cfg *Config, slk *app.Slack, serv *server.Server, r *app.Reconciler, sender *app.OutboxSender, reminder *app.ReviewReminder, hg *HandlerGroup, mongo *store.Mongo) (*Service, func()) {
 	cleanup := func() {
+		drainCtx, cancelDrain := context.WithTimeout(context.Background(), webhookDrainTimeout)
+		defer cancelDrain()
+		log.Info("drain github webhook queue")
+		if err := hg.Github.Shutdown(drainCtx); err != nil {
+			log.Error("drain github webhook queue", zap.Error(err))
+		}
+
 		ctx, cancel := context.WithTimeout(context.Background(), time.Second*cleanupTimeoutSeconds)
 		defer cancel()
 		log.Info("close slack")
@@ -95,32 +143,137 @@ func ProvideService(cfg *Config, slk *app.Slack, serv *server.Server, mongo *sto
 	}
 
 	return &Service{
-		Slack:  slk,
-		Server: serv,
-		Config: cfg,
-		mongo:  mongo,
+		Slack:          slk,
+		Server:         serv,
+		Reconciler:     r,
+		OutboxSender:   sender,
+		ReviewReminder: reminder,
+		Handlers:       hg,
+		Config:         cfg,
+		mongo:          mongo,
 	}, cleanup
 }
 
-func ProvideSlack(cfg *Config, handler app.SlackMessageHandler, us app.UserStore) *app.Slack {
-	client := slack.New(
+func ProvideSlack(cfg *Config, client *slack.Client, handler app.SlackMessageHandler) *app.Slack {
+	return &app.Slack{
+		SlackOptions: &app.SlackOptions{
+			BotToken: cfg.SlackBotUserOAuthAccessToken,
+			Renderer: slackRenderer(cfg),
+		},
+		Client:         client,
+		MessageHandler: handler,
+	}
+}
+
+func ProvideNotifier(cfg *Config, client *slack.Client, outbox app.OutboxStore, resolver *app.AccountResolver, dedup app.Deduplicator, subs app.SubscriptionStore, rules app.RuleStore, templates app.TemplateStore, prs app.PullRequestStore, settings app.RepositorySettingStore, teams app.TeamStore) *app.Notifier {
+	ciBatchWindow, err := time.ParseDuration(cfg.CIBatchWindow)
+	if err != nil || ciBatchWindow <= 0 {
+		log.Fatal("invalid GOBOT_CI_BATCH_WINDOW", zap.String("value", cfg.CIBatchWindow))
+	}
+	channel, err := app.ResolveSlackChannelID(client, cfg.GithubPRNotificationChannel)
+	if err != nil {
+		log.Fatal("failed to resolve GOBOT_GITHUB_PR_NOTIFICATION_CHANNEL", zap.String("value", cfg.GithubPRNotificationChannel), zap.Error(err))
+	}
+	securityChannel := cfg.SecurityChannel
+	if securityChannel != "" {
+		if securityChannel, err = app.ResolveSlackChannelID(client, securityChannel); err != nil {
+			log.Fatal("failed to resolve GOBOT_SECURITY_CHANNEL", zap.String("value", cfg.SecurityChannel), zap.Error(err))
+		}
+	}
+	return &app.Notifier{
+		Outbox:             outbox,
+		AccountResolver:    resolver,
+		DuplicationChecker: dedup,
+		Subscriptions:      subs,
+		Rules:              rules,
+		Templates:          templates,
+		Renderer:           slackRenderer(cfg),
+		PullRequests:       prs,
+		RepositorySettings: settings,
+		Teams:              teams,
+		Channel:            channel,
+		CIBatchWindow:      ciBatchWindow,
+		SecurityChannel:    securityChannel,
+		Now:                app.Now,
+	}
+}
+
+func ProvideOutbox(mongo *store.Mongo) *store.Outbox {
+	outbox := &store.Outbox{Mongo: mongo, Now: app.Now}
+	ctx, cancel := context.WithTimeout(context.Background(), store.DefaultTimeout)
+	defer cancel()
+	if err := outbox.EnsureIndexes(ctx); err != nil {
+		log.Fatal("failed to ensure outbox indexes", zap.Error(err))
+	}
+	return outbox
+}
+
+func ProvideOutboxSender(cfg *Config, client *slack.Client, outbox app.OutboxStore) *app.OutboxSender {
+	maxAttempts, err := strconv.Atoi(cfg.OutboxMaxAttempts)
+	if err != nil || maxAttempts < 1 {
+		log.Fatal("invalid GOBOT_OUTBOX_MAX_ATTEMPTS", zap.String("value", cfg.OutboxMaxAttempts))
+	}
+	return &app.OutboxSender{
+		Store:        outbox,
+		Client:       client,
+		Poster:       &app.BlockPoster{Token: cfg.SlackBotUserOAuthAccessToken},
+		MaxAttempts:  maxAttempts,
+		BaseBackoff:  5 * time.Second,
+		MaxBackoff:   30 * time.Minute,
+		PollInterval: time.Second,
+		Now:          app.Now,
+	}
+}
+
+func ProvideReviewReminder(cfg *Config, prs app.PullRequestStore, notifier *app.Notifier) *app.ReviewReminder {
+	interval, err := time.ParseDuration(cfg.ReviewReminderInterval)
+	if err != nil {
+		log.Fatal("invalid GOBOT_REVIEW_REMINDER_INTERVAL", zap.String("value", cfg.ReviewReminderInterval))
+	}
+	return &app.ReviewReminder{
+		PullRequests: prs,
+		Notifier:     notifier,
+		Interval:     interval,
+		Now:          app.Now,
+	}
+}
+
+func ProvideSlackClient(cfg *Config) *slack.Client {
+	return slack.New(
 		cfg.SlackBotUserOAuthAccessToken,
 		slack.OptionDebug(strings.ToLower(cfg.EnableSlackLog) == "true"),
 		slack.OptionLog(&slackLogger{log.GetLogger()}))
+}
 
-	return &app.Slack{
-		SlackOptions: &app.SlackOptions{
-			GithubPRNotificationChannel: cfg.GithubPRNotificationChannel,
-		},
-		Client: client,
-		AccountResolver: &app.AccountResolver{
-			SlackClient: client,
-			UserStore:   us,
-			Mu:          &sync.Mutex{},
-		},
-		DuplicationChecker: &app.DuplicationChecker{},
-		MessageHandler:     handler,
+func ProvideAccountResolver(client *slack.Client, us app.UserStore) *app.AccountResolver {
+	return &app.AccountResolver{
+		SlackClient: client,
+		UserStore:   us,
+		Mu:          &sync.Mutex{},
+	}
+}
+
+func ProvideReconciler(cfg *Config, client *slack.Client, resolver *app.AccountResolver, us app.UserStore, leases app.LeaseStore) *app.Reconciler {
+	interval, err := time.ParseDuration(cfg.ReconcileInterval)
+	if err != nil || interval < 0 {
+		log.Fatal("invalid GOBOT_RECONCILE_INTERVAL", zap.String("value", cfg.ReconcileInterval))
+	}
+	channel := cfg.ReconcileChannel
+	if channel == "" {
+		channel = cfg.GithubPRNotificationChannel
 	}
+	reconciler := &app.Reconciler{
+		UserStore:       us,
+		AccountResolver: resolver,
+		Leases:          leases,
+		Client:          client,
+		Channel:         channel,
+		Interval:        interval,
+	}
+	if slackRenderer(cfg) == app.RenderBlocks {
+		reconciler.Poster = &app.BlockPoster{Token: cfg.SlackBotUserOAuthAccessToken}
+	}
+	return reconciler
 }
 
 func ProvideServer(cfg *Config, hg *HandlerGroup, ds *datastore.Client) *server.Server {
@@ -156,14 +309,43 @@ func ProvideMessageHandler(builder *app.CommandBuilder) *app.MessageHandler {
 	return &app.MessageHandler{CommandBuilder: builder}
 }
 
-func ProvideCommandBuilder(us app.UserStore) *app.CommandBuilder {
+func ProvideCommandBuilder(cfg *Config, us app.UserStore, r *app.Reconciler, outbox app.OutboxStore, subs app.SubscriptionStore, rules app.RuleStore, templates app.TemplateStore, settings app.RepositorySettingStore, teams app.TeamStore, prs app.PullRequestStore, resolver *app.AccountResolver, deliveries app.WebhookDeliveryStore, replayer app.WebhookReplayer) *app.CommandBuilder {
 	return &app.CommandBuilder{
-		UserStore: us,
+		UserStore:          us,
+		Reconciler:         r,
+		Outbox:             outbox,
+		Subscriptions:      subs,
+		Rules:              rules,
+		Templates:          templates,
+		RepositorySettings: settings,
+		Teams:              teams,
+		PullRequests:       prs,
+		AccountResolver:    resolver,
+		WebhookDeliveries:  deliveries,
+		WebhookReplayer:    replayer,
+		Admins:             app.ParseLogins(cfg.SlackAdmins),
 	}
 }
 
-func ProvideUserStore(mongo *store.Mongo) *store.Users {
-	return &store.Users{Mongo: mongo, Now: app.Now}
+func ProvideUserStore(cfg *Config, mongo *store.Mongo) *store.Users {
+	users := &store.Users{Mongo: mongo, Now: app.Now}
+	if cfg.UserEncryptionKey == "" {
+		return users
+	}
+
+	cipher, err := store.NewCipher(cfg.UserEncryptionKey)
+	if err != nil {
+		log.Fatal("invalid user encryption key", zap.Error(err))
+	}
+	users.Cipher = cipher
+
+	// encrypt documents stored before encryption was enabled.
+	ctx, cancel := context.WithTimeout(context.Background(), userMigrationTimeout)
+	defer cancel()
+	if _, err := users.EncryptExisting(ctx); err != nil {
+		log.Fatal("failed to encrypt existing users", zap.Error(err))
+	}
+	return users
 }
 
 func ProvideMongo(cfg *Config) *store.Mongo {
@@ -174,17 +356,136 @@ func ProvideMongo(cfg *Config) *store.Mongo {
 	return m
 }
 
+func ProvideDeduplicator(mongo *store.Mongo) *store.Deduplications {
+	dedup := &store.Deduplications{Mongo: mongo, Now: app.Now}
+	ctx, cancel := context.WithTimeout(context.Background(), store.DefaultTimeout)
+	defer cancel()
+	if err := dedup.EnsureIndexes(ctx); err != nil {
+		log.Fatal("failed to ensure deduplication indexes", zap.Error(err))
+	}
+	return dedup
+}
+
+func ProvideLeases(mongo *store.Mongo) *store.Leases {
+	leases := &store.Leases{Mongo: mongo, Now: app.Now}
+	ctx, cancel := context.WithTimeout(context.Background(), store.DefaultTimeout)
+	defer cancel()
+	if err := leases.EnsureIndexes(ctx); err != nil {
+		log.Fatal("failed to ensure lease indexes", zap.Error(err))
+	}
+	return leases
+}
+
+func ProvideSubscriptions(mongo *store.Mongo) *store.Subscriptions {
+	subs := &store.Subscriptions{Mongo: mongo, Now: app.Now}
+	ctx, cancel := context.WithTimeout(context.Background(), store.DefaultTimeout)
+	defer cancel()
+	if err := subs.EnsureIndexes(ctx); err != nil {
+		log.Fatal("failed to ensure subscription indexes", zap.Error(err))
+	}
+	return subs
+}
+
+func ProvideRules(mongo *store.Mongo) *store.Rules {
+	return &store.Rules{Mongo: mongo, Now: app.Now}
+}
+
+func ProvideTemplates(mongo *store.Mongo) *store.Templates {
+	templates := &store.Templates{Mongo: mongo, Now: app.Now}
+	ctx, cancel := context.WithTimeout(context.Background(), store.DefaultTimeout)
+	defer cancel()
+	if err := templates.EnsureIndexes(ctx); err != nil {
+		log.Fatal("failed to ensure template indexes", zap.Error(err))
+	}
+	return templates
+}
+
+func ProvideWebhookDeliveries(mongo *store.Mongo) *store.WebhookDeliveries {
+	deliveries := &store.WebhookDeliveries{Mongo: mongo, Now: app.Now}
+	ctx, cancel := context.WithTimeout(context.Background(), store.DefaultTimeout)
+	defer cancel()
+	if err := deliveries.EnsureIndexes(ctx); err != nil {
+		log.Fatal("failed to ensure webhook delivery indexes", zap.Error(err))
+	}
+	return deliveries
+}
+
+func ProvideRepositorySettings(mongo *store.Mongo) *store.RepositorySettings {
+	return &store.RepositorySettings{Mongo: mongo, Now: app.Now}
+}
+
+func ProvideTeams(mongo *store.Mongo) *store.Teams {
+	return &store.Teams{Mongo: mongo, Now: app.Now}
+}
+
+func ProvidePullRequests(mongo *store.Mongo) *store.PullRequests {
+	prs := &store.PullRequests{Mongo: mongo, Now: app.Now}
+	ctx, cancel := context.WithTimeout(context.Background(), store.DefaultTimeout)
+	defer cancel()
+	if err := prs.EnsureIndexes(ctx); err != nil {
+		log.Fatal("failed to ensure pull request indexes", zap.Error(err))
+	}
+	return prs
+}
+
 func ProvideNowFunc() func() time.Time {
 	return app.Now
 }
 
-func ProvideHandlerGroup(cfg *Config, slk *app.Slack) *HandlerGroup {
-	return &HandlerGroup{
-		Github: &handlers.Github{
-			Webhook: githubWebhook(cfg),
-			Slack:   slk,
+func ProvideGithubHandler(cfg *Config, notifier *app.Notifier, dedup app.Deduplicator, deliveries app.WebhookDeliveryStore, prs app.PullRequestStore) *handlers.Github {
+	workers, err := strconv.Atoi(cfg.GithubWebhookWorkers)
+	if err != nil || workers < 1 {
+		log.Fatal("invalid GOBOT_GITHUB_WEBHOOK_WORKERS", zap.String("value", cfg.GithubWebhookWorkers))
+	}
+	queueSize, err := strconv.Atoi(cfg.GithubWebhookQueueSize)
+	if err != nil || queueSize < 0 {
+		log.Fatal("invalid GOBOT_GITHUB_WEBHOOK_QUEUE_SIZE", zap.String("value", cfg.GithubWebhookQueueSize))
+	}
+	retention, err := time.ParseDuration(cfg.WebhookArchiveRetention)
+	if err != nil || retention < 0 {
+		log.Fatal("invalid GOBOT_WEBHOOK_ARCHIVE_RETENTION", zap.String("value", cfg.WebhookArchiveRetention))
+	}
+
+	verifier, err := handlers.NewSignatureVerifier(cfg.GithubWebhookSecret)
+	if err != nil {
+		log.Fatal("invalid GOBOT_GITHUB_WEBHOOK_SECRET", zap.Error(err))
+	}
+
+	gh := &handlers.Github{
+		Webhook:          githubWebhook(),
+		Verifier:         verifier,
+		Notifier:         notifier,
+		Deduplicator:     dedup,
+		PullRequests:     prs,
+		Deliveries:       deliveries,
+		ArchiveRetention: retention,
+		Now:              app.Now,
+		Dispatcher: &handlers.Dispatcher{
+			Workers:     workers,
+			QueueSize:   queueSize,
+			MaxAttempts: 3,
+			Backoff:     time.Second,
 		},
 	}
+	if retention == 0 {
+		log.Info("github webhook archive disabled")
+		gh.Deliveries = nil
+	}
+	return gh
+}
+
+func ProvideAdminHandler(cfg *Config, replayer app.WebhookReplayer) *handlers.Admin {
+	return &handlers.Admin{
+		Token:    cfg.AdminToken,
+		Replayer: replayer,
+	}
+}
+
+func ProvideHandlerGroup(gh *handlers.Github, admin *handlers.Admin) *HandlerGroup {
+	return &HandlerGroup{
+		Github: gh,
+		Admin:  admin,
+	}
 }
 
 func ProvideDatastoreClient(ctx context.Context, cfg *Config) *datastore.Client {
@@ -198,11 +499,22 @@ func ProvideDatastoreClient(ctx context.Context, cfg *Config) *datastore.Client
 func buildRouter(r *httprouter.Router, hg *HandlerGroup) http.Handler {
 	r.POST("/github/webhook", hg.Github.HandleWebhook)
 	r.GET("/liveness", hg.Github.Liveness)
+	r.POST("/admin/github/webhook/replay/:delivery_id", hg.Admin.ReplayWebhook)
+	r.GET("/debug/vars", hg.Admin.Vars)
 	return r
 }
 
-func githubWebhook(cfg *Config) *github.Webhook {
-	hook, err := github.New(github.Options.Secret(cfg.GithubWebhookSecret))
+func slackRenderer(cfg *Config) app.SlackRenderer {
+	renderer, err := app.ParseSlackRenderer(cfg.SlackRenderer)
+	if err != nil {
+		log.Fatal("invalid GOBOT_SLACK_RENDERER", zap.Error(err))
+	}
+	return renderer
+}
+
+// githubWebhook returns webhook parser. signature is verified by handlers.SignatureVerifier.
+func githubWebhook() *github.Webhook {
+	hook, err := github.New()
 	if err != nil {
 		panic(err)
 	}
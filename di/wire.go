@@ -7,6 +7,7 @@ import (
 
 	"github.com/google/wire"
 	"github.com/ymgyt/gobot/app"
+	"github.com/ymgyt/gobot/handlers"
 	"github.com/ymgyt/gobot/store"
 )
 
@@ -14,14 +15,43 @@ func InitializeService(ctx context.Context) (*Service, func()) {
 	wire.Build(
 		wire.Bind(new(app.SlackMessageHandler), new(app.MessageHandler)),
 		wire.Bind(new(app.UserStore), new(store.Users)),
+		wire.Bind(new(app.Deduplicator), new(store.Deduplications)),
+		wire.Bind(new(app.OutboxStore), new(store.Outbox)),
+		wire.Bind(new(app.SubscriptionStore), new(store.Subscriptions)),
+		wire.Bind(new(app.RuleStore), new(store.Rules)),
+		wire.Bind(new(app.TemplateStore), new(store.Templates)),
+		wire.Bind(new(app.WebhookDeliveryStore), new(store.WebhookDeliveries)),
+		wire.Bind(new(app.PullRequestStore), new(store.PullRequests)),
+		wire.Bind(new(app.RepositorySettingStore), new(store.RepositorySettings)),
+		wire.Bind(new(app.TeamStore), new(store.Teams)),
+		wire.Bind(new(app.LeaseStore), new(store.Leases)),
+		wire.Bind(new(app.WebhookReplayer), new(handlers.Github)),
 		ProvideService,
 		ProvideSlack,
+		ProvideSlackClient,
+		ProvideAccountResolver,
+		ProvideReconciler,
+		ProvideNotifier,
+		ProvideOutbox,
+		ProvideOutboxSender,
+		ProvideReviewReminder,
 		ProvideMessageHandler,
 		ProvideCommandBuilder,
 		ProvideUserStore,
+		ProvideDeduplicator,
+		ProvideLeases,
 		ProvideMongo,
 		ProvideServer,
 		ProvideConfigSideEffect,
+		ProvideSubscriptions,
+		ProvideRules,
+		ProvideTemplates,
+		ProvideWebhookDeliveries,
+		ProvidePullRequests,
+		ProvideRepositorySettings,
+		ProvideTeams,
+		ProvideGithubHandler,
+		ProvideAdminHandler,
 		ProvideHandlerGroup,
 		ProvideDatastoreClient,
 	)
@@ -14,14 +14,32 @@ import (
 func InitializeService(ctx context.Context) (*Service, func()) {
 	config := ProvideConfigSideEffect()
 	mongo := ProvideMongo(config)
-	users := ProvideUserStore(mongo)
-	commandBuilder := ProvideCommandBuilder(users)
+	users := ProvideUserStore(config, mongo)
+	client := ProvideSlackClient(config)
+	accountResolver := ProvideAccountResolver(client, users)
+	leases := ProvideLeases(mongo)
+	reconciler := ProvideReconciler(config, client, accountResolver, users, leases)
+	outbox := ProvideOutbox(mongo)
+	subscriptions := ProvideSubscriptions(mongo)
+	rules := ProvideRules(mongo)
+	templates := ProvideTemplates(mongo)
+	repositorySettings := ProvideRepositorySettings(mongo)
+	teams := ProvideTeams(mongo)
+	webhookDeliveries := ProvideWebhookDeliveries(mongo)
+	deduplications := ProvideDeduplicator(mongo)
+	pullRequests := ProvidePullRequests(mongo)
+	notifier := ProvideNotifier(config, client, outbox, accountResolver, deduplications, subscriptions, rules, templates, pullRequests, repositorySettings, teams)
+	github := ProvideGithubHandler(config, notifier, deduplications, webhookDeliveries, pullRequests)
+	commandBuilder := ProvideCommandBuilder(config, users, reconciler, outbox, subscriptions, rules, templates, repositorySettings, teams, pullRequests, accountResolver, webhookDeliveries, github)
 	messageHandler := ProvideMessageHandler(commandBuilder)
-	slack := ProvideSlack(config, messageHandler, users)
-	handlerGroup := ProvideHandlerGroup(config, slack)
-	client := ProvideDatastoreClient(ctx, config)
-	server := ProvideServer(config, handlerGroup, client)
-	service, cleanup := ProvideService(config, slack, server, mongo)
+	slack := ProvideSlack(config, client, messageHandler)
+	outboxSender := ProvideOutboxSender(config, client, outbox)
+	reviewReminder := ProvideReviewReminder(config, pullRequests, notifier)
+	admin := ProvideAdminHandler(config, github)
+	handlerGroup := ProvideHandlerGroup(github, admin)
+	datastoreClient := ProvideDatastoreClient(ctx, config)
+	server := ProvideServer(config, handlerGroup, datastoreClient)
+	service, cleanup := ProvideService(config, slack, server, reconciler, outboxSender, reviewReminder, handlerGroup, mongo)
 	return service, func() {
 		cleanup()
 	}
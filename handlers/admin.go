@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"expvar"
+	"net/http"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
+
+	"github.com/ymgyt/gobot/app"
+	"github.com/ymgyt/gobot/log"
+)
+
+// Admin serves operational endpoints protected by bearer token.
+type Admin struct {
+	// Token is compared with Authorization: Bearer <token>. empty token disables admin endpoints.
+	Token    string
+	Replayer app.WebhookReplayer
+}
+
+// ReplayWebhook replays archived github delivery.
+func (a *Admin) ReplayWebhook(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if !a.authorized(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	id := ps.ByName("delivery_id")
+	if err := a.Replayer.Replay(r.Context(), id); err != nil {
+		log.Error("admin/replay webhook", zap.String("delivery", id), zap.Error(err))
+		if errors.IsNotFound(err) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Vars exposes expvar metrics such as github webhook signature failures.
+func (a *Admin) Vars(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if !a.authorized(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	expvar.Handler().ServeHTTP(w, r)
+}
+
+func (a *Admin) authorized(r *http.Request) bool {
+	if a.Token == "" {
+		return false
+	}
+	const prefix = "Bearer "
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, prefix) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, prefix)), []byte(a.Token)) == 1
+}
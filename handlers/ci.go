@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"github.com/ymgyt/gobot/app"
+	"github.com/ymgyt/gobot/log"
+)
+
+type githubRepository struct {
+	Name     string `json:"name"`
+	FullName string `json:"full_name"`
+}
+
+// see https://developer.github.com/v3/activity/events/types/#checkrunevent
+type checkRunFields struct {
+	Action   string `json:"action"`
+	CheckRun struct {
+		Name       string `json:"name"`
+		HeadSHA    string `json:"head_sha"`
+		Status     string `json:"status"`
+		Conclusion string `json:"conclusion"`
+		HTMLURL    string `json:"html_url"`
+		DetailsURL string `json:"details_url"`
+		CheckSuite struct {
+			ID int64 `json:"id"`
+		} `json:"check_suite"`
+	} `json:"check_run"`
+	Repository githubRepository `json:"repository"`
+}
+
+// see https://developer.github.com/v3/activity/events/types/#checksuiteevent
+type checkSuiteFields struct {
+	Action     string `json:"action"`
+	CheckSuite struct {
+		ID         int64  `json:"id"`
+		HeadSHA    string `json:"head_sha"`
+		Conclusion string `json:"conclusion"`
+		App        struct {
+			Name string `json:"name"`
+		} `json:"app"`
+	} `json:"check_suite"`
+	Repository githubRepository `json:"repository"`
+}
+
+// see https://developer.github.com/v3/activity/events/types/#statusevent
+type statusFields struct {
+	SHA         string           `json:"sha"`
+	State       string           `json:"state"`
+	Context     string           `json:"context"`
+	TargetURL   string           `json:"target_url"`
+	Description string           `json:"description"`
+	Repository  githubRepository `json:"repository"`
+}
+
+// ci events are decoded from raw body because only a few fields are used.
+func (g *Github) handleCheckRun(event *app.GithubEvent, body []byte) {
+	var fields checkRunFields
+	if err := json.Unmarshal(body, &fields); err != nil {
+		log.Error("github/decode check_run", zap.Error(err))
+		return
+	}
+	run := fields.CheckRun
+	url := run.HTMLURL
+	if url == "" {
+		url = run.DetailsURL
+	}
+	g.trackCheck(fields.Repository.FullName, &app.PullRequestCheck{
+		Name:  run.Name,
+		SHA:   run.HeadSHA,
+		State: app.CIState(run.Status, run.Conclusion),
+		URL:   url,
+	})
+	if fields.Action != "completed" || !app.IsCIFailure(run.Conclusion) {
+		return
+	}
+	log.Info("github/handle event", zap.String("event", "check_run"), zap.String("action", fields.Action), zap.String("conclusion", run.Conclusion))
+
+	g.notifyCIFailure(&app.CIFailure{
+		Repository: fields.Repository.FullName,
+		RepoName:   fields.Repository.Name,
+		SHA:        run.HeadSHA,
+		Suite:      strconv.FormatInt(run.CheckSuite.ID, 10),
+		Name:       run.Name,
+		Conclusion: run.Conclusion,
+		URL:        url,
+		Event:      event,
+	})
+}
+
+func (g *Github) handleCheckSuite(event *app.GithubEvent, body []byte) {
+	var fields checkSuiteFields
+	if err := json.Unmarshal(body, &fields); err != nil {
+		log.Error("github/decode check_suite", zap.Error(err))
+		return
+	}
+	// check suites are not tracked. state of pull request is aggregated from check runs.
+	suite := fields.CheckSuite
+	if fields.Action != "completed" || !app.IsCIFailure(suite.Conclusion) {
+		return
+	}
+	log.Info("github/handle event", zap.String("event", "check_suite"), zap.String("action", fields.Action), zap.String("conclusion", suite.Conclusion))
+
+	g.notifyCIFailure(&app.CIFailure{
+		Repository: fields.Repository.FullName,
+		RepoName:   fields.Repository.Name,
+		SHA:        suite.HeadSHA,
+		Suite:      strconv.FormatInt(suite.ID, 10),
+		Name:       suite.App.Name,
+		Conclusion: suite.Conclusion,
+		Summary:    true,
+		Event:      event,
+	})
+}
+
+func (g *Github) handleStatus(event *app.GithubEvent, body []byte) {
+	var fields statusFields
+	if err := json.Unmarshal(body, &fields); err != nil {
+		log.Error("github/decode status", zap.Error(err))
+		return
+	}
+	g.trackCheck(fields.Repository.FullName, &app.PullRequestCheck{
+		Name:  fields.Context,
+		SHA:   fields.SHA,
+		State: app.CIState("", fields.State),
+		URL:   fields.TargetURL,
+	})
+	if !app.IsCIFailure(fields.State) {
+		return
+	}
+	log.Info("github/handle event", zap.String("event", "status"), zap.String("state", fields.State))
+
+	g.notifyCIFailure(&app.CIFailure{
+		Repository: fields.Repository.FullName,
+		RepoName:   fields.Repository.Name,
+		SHA:        fields.SHA,
+		Suite:      app.CIStatusSuite,
+		Name:       fields.Context,
+		Conclusion: fields.State,
+		URL:        fields.TargetURL,
+		Event:      event,
+	})
+}
+
+func (g *Github) notifyCIFailure(f *app.CIFailure) {
+	if err := g.Notifier.NotifyCIFailure(f); err != nil {
+		log.Error("github", zap.String("event", f.Event.Name), zap.String("sha", f.SHA), zap.Error(err))
+	}
+}
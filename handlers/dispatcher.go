@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+	"go.uber.org/zap"
+
+	"github.com/ymgyt/gobot/log"
+)
+
+// ErrQueueFull is returned when dispatcher can not accept more events.
+var ErrQueueFull = errors.New("webhook queue is full")
+
+// webhookEvent is a parsed webhook delivery waiting to be processed.
+type webhookEvent struct {
+	Event      string
+	DeliveryID string
+	Body       []byte
+	Payload    interface{}
+	// Replay is true when archived delivery is processed again.
+	Replay bool
+	// Attempt is 1 on first processing and incremented on each retry.
+	Attempt int
+}
+
+// Dispatcher processes webhook events asynchronously with bounded workers.
+// events whose handler returns error are retried with exponential backoff.
+type Dispatcher struct {
+	Workers   int
+	QueueSize int
+	// MaxAttempts is the number of tries of an event. zero means no retry.
+	MaxAttempts int
+	// Backoff is wait before the first retry. it doubles on each retry.
+	Backoff time.Duration
+	// GiveUp is called when event is not processed after MaxAttempts or its retry is abandoned.
+	GiveUp func(*webhookEvent)
+
+	handler   func(*webhookEvent) error
+	mu        sync.RWMutex
+	queue     chan *webhookEvent
+	closed    bool
+	abort     chan struct{}
+	wg        sync.WaitGroup
+	startOnce sync.Once
+}
+
+// Start launches workers which process events with handler.
+func (d *Dispatcher) Start(handler func(*webhookEvent) error) {
+	d.startOnce.Do(func() {
+		d.handler = handler
+		d.abort = make(chan struct{})
+		d.mu.Lock()
+		d.queue = make(chan *webhookEvent, d.QueueSize)
+		d.mu.Unlock()
+
+		for i := 0; i < d.Workers; i++ {
+			d.wg.Add(1)
+			go d.work()
+		}
+		log.Info("github/start webhook workers", zap.Int("workers", d.Workers), zap.Int("queue_size", d.QueueSize))
+	})
+}
+
+// Enqueue adds event without blocking.
+func (d *Dispatcher) Enqueue(e *webhookEvent) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed || d.queue == nil {
+		return errors.New("webhook dispatcher is not running")
+	}
+	select {
+	case d.queue <- e:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Shutdown stops accepting events and waits until queued events are processed or ctx is done.
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	d.mu.Lock()
+	if d.closed || d.queue == nil {
+		d.mu.Unlock()
+		return nil
+	}
+	d.closed = true
+	remaining := len(d.queue)
+	close(d.queue)
+	d.mu.Unlock()
+
+	log.Info("github/drain webhook queue", zap.Int("remaining", remaining))
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		// waiting retries are abandoned.
+		close(d.abort)
+		return errors.Annotatef(ctx.Err(), "%d event(s) may not be processed", len(d.queue))
+	}
+}
+
+func (d *Dispatcher) work() {
+	defer d.wg.Done()
+	for e := range d.queue {
+		d.dispatch(e)
+	}
+}
+
+func (d *Dispatcher) dispatch(e *webhookEvent) {
+	backoff := d.Backoff
+	for e.Attempt = 1; ; e.Attempt++ {
+		err := d.handle(e)
+		if err == nil {
+			return
+		}
+		if e.Attempt >= d.MaxAttempts {
+			log.Error("github/give up webhook", zap.String("event", e.Event), zap.String("delivery", e.DeliveryID), zap.Int("attempt", e.Attempt), zap.Error(err))
+			d.giveUp(e)
+			return
+		}
+		log.Warn("github/retry webhook", zap.String("event", e.Event), zap.String("delivery", e.DeliveryID), zap.Int("attempt", e.Attempt), zap.Duration("backoff", backoff), zap.Error(err))
+		select {
+		case <-time.After(backoff):
+		case <-d.abort:
+			log.Error("github/abandon webhook retry", zap.String("event", e.Event), zap.String("delivery", e.DeliveryID), zap.Error(err))
+			d.giveUp(e)
+			return
+		}
+		backoff *= 2
+	}
+}
+
+func (d *Dispatcher) giveUp(e *webhookEvent) {
+	if d.GiveUp != nil {
+		d.GiveUp(e)
+	}
+}
+
+// handle processes event once. panic is not retried.
+func (d *Dispatcher) handle(e *webhookEvent) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error("github/panic while processing webhook", zap.String("event", e.Event), zap.String("delivery", e.DeliveryID), zap.Any("panic", r))
+			err = nil
+		}
+	}()
+	return d.handler(e)
+}
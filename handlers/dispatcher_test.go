@@ -0,0 +1,185 @@
+package handlers_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ymgyt/gobot/handlers"
+)
+
+func TestDispatcher_Retry(t *testing.T) {
+	d := &handlers.Dispatcher{Workers: 1, QueueSize: 1, MaxAttempts: 3, Backoff: time.Millisecond}
+	var mu sync.Mutex
+	var attempts []int
+	d.Start(func(e *handlers.WebhookEvent) error {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts = append(attempts, e.Attempt)
+		if e.Attempt < 2 {
+			return errors.New("temporary")
+		}
+		return nil
+	})
+
+	if err := d.Enqueue(&handlers.WebhookEvent{Event: "pull_request"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts) != 2 || attempts[0] != 1 || attempts[1] != 2 {
+		t.Errorf("attempts %v", attempts)
+	}
+}
+
+func TestDispatcher_GiveUp(t *testing.T) {
+	var givenUp []*handlers.WebhookEvent
+	d := &handlers.Dispatcher{
+		Workers:     1,
+		QueueSize:   1,
+		MaxAttempts: 3,
+		Backoff:     time.Millisecond,
+		GiveUp:      func(e *handlers.WebhookEvent) { givenUp = append(givenUp, e) },
+	}
+	var n int
+	d.Start(func(e *handlers.WebhookEvent) error {
+		n++
+		return errors.New("permanent")
+	})
+
+	if err := d.Enqueue(&handlers.WebhookEvent{Event: "pull_request"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("want 3 attempts, got %d", n)
+	}
+	if len(givenUp) != 1 {
+		t.Errorf("want GiveUp called once, got %d", len(givenUp))
+	}
+}
+
+func TestDispatcher_PanicIsNotRetried(t *testing.T) {
+	d := &handlers.Dispatcher{Workers: 1, QueueSize: 2, MaxAttempts: 3, Backoff: time.Millisecond}
+	var n int
+	d.Start(func(e *handlers.WebhookEvent) error {
+		n++
+		if e.Event == "panic" {
+			panic("bug")
+		}
+		return nil
+	})
+
+	for _, event := range []string{"panic", "push"} {
+		if err := d.Enqueue(&handlers.WebhookEvent{Event: event}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// worker survives panic.
+	if n != 2 {
+		t.Errorf("want 2 calls, got %d", n)
+	}
+}
+
+func TestDispatcher_QueueFull(t *testing.T) {
+	d := &handlers.Dispatcher{Workers: 1, QueueSize: 1}
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	d.Start(func(e *handlers.WebhookEvent) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	})
+	defer func() {
+		close(release)
+		_ = d.Shutdown(context.Background())
+	}()
+
+	if err := d.Enqueue(&handlers.WebhookEvent{}); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	if err := d.Enqueue(&handlers.WebhookEvent{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Enqueue(&handlers.WebhookEvent{}); err != handlers.ErrQueueFull {
+		t.Errorf("want ErrQueueFull, got %v", err)
+	}
+}
+
+func TestDispatcher_ShutdownDrainsRunningAndQueuedEvents(t *testing.T) {
+	d := &handlers.Dispatcher{Workers: 1, QueueSize: 2}
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	var mu sync.Mutex
+	var processed []string
+	d.Start(func(e *handlers.WebhookEvent) error {
+		if e.Event == "running" {
+			started <- struct{}{}
+			<-release
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		processed = append(processed, e.Event)
+		return nil
+	})
+
+	if err := d.Enqueue(&handlers.WebhookEvent{Event: "running"}); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	if err := d.Enqueue(&handlers.WebhookEvent{Event: "queued"}); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() { done <- d.Shutdown(context.Background()) }()
+
+	// new events are rejected while draining.
+	time.Sleep(10 * time.Millisecond)
+	if err := d.Enqueue(&handlers.WebhookEvent{Event: "late"}); err == nil {
+		t.Error("event accepted after shutdown")
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("shutdown returned before running event finished: %v", err)
+	default:
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(processed) != 2 || processed[0] != "running" || processed[1] != "queued" {
+		t.Errorf("processed %v", processed)
+	}
+}
+
+func TestDispatcher_ShutdownTimeoutAbandonsRetry(t *testing.T) {
+	d := &handlers.Dispatcher{Workers: 1, QueueSize: 1, MaxAttempts: 2, Backoff: time.Hour}
+	failed := make(chan struct{}, 1)
+	d.Start(func(e *handlers.WebhookEvent) error {
+		failed <- struct{}{}
+		return errors.New("temporary")
+	})
+	if err := d.Enqueue(&handlers.WebhookEvent{}); err != nil {
+		t.Fatal(err)
+	}
+	<-failed
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := d.Shutdown(ctx); err == nil {
+		t.Error("want timeout error")
+	}
+}
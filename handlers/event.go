@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+
+	"github.com/ymgyt/gobot/app"
+)
+
+type githubUser struct {
+	Login string `json:"login"`
+}
+
+type githubLabel struct {
+	Name string `json:"name"`
+}
+
+// githubEventFields is common subset of webhook payloads used to build app.GithubEvent.
+type githubEventFields struct {
+	Action     string `json:"action"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	PullRequest *struct {
+		User   githubUser    `json:"user"`
+		Labels []githubLabel `json:"labels"`
+		Base   struct {
+			Ref string `json:"ref"`
+		} `json:"base"`
+	} `json:"pull_request"`
+	Issue *struct {
+		User   githubUser    `json:"user"`
+		Labels []githubLabel `json:"labels"`
+	} `json:"issue"`
+	Deployment *struct {
+		Environment string `json:"environment"`
+	} `json:"deployment"`
+}
+
+// normalizeEvent extracts fields used for routing from raw body.
+// body is decoded independently from typed payloads because they differ in each event.
+func normalizeEvent(e *webhookEvent) *app.GithubEvent {
+	event := &app.GithubEvent{Name: e.Event, Category: app.CategoryOf(e.Event)}
+
+	var fields githubEventFields
+	if err := json.Unmarshal(e.Body, &fields); err != nil {
+		return event
+	}
+	event.Action = fields.Action
+	event.Repository = fields.Repository.FullName
+
+	var labels []githubLabel
+	switch {
+	case fields.PullRequest != nil:
+		event.Author = fields.PullRequest.User.Login
+		event.Base = fields.PullRequest.Base.Ref
+		labels = fields.PullRequest.Labels
+	case fields.Issue != nil:
+		event.Author = fields.Issue.User.Login
+		labels = fields.Issue.Labels
+	}
+	for _, label := range labels {
+		event.Labels = append(event.Labels, label.Name)
+	}
+	if fields.Deployment != nil {
+		event.Environment = fields.Deployment.Environment
+	}
+	return event
+}
@@ -0,0 +1,9 @@
+package handlers
+
+// WebhookEvent exposes webhookEvent to tests.
+type WebhookEvent = webhookEvent
+
+// Process exposes process to tests.
+func (g *Github) Process(e *WebhookEvent) error {
+	return g.process(e)
+}
@@ -1,9 +1,17 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
 	"net/http"
+	"strings"
+	"time"
 
-	"github.com/davecgh/go-spew/spew"
+	"github.com/juju/errors"
 	"github.com/julienschmidt/httprouter"
 	"go.uber.org/zap"
 	"gopkg.in/go-playground/webhooks.v5/github"
@@ -12,57 +20,407 @@ import (
 	"github.com/ymgyt/gobot/log"
 )
 
+const (
+	// github allows redelivery of recent deliveries from the webhook settings page.
+	deliveryDeduplicationWindow = 72 * time.Hour
+
+	archiveTimeout = 5 * time.Second
+	// body in error logs is truncated to keep log entries small.
+	maxLoggedBodyBytes = 2048
+)
+
 // Github -
 type Github struct {
-	Webhook *github.Webhook
-	Slack   *app.Slack
+	// Webhook only parses payload. signature is verified by Verifier.
+	Webhook      *github.Webhook
+	Verifier     *SignatureVerifier
+	Notifier     *app.Notifier
+	Deduplicator app.Deduplicator
+	Dispatcher   *Dispatcher
+	// PullRequests tracks pull requests to map ci events to them.
+	PullRequests app.PullRequestStore
+
+	// raw deliveries are archived for investigation and replay.
+	Deliveries       app.WebhookDeliveryStore
+	ArchiveRetention time.Duration
+	Now              func() time.Time
 }
 
 var targetEvents = []github.Event{
 	github.PullRequestEvent,
 	github.PullRequestReviewEvent,
 	github.IssuesEvent,
+	github.IssueCommentEvent,
+	github.PullRequestReviewCommentEvent,
+	github.CheckRunEvent,
+	github.CheckSuiteEvent,
+	github.StatusEvent,
+	github.ReleaseEvent,
+	github.CreateEvent,
+	github.DeploymentEvent,
+	github.DeploymentStatusEvent,
+	github.PushEvent,
+}
+
+// rawEvents are not supported by github.Webhook. handlers decode their body.
+var rawEvents = []string{
+	"repository_vulnerability_alert",
+	"dependabot_alert",
+	"security_advisory",
 }
 
 // HandleWebhook -
 func (g *Github) HandleWebhook(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	payload, err := g.Webhook.Parse(r, targetEvents...)
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Error("read github event", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	if err := g.Verifier.Verify(r.Header, body); err != nil {
+		webhookMetrics.Add("signature_rejected", 1)
+		log.Warn("github/signature verification failed",
+			zap.String("event", r.Header.Get("X-GitHub-Event")),
+			zap.String("delivery", r.Header.Get("X-GitHub-Delivery")),
+			zap.String("remote_addr", r.RemoteAddr),
+			zap.Error(err))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	// github expects 2xx for events gobot does not subscribe to, ping included.
+	if event := r.Header.Get("X-GitHub-Event"); !isHandledEvent(event) {
+		webhookMetrics.Add("ignored", 1)
+		log.Info("github/ignore event", zap.String("event", event), zap.String("delivery", r.Header.Get("X-GitHub-Delivery")))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	g.archive(r, body)
+
+	payload, err := g.parse(r, body)
 	if err != nil {
-		log.Error("parse github event", zap.Reflect("request", r))
+		log.Error("parse github event",
+			zap.String("event", r.Header.Get("X-GitHub-Event")),
+			zap.String("delivery", r.Header.Get("X-GitHub-Delivery")),
+			zap.ByteString("body", truncateBody(body)),
+			zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	event := &webhookEvent{
+		Event:      r.Header.Get("X-GitHub-Event"),
+		DeliveryID: r.Header.Get("X-GitHub-Delivery"),
+		Body:       body,
+		Payload:    payload,
+	}
+	if err := g.Dispatcher.Enqueue(event); err != nil {
+		log.Error("github/enqueue event", zap.String("event", event.Event), zap.String("delivery", event.DeliveryID), zap.Error(err))
+		// github marks the delivery as failed, then it can be redelivered.
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	// 処理は非同期に行うのでgithubへはすぐに202を返す
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// archive saves raw delivery. failure of archiving does not prevent notifications.
+func (g *Github) archive(r *http.Request, body []byte) {
+	if g.Deliveries == nil {
 		return
 	}
-	switch payload := payload.(type) {
+	delivery := app.NewWebhookDelivery(r, body, g.Now(), g.ArchiveRetention)
+	ctx, cancel := context.WithTimeout(r.Context(), archiveTimeout)
+	defer cancel()
+	if err := g.Deliveries.SaveDelivery(ctx, delivery); err != nil {
+		log.Error("github/archive delivery", zap.String("delivery", delivery.ID), zap.Error(err))
+	}
+}
+
+// Replay implements app.WebhookReplayer.
+// archived delivery is parsed again and processed without deduplication.
+func (g *Github) Replay(ctx context.Context, deliveryID string) error {
+	if g.Deliveries == nil {
+		return errors.New("webhook archive is disabled")
+	}
+	delivery, err := g.Deliveries.GetDelivery(ctx, deliveryID)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	r, err := http.NewRequest(http.MethodPost, "/github/webhook", bytes.NewReader([]byte(delivery.Body)))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	r.Header = delivery.Header()
+
+	// signature headers are redacted on archive. archived deliveries were verified when received.
+	payload, err := g.parse(r, []byte(delivery.Body))
+	if err != nil {
+		return errors.Annotatef(err, "parse delivery %s", deliveryID)
+	}
+
+	event := &webhookEvent{
+		Event:      delivery.Event,
+		DeliveryID: delivery.ID,
+		Body:       []byte(delivery.Body),
+		Payload:    payload,
+		Replay:     true,
+	}
+	if err := g.Dispatcher.Enqueue(event); err != nil {
+		return errors.Annotatef(err, "enqueue delivery %s", deliveryID)
+	}
+	log.Info("github/replay delivery", zap.String("event", event.Event), zap.String("delivery", event.DeliveryID))
+	return nil
+}
+
+// parse returns typed payload of target events. payload of raw events is nil.
+func (g *Github) parse(r *http.Request, body []byte) (interface{}, error) {
+	if event := r.Header.Get("X-GitHub-Event"); isRawEvent(event) {
+		if !json.Valid(body) {
+			return nil, errors.Errorf("invalid %s payload", event)
+		}
+		return nil, nil
+	}
+	return g.Webhook.Parse(r, targetEvents...)
+}
+
+func isHandledEvent(event string) bool {
+	for _, e := range targetEvents {
+		if string(e) == event {
+			return true
+		}
+	}
+	return isRawEvent(event)
+}
+
+func isRawEvent(event string) bool {
+	for _, e := range rawEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func truncateBody(body []byte) []byte {
+	if len(body) <= maxLoggedBodyBytes {
+		return body
+	}
+	return body[:maxLoggedBodyBytes]
+}
+
+// Start starts processing webhook events.
+func (g *Github) Start() {
+	g.Dispatcher.GiveUp = g.forgetDelivery
+	g.Dispatcher.Start(g.process)
+}
+
+// Shutdown waits until queued webhook events are processed, then flushes notifications batched in memory.
+func (g *Github) Shutdown(ctx context.Context) error {
+	err := g.Dispatcher.Shutdown(ctx)
+	if g.Notifier != nil {
+		g.Notifier.Close()
+	}
+	return err
+}
+
+// process handles event. it returns error when event should be retried.
+// pull request state is tracked before notifications, so that failed tracking is retried without duplicate notifications.
+func (g *Github) process(e *webhookEvent) error {
+	// replay is requested explicitly, so it must not be ignored as duplicate.
+	// retries were checked on the first attempt.
+	if !e.Replay && e.Attempt <= 1 {
+		key := deliveryKey(e)
+		if ok := g.Deduplicator.CheckDuplicateNotification(key, deliveryDeduplicationWindow); !ok {
+			log.Info("github/ignore duplicate delivery", zap.String("key", key))
+			return nil
+		}
+	}
+
+	event := normalizeEvent(e)
+	switch e.Event {
+	case "pull_request", "pull_request_review_comment":
+		if err := g.trackPullRequest(e); err != nil {
+			return errors.Trace(err)
+		}
+	case "pull_request_review":
+		if err := g.trackPullRequest(e); err != nil {
+			return errors.Trace(err)
+		}
+		if err := g.trackReview(e); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	switch e.Event {
+	case "dependabot_alert":
+		g.handleDependabotAlert(event, e.Body)
+		return nil
+	case "repository_vulnerability_alert":
+		g.handleVulnerabilityAlert(event, e.Body)
+		return nil
+	case "security_advisory":
+		g.handleSecurityAdvisory(event, e.Body)
+		return nil
+	}
+	switch payload := e.Payload.(type) {
 	case github.IssuesPayload:
-		spew.Dump("issues", payload)
+		g.handleIssues(event, &payload)
 	case github.PullRequestPayload:
-		g.handlePullRequest(w, r, &payload)
+		g.handlePullRequest(event, &payload, e.Body)
 	case github.PullRequestReviewPayload:
-		g.handlePullRequestReview(w, r, &payload)
+		g.handlePullRequestReview(event, &payload, e.Body)
+	case github.IssueCommentPayload:
+		g.handleIssueComment(event, &payload)
+	case github.PullRequestReviewCommentPayload:
+		g.handlePullRequestReviewComment(event, &payload)
+	case github.CheckRunPayload:
+		g.handleCheckRun(event, e.Body)
+	case github.CheckSuitePayload:
+		g.handleCheckSuite(event, e.Body)
+	case github.StatusPayload:
+		g.handleStatus(event, e.Body)
+	case github.ReleasePayload:
+		g.handleRelease(event, e.Body)
+	case github.CreatePayload:
+		g.handleCreate(event, e.Body)
+	case github.DeploymentPayload, github.DeploymentStatusPayload:
+		g.handleDeployment(event, e.Body)
+	case github.PushPayload:
+		g.handlePush(event, e.Body)
 	default:
 	}
+	return nil
+}
+
+// forgetDelivery removes delivery key of event which is given up, so that redelivery from github is processed.
+func (g *Github) forgetDelivery(e *webhookEvent) {
+	if e.Replay {
+		return
+	}
+	g.Deduplicator.Forget(deliveryKey(e))
+}
+
+// deliveryKey identifies a delivery by X-GitHub-Delivery and event content.
+// redeliveries from github have the same delivery id and content.
+func deliveryKey(e *webhookEvent) string {
+	h := sha256.New()
+	h.Write([]byte(e.Event))
+	h.Write(e.Body)
+	return "github:" + e.DeliveryID + ":" + hex.EncodeToString(h.Sum(nil))
 }
 
 // see https://developer.github.com/v3/activity/events/types/#pullrequestevent
-func (g *Github) handlePullRequest(w http.ResponseWriter, r *http.Request, pr *github.PullRequestPayload) {
+func (g *Github) handlePullRequest(event *app.GithubEvent, pr *github.PullRequestPayload, body []byte) {
 	switch pr.Action {
 	case "review_requested":
-		g.handlePullRequestReviewRequested(w, r, pr)
+		g.handlePullRequestReviewRequested(event, pr, body)
+	case "review_request_removed":
+		g.handlePullRequestReviewRequestRemoved(event, pr, body)
 	default:
-		g.handlePullRequestUndefinedAction(w, r, pr)
+		g.handlePullRequestUndefinedAction(pr)
 	}
 }
 
 // see https://developer.github.com/v3/activity/events/types/#pullrequestreviewevent
-func (g *Github) handlePullRequestReview(w http.ResponseWriter, r *http.Request, pr *github.PullRequestReviewPayload) {
+func (g *Github) handlePullRequestReview(event *app.GithubEvent, pr *github.PullRequestReviewPayload, body []byte) {
 	switch pr.Action {
 	case "submitted":
-		g.handlePullRequestReviewSubmitted(w, r, pr)
+		g.handlePullRequestReviewSubmitted(event, pr)
+	case "dismissed":
+		g.handlePullRequestReviewDismissed(event, pr, body)
+	default:
+		g.handlePullRequestReviewUndefinedAction(pr)
+	}
+}
+
+// see https://developer.github.com/v3/activity/events/types/#issuesevent
+func (g *Github) handleIssues(event *app.GithubEvent, issue *github.IssuesPayload) {
+	switch issue.Action {
+	case "opened", "closed", "reopened":
+		g.handleIssueChanged(event, issue)
 	default:
-		g.handlePullRequestReviewUndefinedAction(w, r, pr)
+		log.Info("github/receive undefined action", zap.String("event", "issues"), zap.String("action", issue.Action))
+	}
+}
+
+// see https://developer.github.com/v3/activity/events/types/#issuecommentevent
+// comments on pull request conversation are also issue_comment.
+func (g *Github) handleIssueComment(event *app.GithubEvent, c *github.IssueCommentPayload) {
+	if c.Action != "created" {
+		log.Info("github/receive undefined action", zap.String("event", "issue_comment"), zap.String("action", c.Action))
+		return
+	}
+	log.Info("github/handle event", zap.String("event", "issue_comment"), zap.String("action", c.Action))
+
+	msg := &app.CommentMsg{
+		Commenter:          c.Comment.User.Login,
+		CommenterAvatarURL: c.Comment.User.AvatarURL,
+		Author:             c.Issue.User.Login,
+		Number:             c.Issue.Number,
+		Title:              c.Issue.Title,
+		Body:               c.Comment.Body,
+		URL:                c.Comment.HTMLURL,
+		ThreadURL:          c.Issue.HTMLURL, // pull requestの場合もpull requestのurlになる
+		PullRequest:        strings.Contains(c.Issue.HTMLURL, "/pull/"),
+		RepoName:           c.Repository.Name,
+		Event:              event,
+	}
+	if err := g.Notifier.NotifyComment(msg); err != nil {
+		log.Error("github", zap.String("event", "issue_comment"), zap.String("action", c.Action), zap.Error(err))
+	}
+}
+
+// see https://developer.github.com/v3/activity/events/types/#pullrequestreviewcommentevent
+func (g *Github) handlePullRequestReviewComment(event *app.GithubEvent, c *github.PullRequestReviewCommentPayload) {
+	if c.Action != "created" {
+		log.Info("github/receive undefined action", zap.String("event", "pull_request_review_comment"), zap.String("action", c.Action))
+		return
+	}
+	log.Info("github/handle event", zap.String("event", "pull_request_review_comment"), zap.String("action", c.Action))
+
+	msg := &app.CommentMsg{
+		Commenter:          c.Comment.User.Login,
+		CommenterAvatarURL: c.Comment.User.AvatarURL,
+		Author:             c.PullRequest.User.Login,
+		Number:             c.PullRequest.Number,
+		Title:              c.PullRequest.Title,
+		Body:               c.Comment.Body,
+		URL:                c.Comment.HTMLURL,
+		ThreadURL:          c.PullRequest.HTMLURL,
+		PullRequest:        true,
+		RepoName:           c.Repository.Name,
+		Event:              event,
+	}
+	if err := g.Notifier.NotifyComment(msg); err != nil {
+		log.Error("github", zap.String("event", "pull_request_review_comment"), zap.String("action", c.Action), zap.Error(err))
+	}
+}
+
+func (g *Github) handleIssueChanged(event *app.GithubEvent, issue *github.IssuesPayload) {
+	log.Info("github/handle event", zap.String("event", "issues"), zap.String("action", issue.Action))
+
+	msg := &app.IssueMsg{
+		Action:          issue.Action,
+		Author:          issue.Issue.User.Login,
+		AuthorAvatarURL: issue.Issue.User.AvatarURL,
+		Number:          issue.Issue.Number,
+		Title:           issue.Issue.Title,
+		Body:            issue.Issue.Body,
+		URL:             issue.Issue.HTMLURL,
+		RepoName:        issue.Repository.Name,
+		Event:           event,
+	}
+	if err := g.Notifier.NotifyIssue(msg); err != nil {
+		log.Error("github", zap.String("event", "issues"), zap.String("action", issue.Action), zap.Error(err))
 	}
 }
 
-func (g *Github) handlePullRequestReviewRequested(w http.ResponseWriter, _ *http.Request, pr *github.PullRequestPayload) {
+func (g *Github) handlePullRequestReviewRequested(event *app.GithubEvent, pr *github.PullRequestPayload, body []byte) {
 	log.Info("github/handle event", zap.String("event", "pullrequest"), zap.String("action", pr.Action))
 
 	msg := &app.PRReviewRequestedMsg{
@@ -72,6 +430,7 @@ func (g *Github) handlePullRequestReviewRequested(w http.ResponseWriter, _ *http
 		Title:          pr.PullRequest.Title,
 		Body:           pr.PullRequest.Body,
 		RepoName:       pr.Repository.Name,
+		Event:          event,
 	}
 
 	// 複数指定されうる
@@ -79,22 +438,36 @@ func (g *Github) handlePullRequestReviewRequested(w http.ResponseWriter, _ *http
 	for i := range pr.PullRequest.RequestedReviewers {
 		msg.RequestedReviewers[i] = pr.PullRequest.RequestedReviewers[i].Login
 	}
-
-	if err := g.Slack.NotifyPRReviewRequested(msg); err != nil {
+	fields, err := decodePullRequestFields(body)
+	if err != nil {
 		log.Error("github", zap.String("event", "pullrequest"), zap.String("action", pr.Action), zap.Error(err))
+		return
 	}
+	msg.RequestedTeams = fields.requestedTeams()
 
-	// githubへは200を返す
-	w.WriteHeader(http.StatusOK)
+	// re-request after changes requested is notified only to the reviewer with link to previous review.
+	if reviewer := fields.RequestedReviewer; reviewer != nil {
+		if review := g.previousReview(pr.Repository.FullName, pr.Number, reviewer.Login); review != nil {
+			msg.RequestedReviewers = []string{reviewer.Login}
+			msg.RequestedTeams = nil
+			msg.PreviousReviews = []*app.PullRequestReview{review}
+		}
+	}
+
+	if err := g.Notifier.NotifyPRReviewRequested(msg); err != nil {
+		log.Error("github", zap.String("event", "pullrequest"), zap.String("action", pr.Action), zap.Error(err))
+	}
 }
 
-func (g *Github) handlePullRequestReviewSubmitted(w http.ResponseWriter, _ *http.Request, pr *github.PullRequestReviewPayload) {
+func (g *Github) handlePullRequestReviewSubmitted(event *app.GithubEvent, pr *github.PullRequestReviewPayload) {
 	log.Info("github/handle event", zap.String("event", "pullrequest_review"), zap.String("action", pr.Action))
 
 	msg := &app.PRReviewSubmittedMsg{
 		Owner:             pr.PullRequest.User.Login,
 		Title:             pr.PullRequest.Title,
+		URL:               pr.PullRequest.HTMLURL,
 		RepoName:          pr.Repository.Name,
+		Event:             event,
 		Reviewer:          pr.Review.User.Login,
 		ReviewerAvatarURL: pr.Review.User.AvatarURL,
 		ReviewBody:        pr.Review.Body,
@@ -102,19 +475,71 @@ func (g *Github) handlePullRequestReviewSubmitted(w http.ResponseWriter, _ *http
 		ReviewURL:         pr.Review.HTMLURL,
 	}
 
-	if err := g.Slack.NotifyPRReviewSubmitted(msg); err != nil {
+	if err := g.Notifier.NotifyPRReviewSubmitted(msg); err != nil {
 		log.Error("github", zap.String("event", "pullrequest_review"), zap.String("action", pr.Action), zap.Error(err))
 	}
+}
 
-	// githubへは200を返す
-	w.WriteHeader(http.StatusOK)
+func (g *Github) handlePullRequestReviewRequestRemoved(event *app.GithubEvent, pr *github.PullRequestPayload, body []byte) {
+	log.Info("github/handle event", zap.String("event", "pullrequest"), zap.String("action", pr.Action))
+
+	fields, err := decodePullRequestFields(body)
+	if err != nil {
+		log.Error("github", zap.String("event", "pullrequest"), zap.String("action", pr.Action), zap.Error(err))
+		return
+	}
+	msg := &app.PRReviewRequestRemovedMsg{
+		RemovedBy: fields.Sender.Login,
+		Number:    pr.Number,
+		Title:     pr.PullRequest.Title,
+		URL:       pr.PullRequest.HTMLURL,
+		RepoName:  pr.Repository.Name,
+		Event:     event,
+	}
+	switch {
+	case fields.RequestedReviewer != nil:
+		msg.Reviewer = fields.RequestedReviewer.Login
+	case fields.RequestedTeam != nil:
+		msg.Team = fields.RequestedTeam.Slug
+	default:
+		log.Info("github/ignore review_request_removed without reviewer")
+		return
+	}
+
+	if err := g.Notifier.NotifyPRReviewRequestRemoved(msg); err != nil {
+		log.Error("github", zap.String("event", "pullrequest"), zap.String("action", pr.Action), zap.Error(err))
+	}
+}
+
+func (g *Github) handlePullRequestReviewDismissed(event *app.GithubEvent, pr *github.PullRequestReviewPayload, body []byte) {
+	log.Info("github/handle event", zap.String("event", "pullrequest_review"), zap.String("action", pr.Action))
+
+	var fields pullRequestReviewFields
+	if err := json.Unmarshal(body, &fields); err != nil {
+		log.Error("github", zap.String("event", "pullrequest_review"), zap.String("action", pr.Action), zap.Error(err))
+		return
+	}
+	msg := &app.PRReviewDismissedMsg{
+		Reviewer:    pr.Review.User.Login,
+		DismissedBy: fields.Sender.Login,
+		Number:      pr.PullRequest.Number,
+		Title:       pr.PullRequest.Title,
+		URL:         pr.PullRequest.HTMLURL,
+		ReviewURL:   pr.Review.HTMLURL,
+		ReviewState: pr.Review.State,
+		RepoName:    pr.Repository.Name,
+		Event:       event,
+	}
+	if err := g.Notifier.NotifyPRReviewDismissed(msg); err != nil {
+		log.Error("github", zap.String("event", "pullrequest_review"), zap.String("action", pr.Action), zap.Error(err))
+	}
 }
 
-func (g *Github) handlePullRequestUndefinedAction(_ http.ResponseWriter, _ *http.Request, pr *github.PullRequestPayload) {
+func (g *Github) handlePullRequestUndefinedAction(pr *github.PullRequestPayload) {
 	log.Info("github/receive undefined action", zap.String("event", "pullrequest"), zap.String("action", pr.Action))
 }
 
-func (g *Github) handlePullRequestReviewUndefinedAction(_ http.ResponseWriter, _ *http.Request, pr *github.PullRequestReviewPayload) {
+func (g *Github) handlePullRequestReviewUndefinedAction(pr *github.PullRequestReviewPayload) {
 	log.Info("github/receive undefiend action", zap.String("event", "pullrequest_review"), zap.String("action", pr.Action))
 }
 
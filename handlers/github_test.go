@@ -0,0 +1,125 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/ymgyt/gobot/app"
+	"github.com/ymgyt/gobot/handlers"
+)
+
+type fakeDeliveryStore struct {
+	saved []*app.WebhookDelivery
+}
+
+func (s *fakeDeliveryStore) SaveDelivery(ctx context.Context, d *app.WebhookDelivery) error {
+	s.saved = append(s.saved, d)
+	return nil
+}
+
+func (s *fakeDeliveryStore) GetDelivery(ctx context.Context, id string) (*app.WebhookDelivery, error) {
+	return nil, nil
+}
+
+func (s *fakeDeliveryStore) ListDeliveries(ctx context.Context, input *app.ListWebhookDeliveriesInput) (app.WebhookDeliveries, error) {
+	return nil, nil
+}
+
+func TestGithub_HandleWebhook_IgnoredEvent(t *testing.T) {
+	verifier, err := handlers.NewSignatureVerifier("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deliveries := &fakeDeliveryStore{}
+	g := &handlers.Github{
+		Verifier:   verifier,
+		Deliveries: deliveries,
+		Now:        time.Now,
+		// dispatcher is not running, so enqueued events would be rejected with 503.
+		Dispatcher: &handlers.Dispatcher{},
+	}
+
+	for _, event := range []string{"ping", "watch"} {
+		body := []byte(`{"zen":"Keep it logically awesome."}`)
+		r := httptest.NewRequest(http.MethodPost, "/github/webhook", bytes.NewReader(body))
+		r.Header.Set("X-GitHub-Event", event)
+		r.Header.Set("X-GitHub-Delivery", "72d3162e-cc78-11e3-81ab-4c9367dc0958")
+		r.Header.Set("X-Hub-Signature-256", "sha256="+sign(sha256.New, "secret", body))
+		w := httptest.NewRecorder()
+
+		g.HandleWebhook(w, r, nil)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: want 200, got %d", event, w.Code)
+		}
+	}
+	if len(deliveries.saved) != 0 {
+		t.Errorf("ignored events are archived: %d", len(deliveries.saved))
+	}
+}
+
+// recordingDeduplicator sees every key first time and records forgotten keys.
+type recordingDeduplicator struct {
+	mu        sync.Mutex
+	checked   []string
+	forgotten []string
+}
+
+func (d *recordingDeduplicator) CheckDuplicateNotification(eventKey string, window time.Duration) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.checked = append(d.checked, eventKey)
+	return true
+}
+
+func (d *recordingDeduplicator) Forget(eventKey string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.forgotten = append(d.forgotten, eventKey)
+}
+
+type failingPullRequestStore struct {
+	app.PullRequestStore
+}
+
+func (failingPullRequestStore) SavePullRequest(ctx context.Context, pr *app.PullRequest) error {
+	return errors.New("mongo is down")
+}
+
+func TestGithub_GiveUpForgetsDelivery(t *testing.T) {
+	dedup := &recordingDeduplicator{}
+	g := &handlers.Github{
+		Deduplicator: dedup,
+		PullRequests: failingPullRequestStore{},
+		Dispatcher:   &handlers.Dispatcher{Workers: 1, QueueSize: 1, MaxAttempts: 2, Backoff: time.Millisecond},
+		Now:          time.Now,
+	}
+	g.Start()
+	err := g.Dispatcher.Enqueue(&handlers.WebhookEvent{
+		Event:      "pull_request",
+		DeliveryID: "72d3162e-cc78-11e3-81ab-4c9367dc0958",
+		Body:       []byte(`{"action":"closed","pull_request":{"number":1},"repository":{"full_name":"ymgyt/gobot"}}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Dispatcher.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// redelivery from github is processed after giving up.
+	if len(dedup.checked) != 1 {
+		t.Fatalf("want delivery checked once, got %v", dedup.checked)
+	}
+	if diff := cmp.Diff(dedup.checked, dedup.forgotten); diff != "" {
+		t.Errorf("forgotten keys (-want +got)\n%s", diff)
+	}
+}
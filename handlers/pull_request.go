@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+	"go.uber.org/zap"
+
+	"github.com/ymgyt/gobot/app"
+	"github.com/ymgyt/gobot/log"
+)
+
+const (
+	trackTimeout = 5 * time.Second
+)
+
+type pullRequestFields struct {
+	Action      string `json:"action"`
+	PullRequest struct {
+		Number  int64         `json:"number"`
+		Title   string        `json:"title"`
+		HTMLURL string        `json:"html_url"`
+		State   string        `json:"state"`
+		Draft   bool          `json:"draft"`
+		User    githubUser    `json:"user"`
+		Labels  []githubLabel `json:"labels"`
+		Head    struct {
+			Ref string `json:"ref"`
+			SHA string `json:"sha"`
+		} `json:"head"`
+		Base struct {
+			Ref string `json:"ref"`
+		} `json:"base"`
+		RequestedReviewers []githubUser `json:"requested_reviewers"`
+		RequestedTeams     []githubTeam `json:"requested_teams"`
+		CreatedAt          time.Time    `json:"created_at"`
+		ClosedAt           time.Time    `json:"closed_at"`
+		MergedAt           time.Time    `json:"merged_at"`
+		UpdatedAt          time.Time    `json:"updated_at"`
+	} `json:"pull_request"`
+	// RequestedReviewer or RequestedTeam is set on review_requested and review_request_removed.
+	RequestedReviewer *githubUser      `json:"requested_reviewer"`
+	RequestedTeam     *githubTeam      `json:"requested_team"`
+	Repository        githubRepository `json:"repository"`
+	Sender            githubUser       `json:"sender"`
+}
+
+// see https://developer.github.com/v3/activity/events/types/#pullrequestreviewevent
+type pullRequestReviewFields struct {
+	Action string `json:"action"`
+	Review struct {
+		User        githubUser `json:"user"`
+		State       string     `json:"state"`
+		HTMLURL     string     `json:"html_url"`
+		SubmittedAt time.Time  `json:"submitted_at"`
+	} `json:"review"`
+	PullRequest struct {
+		Number int64 `json:"number"`
+	} `json:"pull_request"`
+	Repository githubRepository `json:"repository"`
+	Sender     githubUser       `json:"sender"`
+}
+
+type githubTeam struct {
+	Slug string `json:"slug"`
+}
+
+// requestedTeams returns slugs of teams requested to review.
+func (f *pullRequestFields) requestedTeams() []string {
+	var teams []string
+	for _, team := range f.PullRequest.RequestedTeams {
+		teams = append(teams, team.Slug)
+	}
+	return teams
+}
+
+// typed payload does not have requested_teams and requested_reviewer, so they are decoded from raw body.
+func decodePullRequestFields(body []byte) (*pullRequestFields, error) {
+	var fields pullRequestFields
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, errors.Annotate(err, "decode pull_request")
+	}
+	return &fields, nil
+}
+
+// trackReview saves latest review of reviewer to detect re-requests.
+func (g *Github) trackReview(e *webhookEvent) error {
+	if g.PullRequests == nil {
+		return nil
+	}
+	var fields pullRequestReviewFields
+	if err := json.Unmarshal(e.Body, &fields); err != nil {
+		log.Error("github/decode pull_request_review", zap.Error(err))
+		return nil
+	}
+	if fields.Action != "submitted" && fields.Action != "dismissed" {
+		return nil
+	}
+	r := fields.Review
+	review := &app.PullRequestReview{
+		Reviewer:    r.User.Login,
+		State:       strings.ToLower(r.State),
+		URL:         r.HTMLURL,
+		SubmittedAt: r.SubmittedAt,
+	}
+	id := app.PullRequestID(fields.Repository.FullName, fields.PullRequest.Number)
+
+	ctx, cancel := context.WithTimeout(context.Background(), trackTimeout)
+	defer cancel()
+	return errors.Annotate(g.PullRequests.SaveReview(ctx, id, review), "track review")
+}
+
+// previousReview returns changes_requested review of reviewer. it returns nil when reviewer has not requested changes.
+// comments after the review do not hide it, but approval or dismissal does.
+func (g *Github) previousReview(repository string, number int64, reviewer string) *app.PullRequestReview {
+	if g.PullRequests == nil {
+		return nil
+	}
+	id := app.PullRequestID(repository, number)
+	ctx, cancel := context.WithTimeout(context.Background(), trackTimeout)
+	defer cancel()
+	pr, err := g.PullRequests.GetPullRequest(ctx, id)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			log.Error("github/get pull request", zap.String("pull_request", id), zap.Error(err))
+		}
+		return nil
+	}
+	if review := pr.LastBlockingReview(reviewer); review != nil && review.State == app.ReviewChangesRequested {
+		return review
+	}
+	return nil
+}
+
+// trackPullRequest saves state of pull request from pull_request, pull_request_review and pull_request_review_comment events.
+// they have the same pull_request field.
+func (g *Github) trackPullRequest(e *webhookEvent) error {
+	if g.PullRequests == nil {
+		return nil
+	}
+	fields, err := decodePullRequestFields(e.Body)
+	if err != nil {
+		// malformed payload is not retried.
+		log.Error("github/track pull request", zap.String("event", e.Event), zap.Error(err))
+		return nil
+	}
+	p := fields.PullRequest
+	if p.Number == 0 {
+		return nil
+	}
+	pr := &app.PullRequest{
+		ID:             app.PullRequestID(fields.Repository.FullName, p.Number),
+		Repository:     fields.Repository.FullName,
+		Number:         p.Number,
+		Title:          p.Title,
+		URL:            p.HTMLURL,
+		Author:         p.User.Login,
+		State:          p.State,
+		Draft:          p.Draft,
+		HeadSHA:        p.Head.SHA,
+		HeadRef:        p.Head.Ref,
+		BaseRef:        p.Base.Ref,
+		RequestedTeams: fields.requestedTeams(),
+		CreatedAt:      p.CreatedAt,
+		ClosedAt:       p.ClosedAt,
+		MergedAt:       p.MergedAt,
+		// payload has the state of pull request at the time, so the latest update wins.
+		GithubUpdatedAt: p.UpdatedAt,
+	}
+	for _, reviewer := range p.RequestedReviewers {
+		pr.RequestedReviewers = append(pr.RequestedReviewers, reviewer.Login)
+	}
+	for _, label := range p.Labels {
+		pr.Labels = append(pr.Labels, label.Name)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), trackTimeout)
+	defer cancel()
+	if err := g.PullRequests.SavePullRequest(ctx, pr); err != nil {
+		if errors.Cause(err) == app.ErrStalePullRequest {
+			log.Info("github/ignore stale pull request", zap.String("event", e.Event), zap.String("pull_request", pr.ID), zap.Time("updated_at", pr.GithubUpdatedAt))
+			return nil
+		}
+		return errors.Annotate(err, "track pull request")
+	}
+	if fields.Action == "review_requested" && fields.RequestedReviewer != nil {
+		if err := g.PullRequests.SaveReviewRequest(ctx, pr.ID, fields.RequestedReviewer.Login, g.Now()); err != nil {
+			return errors.Annotate(err, "track review request")
+		}
+	}
+	return nil
+}
+
+// trackCheck saves result of check run or commit status to pull requests of the commit.
+func (g *Github) trackCheck(repository string, check *app.PullRequestCheck) {
+	if g.PullRequests == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), trackTimeout)
+	defer cancel()
+	if err := g.PullRequests.SaveCheck(ctx, repository, check); err != nil {
+		log.Error("github/track check", zap.String("repository", repository), zap.String("sha", check.SHA), zap.Error(err))
+	}
+}
@@ -0,0 +1,233 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/juju/errors"
+	"gopkg.in/go-playground/webhooks.v5/github"
+
+	"github.com/ymgyt/gobot/app"
+	"github.com/ymgyt/gobot/handlers"
+)
+
+// fakePullRequestStore keeps pull requests in memory. methods not used by handlers are not implemented.
+type fakePullRequestStore struct {
+	app.PullRequestStore
+	prs     map[string]*app.PullRequest
+	reviews []*app.PullRequestReview
+}
+
+func (s *fakePullRequestStore) GetPullRequest(ctx context.Context, id string) (*app.PullRequest, error) {
+	pr, ok := s.prs[id]
+	if !ok {
+		return nil, errors.NotFoundf("pull request %s", id)
+	}
+	return pr, nil
+}
+
+func (s *fakePullRequestStore) SavePullRequest(ctx context.Context, pr *app.PullRequest) error {
+	return nil
+}
+
+func (s *fakePullRequestStore) SaveReviewRequest(ctx context.Context, id, reviewer string, at time.Time) error {
+	return nil
+}
+
+func (s *fakePullRequestStore) SaveReview(ctx context.Context, id string, review *app.PullRequestReview) error {
+	s.reviews = append(s.reviews, review)
+	return nil
+}
+
+type fakeOutboxStore struct {
+	app.OutboxStore
+	messages []*app.OutboxMessage
+}
+
+func (s *fakeOutboxStore) Enqueue(ctx context.Context, msg *app.OutboxMessage) error {
+	s.messages = append(s.messages, msg)
+	return nil
+}
+
+// fakeUserStore does not know any user.
+type fakeUserStore struct {
+	app.UserStore
+}
+
+func (s fakeUserStore) FindUsers(ctx context.Context, input *app.FindUsersInput) (app.Users, error) {
+	return nil, app.ErrUserNotFound
+}
+
+type notDuplicated struct{}
+
+func (notDuplicated) CheckDuplicateNotification(eventKey string, d time.Duration) bool { return true }
+
+func (notDuplicated) Forget(eventKey string) {}
+
+func newPullRequestGithub(prs *fakePullRequestStore) (*handlers.Github, *fakeOutboxStore) {
+	outbox := &fakeOutboxStore{}
+	now := func() time.Time { return time.Date(2019, 6, 10, 9, 0, 0, 0, time.UTC) }
+	g := &handlers.Github{
+		Notifier: &app.Notifier{
+			Outbox:             outbox,
+			AccountResolver:    &app.AccountResolver{UserStore: fakeUserStore{}},
+			DuplicationChecker: notDuplicated{},
+			Renderer:           app.RenderAttachments,
+			Channel:            "C1",
+			Now:                now,
+		},
+		PullRequests: prs,
+		Now:          now,
+	}
+	return g, outbox
+}
+
+func process(t *testing.T, g *handlers.Github, event string, payload interface{}, body string) {
+	t.Helper()
+	if err := json.Unmarshal([]byte(body), payload); err != nil {
+		t.Fatal(err)
+	}
+	var p interface{}
+	switch v := payload.(type) {
+	case *github.PullRequestPayload:
+		p = *v
+	case *github.PullRequestReviewPayload:
+		p = *v
+	}
+	// replay skips deduplication.
+	e := &handlers.WebhookEvent{Event: event, DeliveryID: "1", Body: []byte(body), Payload: p, Replay: true}
+	if err := g.Process(e); err != nil {
+		t.Fatal(err)
+	}
+}
+
+const (
+	pullRequestURL = "https://github.com/ymgyt/gobot/pull/1"
+	reviewURL      = "https://github.com/ymgyt/gobot/pull/1#pullrequestreview-1"
+)
+
+const reviewRequestedBody = `{
+  "action": "review_requested",
+  "number": 1,
+  "pull_request": {"number": 1, "title": "Add feature", "html_url": "` + pullRequestURL + `", "state": "open",
+    "user": {"login": "octocat"}, "requested_reviewers": [{"login": "alice"}, {"login": "bob"}]},
+  "requested_reviewer": {"login": "alice"},
+  "repository": {"name": "gobot", "full_name": "ymgyt/gobot"},
+  "sender": {"login": "octocat"}
+}`
+
+func TestGithub_ReviewReRequested(t *testing.T) {
+	changesRequested := &app.PullRequestReview{Reviewer: "alice", State: app.ReviewChangesRequested, URL: reviewURL}
+	tests := map[string]struct {
+		review *app.PullRequestReview
+		// wantReRequested means notification is sent only to alice with link to previous review.
+		wantReRequested bool
+	}{
+		"not reviewed": {},
+		"changes requested": {
+			review:          changesRequested,
+			wantReRequested: true,
+		},
+		"commented after changes requested": {
+			review:          &app.PullRequestReview{Reviewer: "alice", State: app.ReviewCommented, LastBlocking: changesRequested},
+			wantReRequested: true,
+		},
+		"dismissed": {
+			review: &app.PullRequestReview{Reviewer: "alice", State: app.ReviewDismissed, LastBlocking: &app.PullRequestReview{Reviewer: "alice", State: app.ReviewDismissed, URL: reviewURL}},
+		},
+		"approved": {
+			review: &app.PullRequestReview{Reviewer: "alice", State: app.ReviewApproved},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			pr := &app.PullRequest{ID: app.PullRequestID("ymgyt/gobot", 1)}
+			if tc.review != nil {
+				pr.Reviews = map[string]*app.PullRequestReview{"alice": tc.review}
+			}
+			g, outbox := newPullRequestGithub(&fakePullRequestStore{prs: map[string]*app.PullRequest{pr.ID: pr}})
+
+			process(t, g, "pull_request", &github.PullRequestPayload{}, reviewRequestedBody)
+			if len(outbox.messages) != 1 {
+				t.Fatalf("want 1 message, got %d", len(outbox.messages))
+			}
+			a := outbox.messages[0].Attachments[0]
+			if got := strings.Contains(a.Pretext, "ready for another look"); got != tc.wantReRequested {
+				t.Errorf("re-requested: want %v, got pretext %q", tc.wantReRequested, a.Pretext)
+			}
+			if got := strings.Contains(a.Text, reviewURL); got != tc.wantReRequested {
+				t.Errorf("link to previous review: want %v, got text %q", tc.wantReRequested, a.Text)
+			}
+			if got := strings.Contains(a.Pretext, "bob"); got == tc.wantReRequested {
+				t.Errorf("other reviewer is mentioned: %v, pretext %q", got, a.Pretext)
+			}
+		})
+	}
+}
+
+func TestGithub_ReviewDismissed(t *testing.T) {
+	submittedAt := time.Date(2019, 6, 10, 9, 0, 0, 0, time.UTC)
+	prs := &fakePullRequestStore{}
+	g, outbox := newPullRequestGithub(prs)
+
+	process(t, g, "pull_request_review", &github.PullRequestReviewPayload{}, `{
+  "action": "dismissed",
+  "review": {"user": {"login": "alice"}, "state": "dismissed", "html_url": "`+reviewURL+`", "submitted_at": "2019-06-10T09:00:00Z"},
+  "pull_request": {"number": 1, "title": "Add feature", "html_url": "`+pullRequestURL+`", "user": {"login": "octocat"}},
+  "repository": {"name": "gobot", "full_name": "ymgyt/gobot"},
+  "sender": {"login": "octocat"}
+}`)
+
+	want := []*app.PullRequestReview{{Reviewer: "alice", State: app.ReviewDismissed, URL: reviewURL, SubmittedAt: submittedAt}}
+	if diff := cmp.Diff(want, prs.reviews); diff != "" {
+		t.Errorf("saved reviews (-want +got)\n%s", diff)
+	}
+	if len(outbox.messages) != 1 {
+		t.Fatalf("want 1 message, got %d", len(outbox.messages))
+	}
+	if pretext := outbox.messages[0].Attachments[0].Pretext; !strings.Contains(pretext, "your review was dismissed by octocat") {
+		t.Errorf("unexpected pretext %q", pretext)
+	}
+}
+
+func TestGithub_ReviewRequestRemoved(t *testing.T) {
+	tests := map[string]struct {
+		removed string
+		// want is substring of pretext. empty means no notification.
+		want string
+	}{
+		"reviewer": {removed: `"requested_reviewer": {"login": "alice"},`, want: "alice"},
+		"team":     {removed: `"requested_team": {"slug": "backend"},`, want: "backend"},
+		"unknown":  {},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			g, outbox := newPullRequestGithub(&fakePullRequestStore{})
+			process(t, g, "pull_request", &github.PullRequestPayload{}, `{
+  "action": "review_request_removed",
+  "number": 1,
+  "pull_request": {"number": 1, "title": "Add feature", "html_url": "`+pullRequestURL+`", "state": "open", "user": {"login": "octocat"}},
+  `+tc.removed+`
+  "repository": {"name": "gobot", "full_name": "ymgyt/gobot"},
+  "sender": {"login": "octocat"}
+}`)
+			if tc.want == "" {
+				if len(outbox.messages) != 0 {
+					t.Errorf("want no message, got %d", len(outbox.messages))
+				}
+				return
+			}
+			if len(outbox.messages) != 1 {
+				t.Fatalf("want 1 message, got %d", len(outbox.messages))
+			}
+			pretext := outbox.messages[0].Attachments[0].Pretext
+			if !strings.Contains(pretext, tc.want) || !strings.Contains(pretext, "review request was removed by octocat") {
+				t.Errorf("unexpected pretext %q", pretext)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/ymgyt/gobot/app"
+	"github.com/ymgyt/gobot/log"
+)
+
+const branchRefPrefix = "refs/heads/"
+
+// see https://developer.github.com/v3/activity/events/types/#pushevent
+type pushFields struct {
+	Ref     string `json:"ref"`
+	Deleted bool   `json:"deleted"`
+	Forced  bool   `json:"forced"`
+	Compare string `json:"compare"`
+	Commits []struct {
+		ID      string `json:"id"`
+		Message string `json:"message"`
+		URL     string `json:"url"`
+		Author  struct {
+			Name     string `json:"name"`
+			Username string `json:"username"`
+		} `json:"author"`
+	} `json:"commits"`
+	Sender     githubSender `json:"sender"`
+	Repository struct {
+		githubRepository
+		DefaultBranch string `json:"default_branch"`
+	} `json:"repository"`
+}
+
+// pushes of tags are not notified.
+func (g *Github) handlePush(event *app.GithubEvent, body []byte) {
+	var fields pushFields
+	if err := json.Unmarshal(body, &fields); err != nil {
+		log.Error("github/decode push", zap.Error(err))
+		return
+	}
+	if !strings.HasPrefix(fields.Ref, branchRefPrefix) {
+		return
+	}
+	branch := strings.TrimPrefix(fields.Ref, branchRefPrefix)
+	log.Info("github/handle event", zap.String("event", "push"), zap.String("branch", branch), zap.Bool("forced", fields.Forced), zap.Bool("deleted", fields.Deleted))
+
+	// rules can match pushed branch by base and pusher by author.
+	event.Base = branch
+	event.Author = fields.Sender.Login
+
+	msg := &app.PushMsg{
+		Branch:          branch,
+		DefaultBranch:   fields.Repository.DefaultBranch,
+		Pusher:          fields.Sender.Login,
+		PusherAvatarURL: fields.Sender.AvatarURL,
+		Forced:          fields.Forced,
+		Deleted:         fields.Deleted,
+		CompareURL:      fields.Compare,
+		RepoName:        fields.Repository.Name,
+		Event:           event,
+	}
+	for _, c := range fields.Commits {
+		author := c.Author.Username
+		if author == "" {
+			author = c.Author.Name
+		}
+		msg.Commits = append(msg.Commits, &app.PushCommit{ID: c.ID, Message: c.Message, Author: author, URL: c.URL})
+	}
+	if err := g.Notifier.NotifyPush(msg); err != nil {
+		log.Error("github", zap.String("event", "push"), zap.Error(err))
+	}
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"encoding/json"
+
+	"go.uber.org/zap"
+
+	"github.com/ymgyt/gobot/app"
+	"github.com/ymgyt/gobot/log"
+)
+
+type githubSender struct {
+	Login     string `json:"login"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+// see https://developer.github.com/v3/activity/events/types/#releaseevent
+type releaseFields struct {
+	Action  string `json:"action"`
+	Release struct {
+		TagName    string       `json:"tag_name"`
+		Name       string       `json:"name"`
+		Body       string       `json:"body"`
+		HTMLURL    string       `json:"html_url"`
+		Draft      bool         `json:"draft"`
+		Prerelease bool         `json:"prerelease"`
+		Author     githubSender `json:"author"`
+	} `json:"release"`
+	Repository githubRepository `json:"repository"`
+}
+
+// see https://developer.github.com/v3/activity/events/types/#createevent
+type createFields struct {
+	Ref        string       `json:"ref"`
+	RefType    string       `json:"ref_type"`
+	Sender     githubSender `json:"sender"`
+	Repository struct {
+		githubRepository
+		HTMLURL string `json:"html_url"`
+	} `json:"repository"`
+}
+
+type githubDeployment struct {
+	ID          int64        `json:"id"`
+	SHA         string       `json:"sha"`
+	Ref         string       `json:"ref"`
+	Environment string       `json:"environment"`
+	Description string       `json:"description"`
+	Creator     githubSender `json:"creator"`
+}
+
+// see https://developer.github.com/v3/activity/events/types/#deploymentevent
+// and https://developer.github.com/v3/activity/events/types/#deploymentstatusevent
+type deploymentFields struct {
+	Deployment       githubDeployment `json:"deployment"`
+	DeploymentStatus *struct {
+		ID             int64        `json:"id"`
+		State          string       `json:"state"`
+		Description    string       `json:"description"`
+		TargetURL      string       `json:"target_url"`
+		LogURL         string       `json:"log_url"`
+		EnvironmentURL string       `json:"environment_url"`
+		Creator        githubSender `json:"creator"`
+	} `json:"deployment_status"`
+	Repository githubRepository `json:"repository"`
+}
+
+func (g *Github) handleRelease(event *app.GithubEvent, body []byte) {
+	var fields releaseFields
+	if err := json.Unmarshal(body, &fields); err != nil {
+		log.Error("github/decode release", zap.Error(err))
+		return
+	}
+	if fields.Action != "published" || fields.Release.Draft {
+		log.Info("github/receive undefined action", zap.String("event", "release"), zap.String("action", fields.Action))
+		return
+	}
+	log.Info("github/handle event", zap.String("event", "release"), zap.String("action", fields.Action))
+
+	r := fields.Release
+	msg := &app.ReleaseMsg{
+		Author:          r.Author.Login,
+		AuthorAvatarURL: r.Author.AvatarURL,
+		TagName:         r.TagName,
+		Name:            r.Name,
+		Body:            r.Body,
+		URL:             r.HTMLURL,
+		Prerelease:      r.Prerelease,
+		RepoName:        fields.Repository.Name,
+		Event:           event,
+	}
+	if err := g.Notifier.NotifyRelease(msg); err != nil {
+		log.Error("github", zap.String("event", "release"), zap.String("action", fields.Action), zap.Error(err))
+	}
+}
+
+// only tags are notified. branches are created too often.
+func (g *Github) handleCreate(event *app.GithubEvent, body []byte) {
+	var fields createFields
+	if err := json.Unmarshal(body, &fields); err != nil {
+		log.Error("github/decode create", zap.Error(err))
+		return
+	}
+	if fields.RefType != "tag" {
+		return
+	}
+	log.Info("github/handle event", zap.String("event", "create"), zap.String("ref_type", fields.RefType))
+
+	msg := &app.TagMsg{
+		Sender:          fields.Sender.Login,
+		SenderAvatarURL: fields.Sender.AvatarURL,
+		Tag:             fields.Ref,
+		URL:             fields.Repository.HTMLURL + "/tree/" + fields.Ref,
+		RepoName:        fields.Repository.Name,
+		Event:           event,
+	}
+	if err := g.Notifier.NotifyTag(msg); err != nil {
+		log.Error("github", zap.String("event", "create"), zap.Error(err))
+	}
+}
+
+// deployment and deployment_status update the same message.
+func (g *Github) handleDeployment(event *app.GithubEvent, body []byte) {
+	var fields deploymentFields
+	if err := json.Unmarshal(body, &fields); err != nil {
+		log.Error("github/decode deployment", zap.String("event", event.Name), zap.Error(err))
+		return
+	}
+	d := fields.Deployment
+	msg := &app.DeploymentMsg{
+		ID:               d.ID,
+		Environment:      d.Environment,
+		Ref:              d.Ref,
+		SHA:              d.SHA,
+		State:            "pending",
+		Description:      d.Description,
+		Creator:          d.Creator.Login,
+		CreatorAvatarURL: d.Creator.AvatarURL,
+		RepoName:         fields.Repository.Name,
+		Event:            event,
+	}
+	if status := fields.DeploymentStatus; status != nil {
+		msg.StatusID = status.ID
+		msg.State = status.State
+		if status.Description != "" {
+			msg.Description = status.Description
+		}
+		msg.URL = status.LogURL
+		if msg.URL == "" {
+			msg.URL = status.TargetURL
+		}
+		msg.EnvironmentURL = status.EnvironmentURL
+	}
+	log.Info("github/handle event", zap.String("event", event.Name), zap.String("environment", msg.Environment), zap.String("state", msg.State))
+
+	if err := g.Notifier.NotifyDeployment(msg); err != nil {
+		log.Error("github", zap.String("event", event.Name), zap.Error(err))
+	}
+}
@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/ymgyt/gobot/app"
+	"github.com/ymgyt/gobot/log"
+)
+
+type githubAdvisory struct {
+	GHSAID   string `json:"ghsa_id"`
+	CVEID    string `json:"cve_id"`
+	Summary  string `json:"summary"`
+	Severity string `json:"severity"`
+	HTMLURL  string `json:"html_url"`
+}
+
+func (a *githubAdvisory) identifier() string {
+	if a.GHSAID != "" {
+		return a.GHSAID
+	}
+	return a.CVEID
+}
+
+type githubVulnerability struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"`
+	} `json:"package"`
+	Severity               string `json:"severity"`
+	VulnerableVersionRange string `json:"vulnerable_version_range"`
+	FirstPatchedVersion    *struct {
+		Identifier string `json:"identifier"`
+	} `json:"first_patched_version"`
+}
+
+func (v *githubVulnerability) patchedVersion() string {
+	if v.FirstPatchedVersion == nil {
+		return ""
+	}
+	return v.FirstPatchedVersion.Identifier
+}
+
+// see https://docs.github.com/en/webhooks/webhook-events-and-payloads#dependabot_alert
+type dependabotAlertFields struct {
+	Action string `json:"action"`
+	Alert  struct {
+		Number     int64  `json:"number"`
+		State      string `json:"state"`
+		HTMLURL    string `json:"html_url"`
+		Dependency struct {
+			Package struct {
+				Ecosystem string `json:"ecosystem"`
+				Name      string `json:"name"`
+			} `json:"package"`
+			ManifestPath string `json:"manifest_path"`
+		} `json:"dependency"`
+		SecurityAdvisory      githubAdvisory      `json:"security_advisory"`
+		SecurityVulnerability githubVulnerability `json:"security_vulnerability"`
+		DismissedBy           *githubSender       `json:"dismissed_by"`
+		DismissedReason       string              `json:"dismissed_reason"`
+	} `json:"alert"`
+	Repository githubRepository `json:"repository"`
+}
+
+// see https://developer.github.com/v3/activity/events/types/#repositoryvulnerabilityalertevent
+type vulnerabilityAlertFields struct {
+	Action string `json:"action"`
+	Alert  struct {
+		ID                  int64         `json:"id"`
+		AffectedPackageName string        `json:"affected_package_name"`
+		AffectedRange       string        `json:"affected_range"`
+		FixedIn             string        `json:"fixed_in"`
+		ExternalIdentifier  string        `json:"external_identifier"`
+		ExternalReference   string        `json:"external_reference"`
+		GHSAID              string        `json:"ghsa_id"`
+		Severity            string        `json:"severity"`
+		Dismisser           *githubSender `json:"dismisser"`
+		DismissReason       string        `json:"dismiss_reason"`
+	} `json:"alert"`
+	Repository struct {
+		githubRepository
+		HTMLURL string `json:"html_url"`
+	} `json:"repository"`
+}
+
+// see https://developer.github.com/v3/activity/events/types/#securityadvisoryevent
+type securityAdvisoryFields struct {
+	Action           string `json:"action"`
+	SecurityAdvisory struct {
+		githubAdvisory
+		References []struct {
+			URL string `json:"url"`
+		} `json:"references"`
+		Vulnerabilities []githubVulnerability `json:"vulnerabilities"`
+	} `json:"security_advisory"`
+}
+
+// dependabot alert states are open, dismissed, fixed and auto_dismissed.
+func dependabotAlertState(state string) string {
+	if strings.HasSuffix(state, app.SecurityAlertDismissed) {
+		return app.SecurityAlertDismissed
+	}
+	return state
+}
+
+func (g *Github) handleDependabotAlert(event *app.GithubEvent, body []byte) {
+	var fields dependabotAlertFields
+	if err := json.Unmarshal(body, &fields); err != nil {
+		log.Error("github/decode dependabot_alert", zap.Error(err))
+		return
+	}
+	log.Info("github/handle event", zap.String("event", "dependabot_alert"), zap.String("action", fields.Action))
+
+	a := fields.Alert
+	msg := &app.SecurityAlertMsg{
+		Key:             fmt.Sprintf("dependabot:%s:%d", strings.ToLower(fields.Repository.FullName), a.Number),
+		Action:          fields.Action,
+		State:           dependabotAlertState(a.State),
+		Severity:        a.SecurityAdvisory.Severity,
+		Ecosystem:       a.Dependency.Package.Ecosystem,
+		Package:         a.Dependency.Package.Name,
+		VulnerableRange: a.SecurityVulnerability.VulnerableVersionRange,
+		PatchedVersion:  a.SecurityVulnerability.patchedVersion(),
+		Identifier:      a.SecurityAdvisory.identifier(),
+		Summary:         a.SecurityAdvisory.Summary,
+		Manifest:        a.Dependency.ManifestPath,
+		URL:             a.HTMLURL,
+		DismissReason:   a.DismissedReason,
+		RepoName:        fields.Repository.Name,
+		Event:           event,
+	}
+	if a.DismissedBy != nil {
+		msg.DismissedBy = a.DismissedBy.Login
+	}
+	if err := g.Notifier.NotifySecurityAlert(msg); err != nil {
+		log.Error("github", zap.String("event", "dependabot_alert"), zap.String("action", fields.Action), zap.Error(err))
+	}
+}
+
+var vulnerabilityAlertStates = map[string]string{
+	"create":  app.SecurityAlertOpen,
+	"dismiss": app.SecurityAlertDismissed,
+	"resolve": app.SecurityAlertFixed,
+}
+
+func (g *Github) handleVulnerabilityAlert(event *app.GithubEvent, body []byte) {
+	var fields vulnerabilityAlertFields
+	if err := json.Unmarshal(body, &fields); err != nil {
+		log.Error("github/decode repository_vulnerability_alert", zap.Error(err))
+		return
+	}
+	state, ok := vulnerabilityAlertStates[fields.Action]
+	if !ok {
+		log.Info("github/receive undefined action", zap.String("event", "repository_vulnerability_alert"), zap.String("action", fields.Action))
+		return
+	}
+	log.Info("github/handle event", zap.String("event", "repository_vulnerability_alert"), zap.String("action", fields.Action))
+
+	a := fields.Alert
+	identifier := a.GHSAID
+	if identifier == "" {
+		identifier = a.ExternalIdentifier
+	}
+	msg := &app.SecurityAlertMsg{
+		Key:             fmt.Sprintf("vulnerability:%s:%d", strings.ToLower(fields.Repository.FullName), a.ID),
+		Action:          fields.Action,
+		State:           state,
+		Severity:        a.Severity,
+		Package:         a.AffectedPackageName,
+		VulnerableRange: a.AffectedRange,
+		PatchedVersion:  a.FixedIn,
+		Identifier:      identifier,
+		URL:             a.ExternalReference,
+		DismissReason:   a.DismissReason,
+		RepoName:        fields.Repository.Name,
+		Event:           event,
+	}
+	if fields.Repository.HTMLURL != "" {
+		msg.URL = fields.Repository.HTMLURL + "/network/alerts"
+	}
+	if a.Dismisser != nil {
+		msg.DismissedBy = a.Dismisser.Login
+	}
+	if err := g.Notifier.NotifySecurityAlert(msg); err != nil {
+		log.Error("github", zap.String("event", "repository_vulnerability_alert"), zap.String("action", fields.Action), zap.Error(err))
+	}
+}
+
+// security advisories are not bound to repository, so they are posted to security channel only.
+func (g *Github) handleSecurityAdvisory(event *app.GithubEvent, body []byte) {
+	var fields securityAdvisoryFields
+	if err := json.Unmarshal(body, &fields); err != nil {
+		log.Error("github/decode security_advisory", zap.Error(err))
+		return
+	}
+	state := app.SecurityAlertOpen
+	switch fields.Action {
+	case "published", "updated":
+	case "withdrawn":
+		state = app.SecurityAlertWithdrawn
+	default:
+		log.Info("github/receive undefined action", zap.String("event", "security_advisory"), zap.String("action", fields.Action))
+		return
+	}
+	log.Info("github/handle event", zap.String("event", "security_advisory"), zap.String("action", fields.Action))
+
+	adv := fields.SecurityAdvisory
+	msg := &app.SecurityAlertMsg{
+		Key:        "advisory:" + adv.identifier(),
+		Action:     fields.Action,
+		State:      state,
+		Severity:   adv.Severity,
+		Identifier: adv.identifier(),
+		Summary:    adv.Summary,
+		URL:        adv.HTMLURL,
+		Event:      event,
+	}
+	if msg.URL == "" && len(adv.References) > 0 {
+		msg.URL = adv.References[0].URL
+	}
+	var packages, ranges, patched []string
+	for i := range adv.Vulnerabilities {
+		v := &adv.Vulnerabilities[i]
+		packages = append(packages, v.Package.Name)
+		ranges = append(ranges, v.Package.Name+" "+v.VulnerableVersionRange)
+		if p := v.patchedVersion(); p != "" {
+			patched = append(patched, v.Package.Name+" "+p)
+		}
+		msg.Ecosystem = v.Package.Ecosystem
+	}
+	msg.Package = strings.Join(packages, ", ")
+	msg.VulnerableRange = strings.Join(ranges, ", ")
+	msg.PatchedVersion = strings.Join(patched, ", ")
+
+	if err := g.Notifier.NotifySecurityAlert(msg); err != nil {
+		log.Error("github", zap.String("event", "security_advisory"), zap.String("action", fields.Action), zap.Error(err))
+	}
+}
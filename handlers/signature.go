@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha1" // nolint:gosec
+	"crypto/sha256"
+	"encoding/hex"
+	"expvar"
+	"hash"
+	"net/http"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+const (
+	signature256Header = "X-Hub-Signature-256"
+	signatureHeader    = "X-Hub-Signature"
+)
+
+var webhookMetrics = expvar.NewMap("github_webhook")
+
+// SignatureVerifier verifies github webhook signatures.
+// multiple secrets are accepted so that secrets can be rotated without downtime.
+type SignatureVerifier struct {
+	Secrets [][]byte
+}
+
+// NewSignatureVerifier creates verifier from comma separated secrets.
+func NewSignatureVerifier(secrets string) (*SignatureVerifier, error) {
+	v := &SignatureVerifier{}
+	for _, secret := range strings.Split(secrets, ",") {
+		if secret = strings.TrimSpace(secret); secret != "" {
+			v.Secrets = append(v.Secrets, []byte(secret))
+		}
+	}
+	if len(v.Secrets) == 0 {
+		return nil, errors.New("github webhook secret is empty")
+	}
+	return v, nil
+}
+
+// Verify checks X-Hub-Signature-256 and falls back to X-Hub-Signature(sha1) when sha256 signature is not sent.
+func (v *SignatureVerifier) Verify(header http.Header, body []byte) error {
+	if sig := header.Get(signature256Header); sig != "" {
+		return v.verify(sig, "sha256=", sha256.New, body)
+	}
+	if sig := header.Get(signatureHeader); sig != "" {
+		return v.verify(sig, "sha1=", sha1.New, body)
+	}
+	return errors.New("signature header is missing")
+}
+
+func (v *SignatureVerifier) verify(signature, prefix string, h func() hash.Hash, body []byte) error {
+	if !strings.HasPrefix(signature, prefix) {
+		return errors.Errorf("unexpected signature format %q", signature)
+	}
+	sig, err := hex.DecodeString(strings.TrimPrefix(signature, prefix))
+	if err != nil {
+		return errors.Annotate(err, "signature is not hex encoded")
+	}
+	for _, secret := range v.Secrets {
+		mac := hmac.New(h, secret)
+		mac.Write(body)
+		if hmac.Equal(sig, mac.Sum(nil)) {
+			webhookMetrics.Add("signature_verified_"+strings.TrimSuffix(prefix, "="), 1)
+			return nil
+		}
+	}
+	return errors.Errorf("%s signature mismatch", strings.TrimSuffix(prefix, "="))
+}
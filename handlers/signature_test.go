@@ -0,0 +1,76 @@
+package handlers_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha1" // nolint:gosec
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"net/http"
+	"testing"
+
+	"github.com/ymgyt/gobot/handlers"
+)
+
+func sign(h func() hash.Hash, secret string, body []byte) string {
+	mac := hmac.New(h, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestSignatureVerifier_Verify(t *testing.T) {
+	body := []byte(`{"action":"opened"}`)
+	v, err := handlers.NewSignatureVerifier("new-secret, old-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		header http.Header
+		ok     bool
+	}{
+		"sha256": {
+			header: http.Header{"X-Hub-Signature-256": {"sha256=" + sign(sha256.New, "new-secret", body)}},
+			ok:     true,
+		},
+		"sha256 with rotated secret": {
+			header: http.Header{"X-Hub-Signature-256": {"sha256=" + sign(sha256.New, "old-secret", body)}},
+			ok:     true,
+		},
+		"sha1 fallback": {
+			header: http.Header{"X-Hub-Signature": {"sha1=" + sign(sha1.New, "old-secret", body)}},
+			ok:     true,
+		},
+		"sha256 is preferred": {
+			header: http.Header{
+				"X-Hub-Signature-256": {"sha256=" + sign(sha256.New, "unknown", body)},
+				"X-Hub-Signature":     {"sha1=" + sign(sha1.New, "new-secret", body)},
+			},
+			ok: false,
+		},
+		"unknown secret": {
+			header: http.Header{"X-Hub-Signature-256": {"sha256=" + sign(sha256.New, "unknown", body)}},
+			ok:     false,
+		},
+		"algorithm mismatch": {
+			header: http.Header{"X-Hub-Signature-256": {"sha1=" + sign(sha1.New, "new-secret", body)}},
+			ok:     false,
+		},
+		"missing": {
+			header: http.Header{},
+			ok:     false,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := v.Verify(tc.header, body)
+			if tc.ok && err != nil {
+				t.Errorf("want ok, got %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Error("want error, got nil")
+			}
+		})
+	}
+}
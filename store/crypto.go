@@ -0,0 +1,123 @@
+package store
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+
+	"github.com/juju/errors"
+)
+
+const (
+	cipherKeySize = 32 // AES-256
+
+	blindIndexKeyContext = "gobot/blind-index"
+)
+
+// Cipher encrypts personal data with envelope encryption.
+// every value is sealed by a random data key, and the data key is sealed by the master key.
+// the master key can not be rotated. blind index key is derived from it and values do not record key id.
+type Cipher struct {
+	masterKey     []byte
+	blindIndexKey []byte
+}
+
+// EncryptedValue is a sealed value stored in mongo.
+type EncryptedValue struct {
+	DataKey    []byte `bson:"dk"` // data key sealed by master key. nonce || ciphertext
+	Ciphertext []byte `bson:"ct"` // value sealed by data key. nonce || ciphertext
+}
+
+// NewCipher creates Cipher from base64 encoded 32 bytes master key.
+func NewCipher(encodedKey string) (*Cipher, error) {
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, errors.Annotate(err, "encryption key must be base64 encoded")
+	}
+	if len(key) != cipherKeySize {
+		return nil, errors.Errorf("encryption key must be %d bytes, got %d bytes", cipherKeySize, len(key))
+	}
+
+	// derive a separate key for blind index so that index values reveal nothing about the master key.
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(blindIndexKeyContext))
+
+	return &Cipher{masterKey: key, blindIndexKey: mac.Sum(nil)}, nil
+}
+
+// Encrypt seals plaintext with a new data key.
+func (c *Cipher) Encrypt(plaintext string) (*EncryptedValue, error) {
+	dataKey := make([]byte, cipherKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, errors.Trace(err)
+	}
+	ciphertext, err := seal(dataKey, []byte(plaintext))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	sealedKey, err := seal(c.masterKey, dataKey)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &EncryptedValue{DataKey: sealedKey, Ciphertext: ciphertext}, nil
+}
+
+// Decrypt -
+func (c *Cipher) Decrypt(v *EncryptedValue) (string, error) {
+	dataKey, err := open(c.masterKey, v.DataKey)
+	if err != nil {
+		return "", errors.Annotate(err, "failed to open data key")
+	}
+	plaintext, err := open(dataKey, v.Ciphertext)
+	if err != nil {
+		return "", errors.Annotate(err, "failed to open value")
+	}
+	return string(plaintext), nil
+}
+
+// BlindIndex returns deterministic keyed hash of value to look up encrypted fields by equality.
+// value is case sensitive as well as lookups of plaintext fields.
+func (c *Cipher) BlindIndex(value string) string {
+	mac := hmac.New(sha256.New, c.blindIndexKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func seal(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(key, sealed []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("sealed value too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	return plaintext, errors.Trace(err)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	return gcm, errors.Trace(err)
+}
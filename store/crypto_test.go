@@ -0,0 +1,68 @@
+package store_test
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+
+	"github.com/ymgyt/gobot/store"
+)
+
+func newTestCipher(t *testing.T) *store.Cipher {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	c, err := store.NewCipher(base64.StdEncoding.EncodeToString(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestCipher_EncryptDecrypt(t *testing.T) {
+	c := newTestCipher(t)
+	email := "ymgyt@example.com"
+
+	encrypted, err := c.Encrypt(email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(encrypted.Ciphertext) == email {
+		t.Fatal("value should be encrypted")
+	}
+	got, err := c.Decrypt(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != email {
+		t.Errorf("got %s, want %s", got, email)
+	}
+
+	if _, err := newTestCipher(t).Decrypt(encrypted); err == nil {
+		t.Error("value should not be decrypted by other key")
+	}
+}
+
+func TestCipher_BlindIndex(t *testing.T) {
+	c := newTestCipher(t)
+	if c.BlindIndex("ymgyt@example.com") != c.BlindIndex("ymgyt@example.com") {
+		t.Error("blind index should be deterministic")
+	}
+	// plaintext emails are looked up case sensitively too.
+	if c.BlindIndex("ymgyt@example.com") == c.BlindIndex("YMGYT@example.com") {
+		t.Error("blind index should be case sensitive")
+	}
+	if c.BlindIndex("ymgyt@example.com") == c.BlindIndex("other@example.com") {
+		t.Error("blind index should differ for different values")
+	}
+	if c.BlindIndex("ymgyt@example.com") == newTestCipher(t).BlindIndex("ymgyt@example.com") {
+		t.Error("blind index should depend on key")
+	}
+}
+
+func TestNewCipher_InvalidKey(t *testing.T) {
+	if _, err := store.NewCipher(base64.StdEncoding.EncodeToString([]byte("short"))); err == nil {
+		t.Error("short key should be rejected")
+	}
+}
@@ -0,0 +1,91 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/juju/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+
+	"github.com/ymgyt/gobot/log"
+)
+
+const (
+	deduplicationCollection = "deduplications"
+
+	deduplicationTimeout = 3 * time.Second
+)
+
+// Deduplications implements app.Deduplicator backed by mongo.
+// records are removed by TTL index after they expire.
+type Deduplications struct {
+	*Mongo
+	Now func() time.Time
+}
+
+// EnsureIndexes creates TTL index.
+func (d *Deduplications) EnsureIndexes(ctx context.Context) error {
+	_, err := d.collection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expire_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return errors.Annotate(err, "create ttl index")
+}
+
+// CheckDuplicateNotification returns true when eventKey is not seen within d.
+// on mongo failure, it returns true because dropping notifications is worse than duplicating them.
+func (d *Deduplications) CheckDuplicateNotification(eventKey string, window time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), deduplicationTimeout)
+	defer cancel()
+
+	// filter matches only expired record, so upsert fails with duplicate key error while the record is alive.
+	// this keeps check and record atomic across replicas.
+	now := d.Now()
+	_, err := d.collection().UpdateOne(ctx,
+		bson.D{
+			{Key: "_id", Value: eventKey},
+			{Key: "expire_at", Value: bson.D{{Key: "$lte", Value: now}}},
+		},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "seen_at", Value: now},
+			{Key: "expire_at", Value: now.Add(window)},
+		}}},
+		options.Update().SetUpsert(true))
+	if isDuplicateKeyError(err) {
+		return false
+	}
+	if err != nil {
+		log.Warn("deduplication", zap.String("key", eventKey), zap.Error(err))
+	}
+	return true
+}
+
+// Forget deletes record of eventKey. failure is logged because the record expires anyway.
+func (d *Deduplications) Forget(eventKey string) {
+	ctx, cancel := context.WithTimeout(context.Background(), deduplicationTimeout)
+	defer cancel()
+	if _, err := d.collection().DeleteOne(ctx, bson.D{{Key: "_id", Value: eventKey}}); err != nil {
+		log.Warn("deduplication/forget", zap.String("key", eventKey), zap.Error(err))
+	}
+}
+
+func (d *Deduplications) collection() *mongo.Collection {
+	return d.Mongo.Collection(deduplicationCollection)
+}
+
+func isDuplicateKeyError(err error) bool {
+	const duplicateKeyCode = 11000
+	we, ok := errors.Cause(err).(mongo.WriteException)
+	if !ok {
+		return false
+	}
+	for _, e := range we.WriteErrors {
+		if e.Code == duplicateKeyCode {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/juju/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	leaseCollection = "leases"
+)
+
+// Leases implements app.LeaseStore.
+type Leases struct {
+	*Mongo
+	Now func() time.Time
+}
+
+// EnsureIndexes creates TTL index for expired leases.
+func (l *Leases) EnsureIndexes(ctx context.Context) error {
+	_, err := l.collection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expire_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return errors.Annotate(err, "create lease ttl index")
+}
+
+// AcquireLease acquires lease by upsert. filter matches only expired lease,
+// so upsert fails with duplicate key error while another replica holds the lease.
+func (l *Leases) AcquireLease(ctx context.Context, key string, d time.Duration) (bool, error) {
+	now := l.Now()
+	_, err := l.collection().UpdateOne(ctx,
+		bson.D{
+			{Key: "_id", Value: key},
+			{Key: "expire_at", Value: bson.D{{Key: "$lte", Value: now}}},
+		},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "acquired_at", Value: now},
+			{Key: "expire_at", Value: now.Add(d)},
+		}}},
+		options.Update().SetUpsert(true))
+	if isDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, errors.Annotatef(err, "lease %s", key)
+}
+
+func (l *Leases) collection() *mongo.Collection {
+	return l.Mongo.Collection(leaseCollection)
+}
@@ -14,6 +14,9 @@ import (
 
 var defaultConnectionTimeout = 3 * time.Second
 
+// DefaultTimeout is used for setup operations like index creation.
+const DefaultTimeout = 10 * time.Second
+
 type Mongo struct {
 	*mongo.Client
 	database string
@@ -0,0 +1,223 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/juju/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/ymgyt/gobot/app"
+)
+
+const (
+	outboxCollection = "outbox"
+	// locks serialize posting messages of the same update key.
+	outboxLockCollection = "outbox_locks"
+
+	// sent messages are kept for a while to investigate notifications.
+	outboxSentRetention = 7 * 24 * time.Hour
+)
+
+// Outbox implements app.OutboxStore.
+type Outbox struct {
+	*Mongo
+	Now func() time.Time
+}
+
+// EnsureIndexes creates index for claiming and TTL index for sent messages and locks.
+func (o *Outbox) EnsureIndexes(ctx context.Context) error {
+	_, err := o.collection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}}},
+		{Keys: bson.D{{Key: "expire_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		{Keys: bson.D{{Key: "thread_key", Value: 1}, {Key: "channel", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "update_key", Value: 1}, {Key: "channel", Value: 1}}, Options: options.Index().SetSparse(true)},
+	})
+	if err != nil {
+		return errors.Annotate(err, "create outbox indexes")
+	}
+	_, err = o.locks().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expire_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return errors.Annotate(err, "create outbox lock ttl index")
+}
+
+func (o *Outbox) Enqueue(ctx context.Context, msg *app.OutboxMessage) error {
+	_, err := o.collection().InsertOne(ctx, msg)
+	return errors.Annotatef(err, "outbox id=%s", msg.ID)
+}
+
+func (o *Outbox) Claim(ctx context.Context, lease time.Duration) (*app.OutboxMessage, error) {
+	now := o.Now()
+	result := o.collection().FindOneAndUpdate(ctx,
+		bson.D{
+			{Key: "status", Value: app.OutboxPending},
+			{Key: "next_attempt_at", Value: bson.D{{Key: "$lte", Value: now}}},
+		},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "next_attempt_at", Value: now.Add(lease)},
+			{Key: "updated_at", Value: now},
+		}}},
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+			SetReturnDocument(options.After))
+
+	var msg app.OutboxMessage
+	if err := result.Decode(&msg); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, errors.Annotate(err, "claim outbox message")
+	}
+	return &msg, nil
+}
+
+func (o *Outbox) MarkSent(ctx context.Context, id, channel, ts string) error {
+	now := o.Now()
+	_, err := o.collection().UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: id}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "status", Value: app.OutboxSent},
+			{Key: "posted_channel", Value: channel},
+			{Key: "posted_ts", Value: ts},
+			{Key: "updated_at", Value: now},
+			{Key: "expire_at", Value: now.Add(outboxSentRetention)},
+		}}})
+	return errors.Annotatef(err, "outbox id=%s", id)
+}
+
+func (o *Outbox) MarkFailed(ctx context.Context, input *app.MarkOutboxFailedInput) error {
+	status := app.OutboxPending
+	if input.Dead {
+		status = app.OutboxDead
+	}
+	_, err := o.collection().UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: input.ID}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "status", Value: status},
+			{Key: "attempts", Value: input.Attempts},
+			{Key: "next_attempt_at", Value: input.NextAttemptAt},
+			{Key: "last_error", Value: input.LastError},
+			{Key: "updated_at", Value: o.Now()},
+		}}})
+	return errors.Annotatef(err, "outbox id=%s", input.ID)
+}
+
+func (o *Outbox) ListOutbox(ctx context.Context, input *app.ListOutboxInput) (app.OutboxMessages, error) {
+	filter := bson.D{}
+	if input.Status != "" {
+		filter = append(filter, primitive.E{Key: "status", Value: input.Status})
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	if input.Limit > 0 {
+		opts.SetLimit(input.Limit)
+	}
+
+	cur, err := o.collection().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, errors.Annotatef(err, "input=%v", input)
+	}
+	defer cur.Close(ctx)
+
+	msgs := app.OutboxMessages{}
+	for cur.Next(ctx) {
+		var msg app.OutboxMessage
+		if err := cur.Decode(&msg); err != nil {
+			return nil, errors.Annotate(err, "failed to decode outbox message")
+		}
+		msgs = append(msgs, &msg)
+	}
+	return msgs, errors.Trace(cur.Err())
+}
+
+func (o *Outbox) Retry(ctx context.Context, id string) error {
+	now := o.Now()
+	result, err := o.collection().UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: id}, {Key: "status", Value: app.OutboxDead}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "status", Value: app.OutboxPending},
+			{Key: "attempts", Value: 0},
+			{Key: "next_attempt_at", Value: now},
+			{Key: "updated_at", Value: now},
+		}}})
+	if err != nil {
+		return errors.Annotatef(err, "outbox id=%s", id)
+	}
+	if result.MatchedCount == 0 {
+		return errors.Errorf("dead outbox message %s not found", id)
+	}
+	return nil
+}
+
+// ThreadTS finds thread from sent messages. thread is lost after sent messages expire.
+func (o *Outbox) ThreadTS(ctx context.Context, channel, threadKey string) (string, error) {
+	var msg app.OutboxMessage
+	err := o.collection().FindOne(ctx,
+		bson.D{
+			{Key: "thread_key", Value: threadKey},
+			{Key: "channel", Value: channel},
+			{Key: "reply", Value: bson.D{{Key: "$ne", Value: true}}},
+			{Key: "status", Value: app.OutboxSent},
+		},
+		options.FindOne().SetSort(bson.D{{Key: "created_at", Value: 1}})).Decode(&msg)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return "", nil
+		}
+		return "", errors.Annotatef(err, "thread_key=%s channel=%s", threadKey, channel)
+	}
+	return msg.PostedTS, nil
+}
+
+func (o *Outbox) PostedMessage(ctx context.Context, channel, updateKey string) (*app.OutboxMessage, error) {
+	var msg app.OutboxMessage
+	err := o.collection().FindOne(ctx,
+		bson.D{
+			{Key: "update_key", Value: updateKey},
+			{Key: "channel", Value: channel},
+			{Key: "status", Value: app.OutboxSent},
+		},
+		options.FindOne().SetSort(bson.D{{Key: "update_version", Value: -1}, {Key: "created_at", Value: 1}})).Decode(&msg)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, errors.Annotatef(err, "update_key=%s channel=%s", updateKey, channel)
+	}
+	return &msg, nil
+}
+
+// LockUpdateKey acquires lock by upsert. filter matches only expired lock,
+// so upsert fails with duplicate key error while another sender holds the lock.
+func (o *Outbox) LockUpdateKey(ctx context.Context, channel, updateKey string, lease time.Duration) (bool, error) {
+	now := o.Now()
+	_, err := o.locks().UpdateOne(ctx,
+		bson.D{
+			{Key: "_id", Value: lockID(channel, updateKey)},
+			{Key: "expire_at", Value: bson.D{{Key: "$lte", Value: now}}},
+		},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "locked_at", Value: now},
+			{Key: "expire_at", Value: now.Add(lease)},
+		}}},
+		options.Update().SetUpsert(true))
+	if isDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, errors.Annotatef(err, "update_key=%s channel=%s", updateKey, channel)
+}
+
+func (o *Outbox) UnlockUpdateKey(ctx context.Context, channel, updateKey string) error {
+	_, err := o.locks().DeleteOne(ctx, bson.D{{Key: "_id", Value: lockID(channel, updateKey)}})
+	return errors.Annotatef(err, "update_key=%s channel=%s", updateKey, channel)
+}
+
+func lockID(channel, updateKey string) string { return channel + "/" + updateKey }
+
+func (o *Outbox) collection() *mongo.Collection { return o.Mongo.Collection(outboxCollection) }
+
+func (o *Outbox) locks() *mongo.Collection { return o.Mongo.Collection(outboxLockCollection) }
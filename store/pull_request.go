@@ -0,0 +1,301 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+
+	"github.com/ymgyt/gobot/app"
+	"github.com/ymgyt/gobot/log"
+)
+
+const (
+	pullRequestCollection = "pull_requests"
+	// checks keyed by commit are kept until pull request of the commit is saved.
+	commitCheckCollection = "commit_checks"
+
+	// check often arrives before synchronize event, but not so long.
+	commitCheckRetention = 24 * time.Hour
+)
+
+// commitChecks are checks of a commit keyed by PullRequestCheck.Key.
+type commitChecks struct {
+	ID     string                           `bson:"_id"`
+	Checks map[string]*app.PullRequestCheck `bson:"checks"`
+}
+
+// PullRequests implements app.PullRequestStore.
+type PullRequests struct {
+	*Mongo
+	Now func() time.Time
+}
+
+// EnsureIndexes creates index to map commit to pull requests and TTL index for commit checks.
+func (p *PullRequests) EnsureIndexes(ctx context.Context) error {
+	_, err := p.collection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "repository", Value: 1}, {Key: "head_sha", Value: 1}}},
+		{Keys: bson.D{{Key: "state", Value: 1}, {Key: "requested_reviewers", Value: 1}}},
+		{Keys: bson.D{{Key: "state", Value: 1}, {Key: "author", Value: 1}}},
+	})
+	if err != nil {
+		return errors.Annotate(err, "create pull request indexes")
+	}
+	_, err = p.commitChecks().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expire_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return errors.Annotate(err, "create commit check ttl index")
+}
+
+func (p *PullRequests) GetPullRequest(ctx context.Context, id string) (*app.PullRequest, error) {
+	var pr app.PullRequest
+	err := p.collection().FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&pr)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.NotFoundf("pull request %s", id)
+		}
+		return nil, errors.Annotatef(err, "pull request %s", id)
+	}
+	return &pr, nil
+}
+
+// SavePullRequest updates fields of pull request. reviews, review requests, checks and reminders are kept.
+// events are delivered out of order, so pull request updated on github after the event is not overwritten.
+func (p *PullRequests) SavePullRequest(ctx context.Context, pr *app.PullRequest) error {
+	pr.Repository = strings.ToLower(pr.Repository)
+	pr.UpdatedAt = p.Now()
+	fields, err := setFields(pr, "_id", "reviews", "review_requested_at", "checks", "reminders")
+	if err != nil {
+		return errors.Annotatef(err, "pull request %s", pr.ID)
+	}
+	// filter does not match newer pull request, so upsert fails with duplicate key error.
+	// pull request created by SaveReview or SaveReviewRequest has no github_updated_at.
+	_, err = p.collection().UpdateOne(ctx,
+		bson.D{
+			{Key: "_id", Value: pr.ID},
+			{Key: "$or", Value: bson.A{
+				bson.D{{Key: "github_updated_at", Value: bson.D{{Key: "$lte", Value: pr.GithubUpdatedAt}}}},
+				bson.D{{Key: "github_updated_at", Value: bson.D{{Key: "$exists", Value: false}}}},
+			}},
+		},
+		bson.D{{Key: "$set", Value: fields}},
+		options.Update().SetUpsert(true))
+	if isDuplicateKeyError(err) {
+		return errors.Wrapf(err, app.ErrStalePullRequest, "pull request %s", pr.ID)
+	}
+	if err != nil {
+		return errors.Annotatef(err, "pull request %s", pr.ID)
+	}
+	return errors.Annotatef(p.applyCommitChecks(ctx, pr), "pull request %s", pr.ID)
+}
+
+// applyCommitChecks copies checks reported before head of pull request moved to the commit.
+func (p *PullRequests) applyCommitChecks(ctx context.Context, pr *app.PullRequest) error {
+	if pr.HeadSHA == "" {
+		return nil
+	}
+	var cc commitChecks
+	err := p.commitChecks().FindOne(ctx, bson.D{{Key: "_id", Value: commitCheckID(pr.Repository, pr.HeadSHA)}}).Decode(&cc)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return errors.Annotatef(err, "commit checks sha=%s", pr.HeadSHA)
+	}
+	fields := bson.D{}
+	for key, check := range cc.Checks {
+		fields = append(fields, bson.E{Key: "checks." + key, Value: check})
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	// head may have moved again in the meantime.
+	_, err = p.collection().UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: pr.ID}, {Key: "head_sha", Value: pr.HeadSHA}},
+		bson.D{{Key: "$set", Value: fields}})
+	return errors.Annotatef(err, "apply commit checks sha=%s", pr.HeadSHA)
+}
+
+// SaveReview saves latest review and last blocking review of reviewer separately.
+// review events are delivered out of order, so review submitted before saved one is ignored.
+func (p *PullRequests) SaveReview(ctx context.Context, id string, review *app.PullRequestReview) error {
+	field := "reviews." + review.Reviewer
+	now := p.Now()
+	// fields are set one by one to keep last_blocking.
+	err := p.saveReview(ctx, id, field, review.SubmittedAt, bson.D{
+		{Key: field + ".reviewer", Value: review.Reviewer},
+		{Key: field + ".state", Value: review.State},
+		{Key: field + ".url", Value: review.URL},
+		{Key: field + ".submitted_at", Value: review.SubmittedAt},
+		{Key: "updated_at", Value: now},
+	})
+	if err != nil || !app.IsBlockingReview(review.State) {
+		return errors.Annotatef(err, "pull request %s reviewer %s", id, review.Reviewer)
+	}
+	err = p.saveReview(ctx, id, field+".last_blocking", review.SubmittedAt, bson.D{
+		{Key: field + ".last_blocking", Value: review},
+		{Key: "updated_at", Value: now},
+	})
+	return errors.Annotatef(err, "pull request %s reviewer %s last blocking review", id, review.Reviewer)
+}
+
+// saveReview sets fields unless review saved at field is newer than submittedAt.
+func (p *PullRequests) saveReview(ctx context.Context, id, field string, submittedAt time.Time, fields bson.D) error {
+	// filter does not match newer review, so upsert fails with duplicate key error.
+	_, err := p.collection().UpdateOne(ctx,
+		bson.D{
+			{Key: "_id", Value: id},
+			{Key: "$or", Value: bson.A{
+				bson.D{{Key: field + ".submitted_at", Value: bson.D{{Key: "$lte", Value: submittedAt}}}},
+				bson.D{{Key: field + ".submitted_at", Value: bson.D{{Key: "$exists", Value: false}}}},
+			}},
+		},
+		bson.D{{Key: "$set", Value: fields}},
+		options.Update().SetUpsert(true))
+	if isDuplicateKeyError(err) {
+		log.Info("store/ignore stale review", zap.String("pull_request", id), zap.String("field", field))
+		return nil
+	}
+	return errors.Trace(err)
+}
+
+func (p *PullRequests) SaveReviewRequest(ctx context.Context, id, reviewer string, at time.Time) error {
+	_, err := p.collection().UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: id}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "review_requested_at." + reviewer, Value: at},
+			{Key: "updated_at", Value: p.Now()},
+		}}},
+		options.Update().SetUpsert(true))
+	return errors.Annotatef(err, "pull request %s reviewer %s", id, reviewer)
+}
+
+func (p *PullRequests) SaveReminder(ctx context.Context, id, reviewer string, state *app.ReviewReminderState) error {
+	_, err := p.collection().UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: id}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "reminders." + reviewer, Value: state},
+		}}})
+	return errors.Annotatef(err, "pull request %s reviewer %s", id, reviewer)
+}
+
+// ClaimReminder sets time of reminder or escalation only when it has not been sent since review was requested.
+func (p *PullRequests) ClaimReminder(ctx context.Context, id, reviewer string, action app.ReminderAction, since, at time.Time) (bool, error) {
+	field := "reminders." + reviewer
+	// missing field also matches.
+	notSince := bson.D{{Key: "$not", Value: bson.D{{Key: "$gte", Value: since}}}}
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: field + ".escalated_at", Value: notSince},
+	}
+	set := field + ".escalated_at"
+	if action == app.ReminderRemind {
+		filter = append(filter, bson.E{Key: field + ".reminded_at", Value: notSince})
+		set = field + ".reminded_at"
+	}
+	result, err := p.collection().UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: bson.D{{Key: set, Value: at}}}})
+	if err != nil {
+		return false, errors.Annotatef(err, "pull request %s reviewer %s", id, reviewer)
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// SaveCheck does not create pull request because commits of untracked pull requests are unknown.
+// check is also kept by commit, so pull request whose head moves to the commit later gets it on SavePullRequest.
+func (p *PullRequests) SaveCheck(ctx context.Context, repository string, check *app.PullRequestCheck) error {
+	check.UpdatedAt = p.Now()
+	_, err := p.commitChecks().UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: commitCheckID(repository, check.SHA)}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "checks." + check.Key(), Value: check},
+			{Key: "expire_at", Value: check.UpdatedAt.Add(commitCheckRetention)},
+		}}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return errors.Annotatef(err, "repository=%s sha=%s check=%s", repository, check.SHA, check.Name)
+	}
+	_, err = p.collection().UpdateMany(ctx,
+		bson.D{
+			{Key: "repository", Value: strings.ToLower(repository)},
+			{Key: "head_sha", Value: check.SHA},
+			{Key: "state", Value: app.PullRequestOpen},
+		},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "checks." + check.Key(), Value: check},
+			{Key: "updated_at", Value: check.UpdatedAt},
+		}}})
+	return errors.Annotatef(err, "repository=%s sha=%s check=%s", repository, check.SHA, check.Name)
+}
+
+// setFields converts document to $set value without excluded keys.
+func setFields(doc interface{}, excludes ...string) (bson.M, error) {
+	b, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var fields bson.M
+	if err := bson.Unmarshal(b, &fields); err != nil {
+		return nil, errors.Trace(err)
+	}
+	for _, key := range excludes {
+		delete(fields, key)
+	}
+	return fields, nil
+}
+
+func (p *PullRequests) ListOpenPullRequests(ctx context.Context, input *app.ListPullRequestsInput) (app.PullRequests, error) {
+	filter := bson.D{{Key: "state", Value: app.PullRequestOpen}}
+	if input.RequestedReviewer != "" {
+		filter = append(filter, bson.E{Key: "requested_reviewers", Value: input.RequestedReviewer})
+	}
+	if input.Author != "" {
+		filter = append(filter, bson.E{Key: "author", Value: input.Author})
+	}
+	prs, err := p.find(ctx, filter)
+	return prs, errors.Annotatef(err, "input=%v", input)
+}
+
+func (p *PullRequests) FindPullRequestsByHeadSHA(ctx context.Context, repository, sha string) (app.PullRequests, error) {
+	prs, err := p.find(ctx, bson.D{
+		{Key: "repository", Value: strings.ToLower(repository)},
+		{Key: "head_sha", Value: sha},
+		{Key: "state", Value: app.PullRequestOpen},
+	})
+	return prs, errors.Annotatef(err, "repository=%s sha=%s", repository, sha)
+}
+
+func (p *PullRequests) find(ctx context.Context, filter bson.D) (app.PullRequests, error) {
+	cur, err := p.collection().Find(ctx, filter)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer cur.Close(ctx)
+
+	prs := app.PullRequests{}
+	for cur.Next(ctx) {
+		var pr app.PullRequest
+		if err := cur.Decode(&pr); err != nil {
+			return nil, errors.Annotate(err, "failed to decode pull request")
+		}
+		prs = append(prs, &pr)
+	}
+	return prs, errors.Trace(cur.Err())
+}
+
+func (p *PullRequests) collection() *mongo.Collection {
+	return p.Mongo.Collection(pullRequestCollection)
+}
+
+func (p *PullRequests) commitChecks() *mongo.Collection {
+	return p.Mongo.Collection(commitCheckCollection)
+}
+
+func commitCheckID(repository, sha string) string {
+	return strings.ToLower(repository) + "@" + sha
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/juju/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/ymgyt/gobot/app"
+)
+
+const (
+	repositorySettingCollection = "repository_settings"
+)
+
+// RepositorySettings implements app.RepositorySettingStore.
+type RepositorySettings struct {
+	*Mongo
+	Now func() time.Time
+}
+
+func (r *RepositorySettings) GetRepositorySetting(ctx context.Context, repository string) (*app.RepositorySetting, error) {
+	var setting app.RepositorySetting
+	err := r.collection().FindOne(ctx, bson.D{{Key: "_id", Value: repository}}).Decode(&setting)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.NotFoundf("setting of %s", repository)
+		}
+		return nil, errors.Annotatef(err, "repository=%s", repository)
+	}
+	return &setting, nil
+}
+
+func (r *RepositorySettings) SaveRepositorySetting(ctx context.Context, setting *app.RepositorySetting) error {
+	setting.UpdatedAt = r.Now()
+	_, err := r.collection().ReplaceOne(ctx,
+		bson.D{{Key: "_id", Value: setting.Repository}},
+		setting,
+		options.Replace().SetUpsert(true))
+	return errors.Annotatef(err, "repository=%s", setting.Repository)
+}
+
+func (r *RepositorySettings) ListRepositorySettings(ctx context.Context) (app.RepositorySettings, error) {
+	cur, err := r.collection().Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, errors.Annotate(err, "list repository settings")
+	}
+	defer cur.Close(ctx)
+
+	settings := app.RepositorySettings{}
+	for cur.Next(ctx) {
+		var setting app.RepositorySetting
+		if err := cur.Decode(&setting); err != nil {
+			return nil, errors.Annotate(err, "failed to decode repository setting")
+		}
+		settings = append(settings, &setting)
+	}
+	return settings, errors.Trace(cur.Err())
+}
+
+func (r *RepositorySettings) collection() *mongo.Collection {
+	return r.Mongo.Collection(repositorySettingCollection)
+}
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/juju/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/ymgyt/gobot/app"
+)
+
+const (
+	ruleCollection = "rules"
+)
+
+// Rules implements app.RuleStore.
+type Rules struct {
+	*Mongo
+	Now func() time.Time
+}
+
+func (r *Rules) AddRule(ctx context.Context, rule *app.Rule) error {
+	if rule.CreatedAt.IsZero() {
+		rule.CreatedAt = r.Now()
+	}
+	_, err := r.collection().InsertOne(ctx, rule)
+	return errors.Annotatef(err, "rule id=%s", rule.ID)
+}
+
+func (r *Rules) ListRules(ctx context.Context) (app.Rules, error) {
+	cur, err := r.collection().Find(ctx, bson.D{},
+		options.Find().SetSort(bson.D{{Key: "priority", Value: -1}, {Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer cur.Close(ctx)
+
+	rules := app.Rules{}
+	for cur.Next(ctx) {
+		var rule app.Rule
+		if err := cur.Decode(&rule); err != nil {
+			return nil, errors.Annotate(err, "failed to decode rule")
+		}
+		rules = append(rules, &rule)
+	}
+	return rules, errors.Trace(cur.Err())
+}
+
+func (r *Rules) DeleteRule(ctx context.Context, id string) error {
+	result, err := r.collection().DeleteOne(ctx, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		return errors.Annotatef(err, "rule id=%s", id)
+	}
+	if result.DeletedCount == 0 {
+		return errors.NotFoundf("rule %s", id)
+	}
+	return nil
+}
+
+func (r *Rules) collection() *mongo.Collection {
+	return r.Mongo.Collection(ruleCollection)
+}
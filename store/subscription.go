@@ -0,0 +1,126 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/juju/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/ymgyt/gobot/app"
+)
+
+const (
+	subscriptionCollection = "subscriptions"
+)
+
+// Subscriptions implements app.SubscriptionStore.
+type Subscriptions struct {
+	*Mongo
+	Now func() time.Time
+}
+
+// EnsureIndexes creates unique index of channel and repository, and index for fan out lookup.
+func (s *Subscriptions) EnsureIndexes(ctx context.Context) error {
+	_, err := s.collection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "channel", Value: 1}, {Key: "repository", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "repository", Value: 1}, {Key: "categories", Value: 1}}},
+	})
+	return errors.Annotate(err, "create subscription indexes")
+}
+
+func (s *Subscriptions) Subscribe(ctx context.Context, sub *app.Subscription) error {
+	now := s.Now()
+	addToSet := bson.D{{Key: "categories", Value: bson.D{{Key: "$each", Value: sub.Categories}}}}
+	if len(sub.Environments) > 0 {
+		addToSet = append(addToSet, primitive.E{Key: "environments", Value: bson.D{{Key: "$each", Value: sub.Environments}}})
+	}
+	_, err := s.collection().UpdateOne(ctx,
+		bson.D{{Key: "channel", Value: sub.Channel}, {Key: "repository", Value: sub.Repository}},
+		bson.D{
+			{Key: "$addToSet", Value: addToSet},
+			{Key: "$set", Value: bson.D{{Key: "updated_at", Value: now}}},
+			{Key: "$setOnInsert", Value: bson.D{
+				{Key: "created_by", Value: sub.CreatedBy},
+				{Key: "created_at", Value: now},
+			}},
+		},
+		options.Update().SetUpsert(true))
+	return errors.Annotatef(err, "channel=%s repository=%s", sub.Channel, sub.Repository)
+}
+
+func (s *Subscriptions) Unsubscribe(ctx context.Context, channel, repository string, categories []app.EventCategory) error {
+	filter := bson.D{{Key: "channel", Value: channel}, {Key: "repository", Value: repository}}
+	result, err := s.collection().UpdateOne(ctx, filter,
+		bson.D{
+			{Key: "$pullAll", Value: bson.D{{Key: "categories", Value: categories}}},
+			{Key: "$set", Value: bson.D{{Key: "updated_at", Value: s.Now()}}},
+		})
+	if err != nil {
+		return errors.Annotatef(err, "channel=%s repository=%s", channel, repository)
+	}
+	if result.MatchedCount == 0 {
+		return errors.NotFoundf("subscription of %s in this channel", repository)
+	}
+
+	_, err = s.collection().DeleteOne(ctx, append(filter, primitive.E{Key: "categories", Value: bson.D{{Key: "$size", Value: 0}}}))
+	return errors.Annotatef(err, "channel=%s repository=%s", channel, repository)
+}
+
+func (s *Subscriptions) ListSubscriptions(ctx context.Context, input *app.ListSubscriptionsInput) (app.Subscriptions, error) {
+	filter := bson.D{}
+	if input.Channel != "" {
+		filter = append(filter, primitive.E{Key: "channel", Value: input.Channel})
+	}
+	if input.Repository != "" {
+		filter = append(filter, primitive.E{Key: "repository", Value: input.Repository})
+	}
+	return s.find(ctx, filter, options.Find().SetSort(bson.D{{Key: "repository", Value: 1}, {Key: "channel", Value: 1}}))
+}
+
+func (s *Subscriptions) SubscribedChannels(ctx context.Context, repository string, category app.EventCategory, environment string) ([]string, error) {
+	filter := bson.D{{Key: "repository", Value: repository}, {Key: "categories", Value: category}}
+	if environment != "" {
+		filter = append(filter, primitive.E{Key: "$or", Value: bson.A{
+			bson.D{{Key: "environments", Value: environment}},
+			bson.D{{Key: "environments", Value: bson.D{{Key: "$exists", Value: false}}}},
+		}})
+	}
+	subs, err := s.find(ctx, filter, options.Find())
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	channels := make([]string, len(subs))
+	for i := range subs {
+		channels[i] = subs[i].Channel
+	}
+	return channels, nil
+}
+
+func (s *Subscriptions) find(ctx context.Context, filter bson.D, opts *options.FindOptions) (app.Subscriptions, error) {
+	cur, err := s.collection().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, errors.Annotatef(err, "filter=%v", filter)
+	}
+	defer cur.Close(ctx)
+
+	subs := app.Subscriptions{}
+	for cur.Next(ctx) {
+		var sub app.Subscription
+		if err := cur.Decode(&sub); err != nil {
+			return nil, errors.Annotate(err, "failed to decode subscription")
+		}
+		subs = append(subs, &sub)
+	}
+	return subs, errors.Trace(cur.Err())
+}
+
+func (s *Subscriptions) collection() *mongo.Collection {
+	return s.Mongo.Collection(subscriptionCollection)
+}
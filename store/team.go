@@ -0,0 +1,66 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/juju/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/ymgyt/gobot/app"
+)
+
+const (
+	teamCollection = "teams"
+)
+
+// Teams implements app.TeamStore.
+type Teams struct {
+	*Mongo
+	Now func() time.Time
+}
+
+func (t *Teams) GetTeam(ctx context.Context, slug string) (*app.Team, error) {
+	var team app.Team
+	err := t.collection().FindOne(ctx, bson.D{{Key: "_id", Value: slug}}).Decode(&team)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.NotFoundf("team %s", slug)
+		}
+		return nil, errors.Annotatef(err, "slug=%s", slug)
+	}
+	return &team, nil
+}
+
+func (t *Teams) SaveTeam(ctx context.Context, team *app.Team) error {
+	team.UpdatedAt = t.Now()
+	_, err := t.collection().ReplaceOne(ctx,
+		bson.D{{Key: "_id", Value: team.Slug}},
+		team,
+		options.Replace().SetUpsert(true))
+	return errors.Annotatef(err, "slug=%s", team.Slug)
+}
+
+func (t *Teams) ListTeams(ctx context.Context) (app.Teams, error) {
+	cur, err := t.collection().Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, errors.Annotate(err, "list teams")
+	}
+	defer cur.Close(ctx)
+
+	teams := app.Teams{}
+	for cur.Next(ctx) {
+		var team app.Team
+		if err := cur.Decode(&team); err != nil {
+			return nil, errors.Annotate(err, "failed to decode team")
+		}
+		teams = append(teams, &team)
+	}
+	return teams, errors.Trace(cur.Err())
+}
+
+func (t *Teams) collection() *mongo.Collection {
+	return t.Mongo.Collection(teamCollection)
+}
@@ -0,0 +1,91 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/juju/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/ymgyt/gobot/app"
+)
+
+const (
+	templateCollection = "notification_templates"
+)
+
+// Templates implements app.TemplateStore.
+type Templates struct {
+	*Mongo
+	Now func() time.Time
+}
+
+// EnsureIndexes creates unique index of kind, repository and channel.
+func (t *Templates) EnsureIndexes(ctx context.Context) error {
+	_, err := t.collection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "kind", Value: 1}, {Key: "repository", Value: 1}, {Key: "channel", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return errors.Annotate(err, "create template indexes")
+}
+
+func (t *Templates) SetTemplate(ctx context.Context, tmpl *app.NotificationTemplate, field, text string) error {
+	_, err := t.collection().UpdateOne(ctx,
+		templateFilter(tmpl.Kind, tmpl.Repository, tmpl.Channel),
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "fields." + field, Value: text},
+			{Key: "updated_by", Value: tmpl.UpdatedBy},
+			{Key: "updated_at", Value: t.Now()},
+		}}},
+		options.Update().SetUpsert(true))
+	return errors.Annotatef(err, "template %s", tmpl)
+}
+
+func (t *Templates) ListTemplates(ctx context.Context, kind app.TemplateKind) (app.NotificationTemplates, error) {
+	filter := bson.D{}
+	if kind != "" {
+		filter = append(filter, primitive.E{Key: "kind", Value: kind})
+	}
+	cur, err := t.collection().Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "kind", Value: 1}, {Key: "repository", Value: 1}, {Key: "channel", Value: 1}}))
+	if err != nil {
+		return nil, errors.Annotatef(err, "kind=%s", kind)
+	}
+	defer cur.Close(ctx)
+
+	templates := app.NotificationTemplates{}
+	for cur.Next(ctx) {
+		var tmpl app.NotificationTemplate
+		if err := cur.Decode(&tmpl); err != nil {
+			return nil, errors.Annotate(err, "failed to decode template")
+		}
+		templates = append(templates, &tmpl)
+	}
+	return templates, errors.Trace(cur.Err())
+}
+
+func (t *Templates) DeleteTemplate(ctx context.Context, kind app.TemplateKind, repository, channel string) error {
+	result, err := t.collection().DeleteOne(ctx, templateFilter(kind, repository, channel))
+	if err != nil {
+		return errors.Annotatef(err, "kind=%s repository=%s channel=%s", kind, repository, channel)
+	}
+	if result.DeletedCount == 0 {
+		return errors.NotFoundf("template %s repository=%s channel=%s", kind, repository, channel)
+	}
+	return nil
+}
+
+func templateFilter(kind app.TemplateKind, repository, channel string) bson.D {
+	return bson.D{
+		{Key: "kind", Value: kind},
+		{Key: "repository", Value: repository},
+		{Key: "channel", Value: channel},
+	}
+}
+
+func (t *Templates) collection() *mongo.Collection {
+	return t.Mongo.Collection(templateCollection)
+}
@@ -16,11 +16,15 @@ import (
 
 const (
 	userCollection = "users"
+
+	defaultListUsersLimit = 20
 )
 
 type Users struct {
 	*Mongo
 	Now func() time.Time
+	// Cipher encrypts personal data when configured.
+	Cipher *Cipher
 }
 
 func (u *Users) AddUser(ctx context.Context, user *app.User) error {
@@ -28,9 +32,13 @@ func (u *Users) AddUser(ctx context.Context, user *app.User) error {
 	user.CreatedAt = now
 	user.UpdatedAt = now
 
-	result, err := u.collection().InsertOne(ctx, user)
+	doc, err := u.document(user)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	result, err := u.collection().InsertOne(ctx, doc)
 	if err != nil {
-		return errors.Annotatef(err, "user:%v", user)
+		return errors.Annotatef(err, "github user_name=%s", user.Github.UserName)
 	}
 
 	log.Debug("add user", zap.Reflect("insertOneResult", result))
@@ -39,11 +47,13 @@ func (u *Users) AddUser(ctx context.Context, user *app.User) error {
 
 func (u *Users) UpdateUser(ctx context.Context, input *app.UpdateUserInput) error {
 	input.User.UpdatedAt = u.Now()
-	result, err := u.collection().ReplaceOne(ctx,
-		input.Filter.BsonDWithoutTimestamp(),
-		input.User)
+	doc, err := u.document(input.User)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	result, err := u.collection().ReplaceOne(ctx, u.filter(input.Filter), doc)
 	if err != nil {
-		return errors.Annotatef(err, "input=%v", input)
+		return errors.Annotatef(err, "github user_name=%s", input.User.Github.UserName)
 	}
 	log.Debug("update user", zap.Reflect("updateOneResult", result))
 	return nil
@@ -55,7 +65,7 @@ func (u *Users) FindUsers(ctx context.Context, input *app.FindUsersInput) (app.U
 		opts.SetLimit(input.Limit)
 	}
 
-	cur, err := u.collection().Find(ctx, input.Filter.BsonDWithoutTimestamp(), opts)
+	cur, err := u.collection().Find(ctx, u.filter(input.Filter), opts)
 	if err != nil {
 		return nil, errors.Annotatef(err, "input=%v", input)
 	}
@@ -63,16 +73,18 @@ func (u *Users) FindUsers(ctx context.Context, input *app.FindUsersInput) (app.U
 
 	var users app.Users
 	for cur.Next(ctx) {
-		var user app.User
-		if err := cur.Decode(&user); err != nil {
+		var doc userDocument
+		if err := cur.Decode(&doc); err != nil {
 			return nil, errors.Annotate(err, "failed to decode user")
 		}
-		if user.IsDeleted() && !input.IncludeDeleted {
+		if doc.IsDeleted() && !input.IncludeDeleted {
 			continue
 		}
-		// currently, mongo does not store timezone.
-		user.ApplyTimeZone(app.TimeZone)
-		users = append(users, &user)
+		user, err := u.user(&doc)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		users = append(users, user)
 	}
 	if len(users) == 0 {
 		return nil, app.ErrUserNotFound
@@ -80,6 +92,70 @@ func (u *Users) FindUsers(ctx context.Context, input *app.FindUsersInput) (app.U
 	return users, nil
 }
 
+// ListUsers returns a page of users ordered by input.Sort.
+// pagination is keyset based, so NextCursor stays valid while users are added or deleted.
+func (u *Users) ListUsers(ctx context.Context, input *app.ListUsersInput) (*app.ListUsersOutput, error) {
+	sort, err := app.ParseUserSort(input.Sort)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if sort.Key == slackEmailKey && u.Cipher != nil {
+		return nil, errors.New("sort by email is not available while encryption is enabled")
+	}
+	query, err := u.query(input.Query)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	conds := bson.A{query.BsonD(input.IncludeDeleted)}
+	if filter := u.filter(input.Filter); len(filter) > 0 {
+		conds = append(conds, filter)
+	}
+	if input.Cursor != "" {
+		cursor, err := sort.DecodeCursor(input.Cursor)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		conds = append(conds, sort.After(cursor))
+	}
+
+	limit := input.Limit
+	if limit <= 0 {
+		limit = defaultListUsersLimit
+	}
+	// fetch one more user to know whether next page exists.
+	opts := options.Find().SetSort(sort.BsonD()).SetLimit(limit + 1)
+
+	cur, err := u.collection().Find(ctx, bson.D{{Key: "$and", Value: conds}}, opts)
+	if err != nil {
+		return nil, errors.Annotatef(err, "input=%v", input)
+	}
+	defer cur.Close(ctx)
+
+	var users app.Users
+	for cur.Next(ctx) {
+		var doc userDocument
+		if err := cur.Decode(&doc); err != nil {
+			return nil, errors.Annotate(err, "failed to decode user")
+		}
+		user, err := u.user(&doc)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		users = append(users, user)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	output := &app.ListUsersOutput{Users: users}
+	if int64(len(users)) > limit {
+		output.Users = users[:limit]
+		output.NextCursor = sort.Cursor(output.Users[limit-1])
+	}
+	return output, nil
+}
+
 func (u *Users) DeleteUsers(ctx context.Context, input *app.DeleteUsersInput) (*app.DeleteUsersOutput, error) {
 	if input.Filter == nil && !input.All {
 		return nil, errors.New("unsafe deletion process. if you want to delete all, enable the all flag")
@@ -91,7 +167,7 @@ func (u *Users) DeleteUsers(ctx context.Context, input *app.DeleteUsersInput) (*
 }
 
 func (u *Users) hardDeleteUsers(ctx context.Context, input *app.DeleteUsersInput) (*app.DeleteUsersOutput, error) {
-	result, err := u.collection().DeleteMany(ctx, input.Filter.BsonDWithoutTimestamp())
+	result, err := u.collection().DeleteMany(ctx, u.filter(input.Filter))
 	if err != nil {
 		return nil, errors.Annotatef(err, "failed to delete user. input=%v", input)
 	}
@@ -103,7 +179,7 @@ func (u *Users) hardDeleteUsers(ctx context.Context, input *app.DeleteUsersInput
 
 func (u *Users) softDeleteUsers(ctx context.Context, input *app.DeleteUsersInput) (*app.DeleteUsersOutput, error) {
 	result, err := u.collection().UpdateOne(ctx,
-		input.Filter.BsonDWithoutTimestamp(),
+		u.filter(input.Filter),
 		bson.D{
 			{Key: "$set", Value: bson.D{
 				{Key: "deleted_at", Value: u.Now()},
@@ -0,0 +1,145 @@
+package store
+
+import (
+	"context"
+
+	"github.com/juju/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+
+	"github.com/ymgyt/gobot/app"
+	"github.com/ymgyt/gobot/log"
+)
+
+const (
+	slackEmailKey           = "slack.email"
+	slackEmailBlindIndexKey = "blind_index.slack_email"
+)
+
+// userDocument is a stored form of app.User.
+// when Cipher is configured, personal data is moved from User to Encrypted and BlindIndex.
+type userDocument struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	app.User   `bson:",inline"`
+	Encrypted  *encryptedUserFields `bson:"encrypted,omitempty"`
+	BlindIndex *userBlindIndex      `bson:"blind_index,omitempty"`
+}
+
+type encryptedUserFields struct {
+	SlackEmail *EncryptedValue `bson:"slack_email,omitempty"`
+}
+
+type userBlindIndex struct {
+	SlackEmail string `bson:"slack_email,omitempty"`
+}
+
+func (u *Users) document(user *app.User) (*userDocument, error) {
+	doc := &userDocument{User: *user}
+	if u.Cipher == nil || user.Slack.Email == "" {
+		return doc, nil
+	}
+
+	encrypted, err := u.Cipher.Encrypt(user.Slack.Email)
+	if err != nil {
+		return nil, errors.Annotate(err, "failed to encrypt slack email")
+	}
+	doc.Encrypted = &encryptedUserFields{SlackEmail: encrypted}
+	doc.BlindIndex = &userBlindIndex{SlackEmail: u.Cipher.BlindIndex(user.Slack.Email)}
+	doc.User.Slack.Email = ""
+	return doc, nil
+}
+
+func (u *Users) user(doc *userDocument) (*app.User, error) {
+	user := doc.User
+	if doc.Encrypted != nil && doc.Encrypted.SlackEmail != nil {
+		if u.Cipher == nil {
+			return nil, errors.New("user document is encrypted but encryption key is not configured")
+		}
+		email, err := u.Cipher.Decrypt(doc.Encrypted.SlackEmail)
+		if err != nil {
+			return nil, errors.Annotatef(err, "github user_name=%s", user.Github.UserName)
+		}
+		user.Slack.Email = email
+	}
+	// currently, mongo does not store timezone.
+	user.ApplyTimeZone(app.TimeZone)
+	return &user, nil
+}
+
+// filter converts user to mongo filter with dotted keys, so that unspecified fields in sub documents are ignored.
+func (u *Users) filter(user *app.User) bson.D {
+	d := bson.D{}
+	if user == nil {
+		return d
+	}
+	if user.Github.UserName != "" {
+		d = append(d, primitive.E{Key: "github.user_name", Value: user.Github.UserName})
+	}
+	if user.Slack.ID != "" {
+		d = append(d, primitive.E{Key: "slack.id", Value: user.Slack.ID})
+	}
+	if user.Slack.Email != "" {
+		if u.Cipher != nil {
+			d = append(d, primitive.E{Key: slackEmailBlindIndexKey, Value: u.Cipher.BlindIndex(user.Slack.Email)})
+		} else {
+			d = append(d, primitive.E{Key: slackEmailKey, Value: user.Slack.Email})
+		}
+	}
+	return d
+}
+
+// query rewrites email conditions to blind index lookups. only exact match is available for encrypted fields.
+func (u *Users) query(q *app.UserQuery) (*app.UserQuery, error) {
+	if u.Cipher == nil || q == nil {
+		return q, nil
+	}
+	rewritten := &app.UserQuery{DeletedOnly: q.DeletedOnly}
+	for _, c := range q.Conditions {
+		if c.Key == slackEmailKey {
+			if c.Operator != "=" {
+				return nil, errors.New("only exact match(email=) is available for email while encryption is enabled")
+			}
+			c = app.UserCondition{Key: slackEmailBlindIndexKey, Operator: "=", Value: u.Cipher.BlindIndex(c.Value.(string))}
+		}
+		rewritten.Conditions = append(rewritten.Conditions, c)
+	}
+	return rewritten, nil
+}
+
+// EncryptExisting encrypts plaintext personal data of stored users in place.
+// it is safe to run multiple times since encrypted documents are skipped.
+func (u *Users) EncryptExisting(ctx context.Context) (int, error) {
+	if u.Cipher == nil {
+		return 0, errors.New("encryption key is not configured")
+	}
+	cur, err := u.collection().Find(ctx, bson.D{
+		{Key: slackEmailKey, Value: bson.D{{Key: "$exists", Value: true}, {Key: "$ne", Value: ""}}},
+	})
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	defer cur.Close(ctx)
+
+	var migrated int
+	for cur.Next(ctx) {
+		var plain userDocument
+		if err := cur.Decode(&plain); err != nil {
+			return migrated, errors.Annotate(err, "failed to decode user")
+		}
+		doc, err := u.document(&plain.User)
+		if err != nil {
+			return migrated, errors.Trace(err)
+		}
+		doc.ID = plain.ID
+		if _, err := u.collection().ReplaceOne(ctx, bson.D{{Key: "_id", Value: plain.ID}}, doc); err != nil {
+			return migrated, errors.Annotatef(err, "_id=%s", plain.ID.Hex())
+		}
+		migrated++
+	}
+	if err := cur.Err(); err != nil {
+		return migrated, errors.Trace(err)
+	}
+	log.Info("encrypt existing users", zap.Int("migrated", migrated))
+	return migrated, nil
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/juju/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/ymgyt/gobot/app"
+)
+
+const (
+	webhookDeliveryCollection = "webhook_deliveries"
+)
+
+// WebhookDeliveries implements app.WebhookDeliveryStore.
+// deliveries are removed by TTL index after retention.
+type WebhookDeliveries struct {
+	*Mongo
+	Now func() time.Time
+}
+
+// EnsureIndexes -
+func (w *WebhookDeliveries) EnsureIndexes(ctx context.Context) error {
+	_, err := w.collection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "expire_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		{Keys: bson.D{{Key: "event", Value: 1}, {Key: "received_at", Value: -1}}},
+	})
+	return errors.Annotate(err, "create webhook delivery indexes")
+}
+
+// SaveDelivery saves delivery. redelivery from github overwrites the delivery with the same id.
+func (w *WebhookDeliveries) SaveDelivery(ctx context.Context, d *app.WebhookDelivery) error {
+	_, err := w.collection().ReplaceOne(ctx,
+		bson.D{{Key: "_id", Value: d.ID}},
+		d,
+		options.Replace().SetUpsert(true))
+	return errors.Annotatef(err, "delivery id=%s", d.ID)
+}
+
+func (w *WebhookDeliveries) GetDelivery(ctx context.Context, id string) (*app.WebhookDelivery, error) {
+	var d app.WebhookDelivery
+	err := w.collection().FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&d)
+	if err == mongo.ErrNoDocuments {
+		return nil, errors.NotFoundf("webhook delivery %s", id)
+	}
+	if err != nil {
+		return nil, errors.Annotatef(err, "delivery id=%s", id)
+	}
+	return &d, nil
+}
+
+func (w *WebhookDeliveries) ListDeliveries(ctx context.Context, input *app.ListWebhookDeliveriesInput) (app.WebhookDeliveries, error) {
+	filter := bson.D{}
+	if input.Event != "" {
+		filter = append(filter, primitive.E{Key: "event", Value: input.Event})
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "received_at", Value: -1}})
+	if input.Limit > 0 {
+		opts.SetLimit(input.Limit)
+	}
+
+	cur, err := w.collection().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, errors.Annotatef(err, "input=%v", input)
+	}
+	defer cur.Close(ctx)
+
+	ds := app.WebhookDeliveries{}
+	for cur.Next(ctx) {
+		var d app.WebhookDelivery
+		if err := cur.Decode(&d); err != nil {
+			return nil, errors.Annotate(err, "failed to decode webhook delivery")
+		}
+		ds = append(ds, &d)
+	}
+	return ds, errors.Trace(cur.Err())
+}
+
+func (w *WebhookDeliveries) collection() *mongo.Collection {
+	return w.Mongo.Collection(webhookDeliveryCollection)
+}